@@ -0,0 +1,112 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/armresourcegraph2"
+	"sync"
+	"time"
+)
+
+// Client runs Azure Resource Graph queries with its own credential and options. Pass it to
+// [Exec], [Stream] and other functions with [ExecOptions.Client], otherwise they use the
+// shared default client.
+//
+// Example:
+//
+//	cred, err := azidentity.NewClientSecretCredential(tenantID, clientID, secret, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	client, err := rg.NewClient(cred, &rg.ClientOptions{Decoder: rg.StdDecoder})
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	items, err := rg.Exec[record](ctx, query, &rg.ExecOptions{Client: client})
+type Client struct {
+	armClient *armresourcegraph2.Client
+	decoder   Decoder
+
+	// lookups is the cache of [ExecOptions.Enrich].
+	lookups *lookups
+}
+
+// ClientOptions are the optional parameters for [NewClient].
+type ClientOptions struct {
+	// ClientOptions are the Azure SDK options for the underlying ARM client, e.g. the cloud or retries.
+	arm.ClientOptions
+
+	// Decoder unmarshals the rows into the result types, the default is [JsoniterDecoder].
+	Decoder Decoder
+
+	// LookupTTL is how long the client caches the subscriptions and resource groups looked up
+	// by [ExecOptions.Enrich], the default is one hour.
+	LookupTTL time.Duration
+}
+
+// NewClient creates new Azure Resource Graph query client with the specified Azure token credential.
+// Both cred and options can be nil, in which case the shared default [azcore.TokenCredential]
+// created with [azidentity.NewDefaultAzureCredential] and default options are used.
+func NewClient(cred azcore.TokenCredential, options *ClientOptions) (*Client, error) {
+	if options == nil {
+		options = &ClientOptions{}
+	}
+
+	result := &Client{decoder: options.Decoder, lookups: newLookups(options.LookupTTL)}
+	if result.decoder == nil {
+		result.decoder = JsoniterDecoder
+	}
+
+	if cred == nil {
+		token, err := getDefaultCredentialToken()
+		if err != nil {
+			return nil, err
+		}
+		cred = token
+	}
+
+	armClient, err := armresourcegraph2.NewClient(cred, &options.ClientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	result.armClient = armClient
+	return result, nil
+}
+
+// defaultClient is the singleton shared default [Client], which keeps the lookups cache across calls.
+var defaultClient = struct {
+	once   sync.Once
+	client *Client
+	err    error
+}{}
+
+// NewDefaultClient returns the shared client with the shared default Azure token credential and default options.
+// This is the client used when [ExecOptions.Client] is nil.
+func NewDefaultClient() (*Client, error) {
+	defaultClient.once.Do(func() {
+		armClient, err := getDefaultArmClient()
+		if err != nil {
+			defaultClient.err = err
+			return
+		}
+
+		defaultClient.client = &Client{armClient: armClient, decoder: JsoniterDecoder, lookups: newLookups(0)}
+	})
+
+	return defaultClient.client, defaultClient.err
+}
+
+// getClient returns the client from the options, or the default client.
+func getClient(options *ExecOptions) (*Client, error) {
+	if options != nil && options.Client != nil {
+		return options.Client, nil
+	}
+
+	return NewDefaultClient()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"time"
+)
+
+// config is the exporter configuration file.
+//
+// Example:
+//
+//	interval: 5m
+//	queries:
+//	  - name: azure_resources
+//	    help: Number of resources by type and location.
+//	    query: |
+//	      resources
+//	      | summarize count_=count() by type, location
+//	    labels: [type, location]
+//	    value: count_
+//	  - name: azure_policy_noncompliant_resources
+//	    help: Number of resources not compliant with the policy assignments.
+//	    interval: 30m
+//	    query: |
+//	      policyresources
+//	      | where type =~ 'microsoft.policyinsights/policystates' and properties.complianceState =~ 'NonCompliant'
+//	      | summarize count_=dcount(tostring(properties.resourceId)) by assignment=tostring(properties.policyAssignmentName)
+//	    value: count_
+type config struct {
+	// Interval is the default time between the runs of the queries.
+	Interval duration `yaml:"interval"`
+
+	// Queries are the queries to turn into metrics.
+	Queries []queryConfig `yaml:"queries"`
+}
+
+// queryConfig is a query which produces a gauge metric.
+type queryConfig struct {
+	// Name is the metric name.
+	Name string `yaml:"name"`
+
+	// Help is the metric description.
+	Help string `yaml:"help"`
+
+	// Query is the KQL query. It must project the label columns and the value column.
+	Query string `yaml:"query"`
+
+	// Labels are the columns which become the metric labels. When empty, all columns except the value are labels.
+	Labels []string `yaml:"labels"`
+
+	// Value is the numeric column with the metric value. The values of the rows with the same labels are summed.
+	Value string `yaml:"value"`
+
+	// Interval is the time between the runs of this query, the default is the interval of the config.
+	Interval duration `yaml:"interval"`
+
+	// Subscriptions and ManagementGroups are the query scope, the default is all subscriptions accessible to the credential.
+	Subscriptions    []string `yaml:"subscriptions"`
+	ManagementGroups []string `yaml:"managementGroups"`
+}
+
+// duration is time.Duration which unmarshals from text like "5m".
+type duration time.Duration
+
+func (d *duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+	return nil
+}
+
+// defaultInterval is the time between the runs of the queries when the config doesn't set it.
+const defaultInterval = 5 * time.Minute
+
+// metricNameRegex matches valid Prometheus metric and label names.
+var metricNameRegex = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// loadConfig reads and validates the config file and fills in the defaults.
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result config
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("reading config '%s': %w", path, err)
+	}
+
+	if err := result.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config '%s': %w", path, err)
+	}
+
+	return &result, nil
+}
+
+func (c *config) validate() error {
+	if c.Interval <= 0 {
+		c.Interval = duration(defaultInterval)
+	}
+
+	if len(c.Queries) == 0 {
+		return errors.New("no queries")
+	}
+
+	names := map[string]bool{}
+	for i := range c.Queries {
+		q := &c.Queries[i]
+		if !metricNameRegex.MatchString(q.Name) {
+			return fmt.Errorf("query %d: invalid metric name '%s'", i+1, q.Name)
+		}
+
+		if names[q.Name] {
+			return fmt.Errorf("query '%s': duplicate metric name", q.Name)
+		}
+		names[q.Name] = true
+
+		if q.Query == "" {
+			return fmt.Errorf("query '%s': the query is empty", q.Name)
+		}
+
+		if q.Value == "" {
+			return fmt.Errorf("query '%s': the value column is not set", q.Name)
+		}
+
+		for _, l := range q.Labels {
+			if !metricNameRegex.MatchString(l) {
+				return fmt.Errorf("query '%s': invalid label name '%s'", q.Name, l)
+			}
+		}
+
+		if q.Help == "" {
+			q.Help = "Azure Resource Graph query " + q.Name + "."
+		}
+
+		if q.Interval <= 0 {
+			q.Interval = c.Interval
+		}
+	}
+
+	return nil
+}
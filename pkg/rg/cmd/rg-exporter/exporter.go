@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backend runs the queries. It is an interface so that the exporter can run against a fake.
+type backend interface {
+	ExecRows(ctx context.Context, query string, options *rg.ExecOptions) ([]rg.Row, error)
+}
+
+// rgBackend runs the queries against Azure Resource Graph.
+type rgBackend struct{}
+
+func (rgBackend) ExecRows(ctx context.Context, query string, options *rg.ExecOptions) ([]rg.Row, error) {
+	return rg.ExecRows(ctx, query, options)
+}
+
+// exporter runs the queries on their schedules and exposes the results as Prometheus gauges.
+// It is an unchecked collector, as the labels of the metrics come from the query results.
+type exporter struct {
+	backend backend
+	queries []queryConfig
+
+	mu      sync.Mutex
+	results map[string]*queryResult
+}
+
+// queryResult is the outcome of the latest run of a query.
+type queryResult struct {
+	// samples are from the latest successful run.
+	samples []prometheus.Metric
+
+	success  bool
+	duration time.Duration
+	lastRun  time.Time
+}
+
+var (
+	successDesc = prometheus.NewDesc("rg_exporter_query_success",
+		"Whether the latest run of the query succeeded.", []string{"query"}, nil)
+	durationDesc = prometheus.NewDesc("rg_exporter_query_duration_seconds",
+		"Duration of the latest run of the query.", []string{"query"}, nil)
+	lastRunDesc = prometheus.NewDesc("rg_exporter_query_last_run_timestamp_seconds",
+		"Time of the latest run of the query.", []string{"query"}, nil)
+)
+
+func newExporter(backend backend, queries []queryConfig) *exporter {
+	return &exporter{
+		backend: backend,
+		queries: queries,
+		results: map[string]*queryResult{},
+	}
+}
+
+// Describe implements prometheus.Collector. It sends no descriptions to make the collector unchecked.
+func (e *exporter) Describe(chan<- *prometheus.Desc) {
+}
+
+// Collect implements prometheus.Collector. When a run fails, the samples of the last successful run
+// are kept, and rg_exporter_query_success tells the run failed.
+func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, q := range e.queries {
+		r, ok := e.results[q.Name]
+		if !ok {
+			continue
+		}
+
+		for _, m := range r.samples {
+			ch <- m
+		}
+
+		success := 0.0
+		if r.success {
+			success = 1
+		}
+		ch <- prometheus.MustNewConstMetric(successDesc, prometheus.GaugeValue, success, q.Name)
+		ch <- prometheus.MustNewConstMetric(durationDesc, prometheus.GaugeValue, r.duration.Seconds(), q.Name)
+		ch <- prometheus.MustNewConstMetric(lastRunDesc, prometheus.GaugeValue, float64(r.lastRun.UnixNano())/1e9, q.Name)
+	}
+}
+
+// run runs each query on its interval until the context is done.
+func (e *exporter) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, q := range e.queries {
+		wg.Add(1)
+		go func(q queryConfig) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(time.Duration(q.Interval))
+			defer ticker.Stop()
+
+			for {
+				e.runQuery(ctx, q)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(q)
+	}
+
+	wg.Wait()
+}
+
+// runQuery runs the query once and stores the result.
+func (e *exporter) runQuery(ctx context.Context, q queryConfig) {
+	start := time.Now()
+	samples, err := e.collectQuery(ctx, q)
+	if err != nil {
+		log.Printf("query '%s': %s", q.Name, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r, ok := e.results[q.Name]
+	if !ok {
+		r = &queryResult{}
+		e.results[q.Name] = r
+	}
+
+	r.success = err == nil
+	r.duration = time.Since(start)
+	r.lastRun = start
+	if err == nil {
+		r.samples = samples
+	}
+}
+
+// collectQuery runs the query and converts its rows into the metric samples.
+func (e *exporter) collectQuery(ctx context.Context, q queryConfig) ([]prometheus.Metric, error) {
+	options := &rg.ExecOptions{
+		Subscriptions:    q.Subscriptions,
+		ManagementGroups: q.ManagementGroups,
+	}
+
+	rows, err := e.backend.ExecRows(ctx, q.Query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	labels := q.Labels
+	if len(labels) == 0 {
+		for _, c := range rows[0].Columns() {
+			if c.Name != q.Value {
+				labels = append(labels, c.Name)
+			}
+		}
+	}
+
+	// The rows with the same label values are summed, as duplicate samples would fail the whole scrape.
+	var samples []sample
+	indexes := map[string]int{}
+	for i, row := range rows {
+		raw, ok := row.Get(q.Value)
+		if !ok {
+			return nil, fmt.Errorf("row %d has no value column '%s'", i, q.Value)
+		}
+
+		value, err := toFloat(raw)
+		if err != nil {
+			return nil, fmt.Errorf("row %d, value column '%s': %w", i, q.Value, err)
+		}
+
+		labelValues := make([]string, len(labels))
+		for j, l := range labels {
+			v, ok := row.Get(l)
+			if !ok {
+				return nil, fmt.Errorf("row %d has no label column '%s'", i, l)
+			}
+			labelValues[j] = labelValue(v)
+		}
+
+		key := strings.Join(labelValues, "\xff")
+		if j, ok := indexes[key]; ok {
+			samples[j].value += value
+			continue
+		}
+
+		indexes[key] = len(samples)
+		samples = append(samples, sample{labelValues: labelValues, value: value})
+	}
+
+	if len(samples) < len(rows) {
+		log.Printf("query '%s': summed %d rows with the same labels as other rows", q.Name, len(rows)-len(samples))
+	}
+
+	desc := prometheus.NewDesc(q.Name, q.Help, labels, nil)
+	result := make([]prometheus.Metric, 0, len(samples))
+	for _, s := range samples {
+		m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.value, s.labelValues...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	return result, nil
+}
+
+// sample is the value of the metric for the label values.
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// toFloat converts the row value into the metric value.
+func toFloat(v any) (float64, error) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case nil:
+		return 0, fmt.Errorf("the value is null")
+	default:
+		return 0, fmt.Errorf("the value of type %T is not a number", v)
+	}
+}
+
+// labelValue converts the row value into the label value, null is empty.
+func labelValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case json.RawMessage:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
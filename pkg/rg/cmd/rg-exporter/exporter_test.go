@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// fakeBackend returns the rows or the error of the query.
+type fakeBackend struct {
+	rows map[string][]rg.Row
+	errs map[string]error
+}
+
+func (b *fakeBackend) ExecRows(_ context.Context, query string, _ *rg.ExecOptions) ([]rg.Row, error) {
+	if err := b.errs[query]; err != nil {
+		return nil, err
+	}
+	return b.rows[query], nil
+}
+
+var countColumns = []rg.Column{
+	{Name: "type", Type: rg.ColumnDataTypeString},
+	{Name: "location", Type: rg.ColumnDataTypeString},
+	{Name: "count_", Type: rg.ColumnDataTypeInteger},
+}
+
+// gather returns the values of the samples of the metric by their labels, joined with commas.
+func gather(t *testing.T, e *exporter, name string) map[string]float64 {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, m := range family.GetMetric() {
+			var key string
+			for i, label := range m.GetLabel() {
+				if i > 0 {
+					key += ","
+				}
+				key += label.GetName() + "=" + label.GetValue()
+			}
+			result[key] = m.GetGauge().GetValue()
+		}
+	}
+
+	return result
+}
+
+func TestExporter(t *testing.T) {
+	backend := &fakeBackend{rows: map[string][]rg.Row{
+		"resources": {
+			rg.NewRow(countColumns, []any{"vm", "westeurope", int64(2)}),
+			rg.NewRow(countColumns, []any{"disk", "westeurope", int64(3)}),
+			// The same labels as the first row, e.g. the query groups by more columns than the labels.
+			rg.NewRow(countColumns, []any{"vm", "westeurope", int64(4)}),
+		},
+	}}
+
+	e := newExporter(backend, []queryConfig{{Name: "azure_resources", Query: "resources", Value: "count_"}})
+	e.runQuery(context.Background(), e.queries[0])
+
+	got := gather(t, e, "azure_resources")
+	want := map[string]float64{
+		"location=westeurope,type=vm":   6,
+		"location=westeurope,type=disk": 3,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("got %s %v, want %v", key, got[key], value)
+		}
+	}
+
+	if success := gather(t, e, "rg_exporter_query_success"); success["query=azure_resources"] != 1 {
+		t.Errorf("got success %v, want 1", success)
+	}
+}
+
+func TestExporterLabels(t *testing.T) {
+	backend := &fakeBackend{rows: map[string][]rg.Row{
+		"resources": {
+			rg.NewRow(countColumns, []any{"vm", "westeurope", int64(2)}),
+			rg.NewRow(countColumns, []any{"vm", "northeurope", int64(5)}),
+		},
+	}}
+
+	e := newExporter(backend, []queryConfig{{Name: "azure_resources", Query: "resources", Labels: []string{"type"}, Value: "count_"}})
+	e.runQuery(context.Background(), e.queries[0])
+
+	if got := gather(t, e, "azure_resources"); len(got) != 1 || got["type=vm"] != 7 {
+		t.Errorf("got %v, want type=vm 7", got)
+	}
+}
+
+func TestExporterFailure(t *testing.T) {
+	backend := &fakeBackend{rows: map[string][]rg.Row{
+		"resources": {rg.NewRow(countColumns, []any{"vm", "westeurope", int64(2)})},
+		"bad":       {rg.NewRow(countColumns, []any{"vm", "westeurope", "many"})},
+	}}
+
+	e := newExporter(backend, []queryConfig{
+		{Name: "azure_resources", Query: "resources", Value: "count_"},
+		{Name: "azure_bad", Query: "bad", Value: "count_"},
+	})
+	for _, q := range e.queries {
+		e.runQuery(context.Background(), q)
+	}
+
+	// The failed query doesn't fail the scrape of the other.
+	if got := gather(t, e, "azure_resources"); got["location=westeurope,type=vm"] != 2 {
+		t.Errorf("got %v, want the sample of azure_resources", got)
+	}
+
+	success := gather(t, e, "rg_exporter_query_success")
+	if success["query=azure_resources"] != 1 || success["query=azure_bad"] != 0 {
+		t.Errorf("got success %v", success)
+	}
+
+	// A failed run keeps the samples of the last successful run.
+	backend.errs = map[string]error{"resources": errors.New("throttled")}
+	e.runQuery(context.Background(), e.queries[0])
+
+	if got := gather(t, e, "azure_resources"); got["location=westeurope,type=vm"] != 2 {
+		t.Errorf("got %v, want the sample of the last successful run", got)
+	}
+
+	if success := gather(t, e, "rg_exporter_query_success"); success["query=azure_resources"] != 0 {
+		t.Errorf("got success %v, want 0", success)
+	}
+}
@@ -0,0 +1,97 @@
+// Command rg-exporter runs Azure Resource Graph queries on a schedule and exposes
+// the results as Prometheus gauges.
+//
+// Usage:
+//
+//	rg-exporter -config queries.yaml [-listen :9090]
+//
+// Each query in the config projects the label columns and one numeric value column,
+// and each row becomes a sample of the gauge named after the query, e.g. the query
+//
+//	resources | summarize count_=count() by type, location
+//
+// with the value column "count_" gives samples like azure_resources{type="...",location="..."} 42.
+// See the config type for the file format.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("rg-exporter: ")
+
+	var (
+		configPath string
+		listen     string
+	)
+
+	flag.StringVar(&configPath, "config", "rg-exporter.yaml", "the config `file` with the queries")
+	flag.StringVar(&listen, "listen", ":9090", "the `address` to serve /metrics on")
+	flag.Parse()
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := serve(ctx, listen, newExporter(rgBackend{}, cfg.Queries)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve runs the exporter and serves the metrics until the context is done.
+func serve(ctx context.Context, listen string, e *exporter) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.run(ctx)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving metrics on %s/metrics", listen)
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	// Stops the queries when the server fails to start.
+	cancel()
+	<-done
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// auditEntry is a record of the audit log.
+type auditEntry struct {
+	Time       time.Time         `json:"time"`
+	Caller     string            `json:"caller"`
+	RemoteAddr string            `json:"remoteAddr"`
+	Query      string            `json:"query,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Status     int               `json:"status"`
+	Rows       int               `json:"rows"`
+	Cached     bool              `json:"cached"`
+	Duration   float64           `json:"durationSeconds"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// auditLog writes the audit log as JSON lines.
+type auditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newAuditLog(w io.Writer) *auditLog {
+	return &auditLog{enc: json.NewEncoder(w)}
+}
+
+func (a *auditLog) write(e auditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.enc.Encode(e); err != nil {
+		log.Printf("writing audit log: %s", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"sync"
+	"time"
+)
+
+// cache keeps the query results for the TTL, up to the maximum number of entries.
+type cache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	// now returns the current time, it is replaced in the tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	rows    []rg.Row
+	expires time.Time
+}
+
+func newCache(ttl time.Duration, maxEntries int) *cache {
+	return &cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[string]cacheEntry{},
+	}
+}
+
+// get returns the cached rows for the key.
+func (c *cache) get(key string) ([]rg.Row, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if c.now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return e.rows, true
+}
+
+// put caches the rows for the key. When the cache is full, it drops the expired entries,
+// and then the entry which expires first if the cache is still full.
+func (c *cache) put(key string, rows []rg.Row) {
+	if c.ttl <= 0 || c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		var oldest string
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			} else if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, oldest)
+		}
+	}
+
+	c.entries[key] = cacheEntry{rows: rows, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	cache := newCache(time.Minute, 10)
+	cache.now = c.now
+
+	rows := []rg.Row{rg.NewRow([]rg.Column{{Name: "name", Type: rg.ColumnDataTypeString}}, []any{"a"})}
+	cache.put("a", rows)
+
+	if got, ok := cache.get("a"); !ok || len(got) != 1 {
+		t.Errorf("got %v, %v, want the cached rows", got, ok)
+	}
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("got rows of a key which is not cached")
+	}
+
+	c.add(time.Minute + time.Second)
+	if _, ok := cache.get("a"); ok {
+		t.Error("got the expired rows")
+	}
+
+	if len(cache.entries) != 0 {
+		t.Errorf("got %d entries, want the expired entry dropped", len(cache.entries))
+	}
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	cache := newCache(time.Minute, 3)
+	cache.now = c.now
+
+	for _, key := range []string{"a", "b", "c"} {
+		cache.put(key, nil)
+		c.add(10 * time.Second)
+	}
+
+	// The full cache drops the entry which expires first.
+	cache.put("d", nil)
+	if _, ok := cache.get("a"); ok || len(cache.entries) != 3 {
+		t.Errorf("got entries %v, want a dropped", cache.entries)
+	}
+
+	// Updating an entry doesn't drop others.
+	cache.put("b", nil)
+	if len(cache.entries) != 3 {
+		t.Errorf("got entries %v, want 3", cache.entries)
+	}
+
+	// The expired entries are dropped first.
+	c.add(time.Minute)
+	cache.put("e", nil)
+	if len(cache.entries) != 3 {
+		t.Errorf("got entries %v, want b, d and e", cache.entries)
+	}
+	for _, key := range []string{"b", "d", "e"} {
+		if _, ok := cache.entries[key]; !ok {
+			t.Errorf("got entries %v, want %s", cache.entries, key)
+		}
+	}
+}
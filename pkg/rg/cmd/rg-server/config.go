@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/lib"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// config is the server configuration file.
+//
+// Example:
+//
+//	listen: ":8080"
+//	cacheTTL: 5m
+//	cacheMaxEntries: 1000
+//	rateLimit:
+//	  requestsPerMinute: 60
+//	  burst: 10
+//	auditLog: /var/log/rg-server/audit.jsonl
+//	library: ./queries
+//	callers:
+//	  - name: portal
+//	    keyEnv: RG_SERVER_PORTAL_KEY
+//	queries:
+//	  - name: public-ips
+//	    description: Public IP addresses in a location.
+//	    parameters:
+//	      - name: region
+//	        type: string
+//	        required: true
+//	    query: |
+//	      resources
+//	      | where type =~ 'microsoft.network/publicipaddresses' and location =~ region
+//	      | project id, name, ipAddress=tostring(properties.ipAddress)
+//
+// The queries are the saved query files from the library directory and the queries in the config
+// file, which have the same fields as the front-matter of the files plus the query text.
+// The parameters are passed to the query as let statements, e.g. "let region = 'westeurope';",
+// so the query refers to them by name and the values are never a part of the query text other than literals.
+// The parameter names should differ from the column names the query uses.
+type config struct {
+	// Listen is the address to serve on, the default is ":8080".
+	Listen string `yaml:"listen"`
+
+	// CacheTTL is how long the results are cached, the default is 5 minutes. Negative disables caching.
+	CacheTTL duration `yaml:"cacheTTL"`
+
+	// CacheMaxEntries is the maximum number of cached results, the default is 1000. When the cache
+	// is full, the results which expire first are dropped.
+	CacheMaxEntries int `yaml:"cacheMaxEntries"`
+
+	// Timeout is the maximum time to run a query, the default is 1 minute.
+	Timeout duration `yaml:"timeout"`
+
+	// RateLimit is the rate limit of each caller.
+	RateLimit rateLimitConfig `yaml:"rateLimit"`
+
+	// AuditLog is the file to append the audit log to, the default is stderr.
+	AuditLog string `yaml:"auditLog"`
+
+	// Callers are the callers allowed to use the server. When empty, the server is open
+	// and callers are identified by their IP addresses for the rate limits and the audit log.
+	Callers []callerConfig `yaml:"callers"`
+
+	// Library is the directory with the saved query files, see the lib package for the format.
+	// A relative path is relative to the directory of the config file.
+	Library string `yaml:"library"`
+
+	// Queries are the queries in the config file, in addition to the library.
+	Queries []lib.Query `yaml:"queries"`
+
+	// library has the queries served at /queries/{name}, from both the library and the config file.
+	library *lib.Library
+}
+
+// rateLimitConfig is the token bucket rate limit of a caller.
+type rateLimitConfig struct {
+	// RequestsPerMinute is the sustained request rate, the default is 60. Negative disables rate limits.
+	RequestsPerMinute float64 `yaml:"requestsPerMinute"`
+
+	// Burst is the number of requests allowed at once, the default is 10.
+	Burst int `yaml:"burst"`
+}
+
+// callerConfig is a caller identified by its API key, which it sends in the X-API-Key header
+// or as the bearer token in the Authorization header.
+type callerConfig struct {
+	// Name is the caller name in the audit log.
+	Name string `yaml:"name"`
+
+	// Key is the API key of the caller.
+	Key string `yaml:"key"`
+
+	// KeyEnv is the environment variable with the API key, to keep the keys out of the config file.
+	KeyEnv string `yaml:"keyEnv"`
+}
+
+// duration is time.Duration which unmarshals from text like "5m".
+type duration time.Duration
+
+func (d *duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = duration(v)
+	return nil
+}
+
+// reservedParameters are the URL query parameters used by the server itself.
+var reservedParameters = map[string]bool{
+	"format": true,
+}
+
+// loadConfig reads and validates the config file and fills in the defaults.
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result config
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("reading config '%s': %w", path, err)
+	}
+
+	if result.Library != "" && !filepath.IsAbs(result.Library) {
+		result.Library = filepath.Join(filepath.Dir(path), result.Library)
+	}
+
+	if err := result.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config '%s': %w", path, err)
+	}
+
+	return &result, nil
+}
+
+func (c *config) validate() error {
+	if c.Listen == "" {
+		c.Listen = ":8080"
+	}
+
+	if c.CacheTTL == 0 {
+		c.CacheTTL = duration(5 * time.Minute)
+	}
+
+	if c.CacheMaxEntries <= 0 {
+		c.CacheMaxEntries = 1000
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = duration(time.Minute)
+	}
+
+	if c.RateLimit.RequestsPerMinute == 0 {
+		c.RateLimit.RequestsPerMinute = 60
+	}
+
+	if c.RateLimit.Burst <= 0 {
+		c.RateLimit.Burst = 10
+	}
+
+	for i := range c.Callers {
+		caller := &c.Callers[i]
+		if caller.Name == "" {
+			return fmt.Errorf("caller %d: the name is empty", i+1)
+		}
+
+		if caller.KeyEnv != "" {
+			caller.Key = os.Getenv(caller.KeyEnv)
+		}
+
+		if caller.Key == "" {
+			return fmt.Errorf("caller '%s': the key is empty", caller.Name)
+		}
+	}
+
+	c.library = lib.New()
+	for i := range c.Queries {
+		if err := c.library.Add(&c.Queries[i]); err != nil {
+			return err
+		}
+	}
+
+	if c.Library != "" {
+		if err := c.library.LoadDir(c.Library); err != nil {
+			return err
+		}
+	}
+
+	queries := c.library.Queries()
+	if len(queries) == 0 {
+		return errors.New("no queries")
+	}
+
+	for _, q := range queries {
+		for _, p := range q.Parameters {
+			if reservedParameters[p.Name] {
+				return fmt.Errorf("query '%s': the parameter name '%s' is reserved", q.Name, p.Name)
+			}
+		}
+	}
+
+	return nil
+}
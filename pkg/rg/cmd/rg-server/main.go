@@ -0,0 +1,97 @@
+// Command rg-server serves named, parameterised Azure Resource Graph queries as REST endpoints,
+// so that other teams can get inventory data without Azure credentials of their own.
+//
+// Usage:
+//
+//	rg-server -config queries.yaml
+//
+// Endpoints:
+//
+//	GET /queries                 the list of queries with their parameters
+//	GET /queries/{name}?p=v      the rows of the query as JSON, or CSV with format=csv or Accept: text/csv
+//
+// The results are cached, each caller is rate limited, and each query request is written to
+// the audit log. See the config type for the file format.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("rg-server: ")
+
+	var (
+		configPath string
+		listen     string
+	)
+
+	flag.StringVar(&configPath, "config", "rg-server.yaml", "the config `file` with the queries")
+	flag.StringVar(&listen, "listen", "", "the `address` to serve on, overrides the config")
+	flag.Parse()
+
+	if err := run(configPath, listen); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(configPath string, listen string) error {
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	if listen != "" {
+		cfg.Listen = listen
+	}
+
+	var auditWriter io.Writer = os.Stderr
+	if cfg.AuditLog != "" {
+		f, err := os.OpenFile(cfg.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		auditWriter = f
+	}
+
+	client, err := rg.NewDefaultClient()
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           newServer(cfg, rgBackend{client: client}, newAuditLog(auditWriter)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving on %s", cfg.Listen)
+	err = server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	return err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket rate limiter per caller.
+type rateLimiter struct {
+	// rate is the number of tokens added per second, zero or negative disables the limits.
+	rate  float64
+	burst float64
+
+	// now returns the current time, it is replaced in the tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+
+	// swept is the time the full buckets were last dropped.
+	swept time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(requestsPerMinute float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    requestsPerMinute / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// allow takes a token from the bucket of the caller. When there is none, it returns false
+// and the time until the next token.
+func (l *rateLimiter) allow(caller string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[caller]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[caller] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops the buckets of the callers idle long enough for their buckets to be full again,
+// as they are the same as new buckets. Otherwise the buckets of all callers ever seen, e.g. all
+// IP addresses of an open server, would stay in memory. It runs once per refill time at most.
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.swept) < refill {
+		return
+	}
+
+	for caller, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, caller)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is the time of the tests, which only moves when told.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func TestRateLimiter(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	l := newRateLimiter(60, 2)
+	l.now = c.now
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a"); !ok {
+			t.Fatalf("request %d is limited, want the burst allowed", i+1)
+		}
+	}
+
+	ok, wait := l.allow("a")
+	if ok || wait != time.Second {
+		t.Errorf("got %v and wait %s, want the request limited for 1s", ok, wait)
+	}
+
+	// The other callers have their own buckets.
+	if ok, _ := l.allow("b"); !ok {
+		t.Error("the request of another caller is limited")
+	}
+
+	c.add(time.Second)
+	if ok, _ := l.allow("a"); !ok {
+		t.Error("the request is limited after the wait")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	c := &clock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	l := newRateLimiter(60, 10)
+	l.now = c.now
+
+	for _, caller := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		l.allow(caller)
+	}
+
+	// The buckets refill in 10s, the buckets of the callers idle for that long are dropped.
+	c.add(5 * time.Second)
+	l.allow("10.0.0.1")
+
+	c.add(5 * time.Second)
+	l.allow("10.0.0.4")
+
+	if len(l.buckets) != 2 || l.buckets["10.0.0.1"] == nil || l.buckets["10.0.0.4"] == nil {
+		t.Errorf("got buckets %v, want the buckets of the recent callers", l.buckets)
+	}
+
+	l.rate = 0
+	if ok, _ := l.allow("10.0.0.1"); !ok {
+		t.Error("the request is limited with the limits disabled")
+	}
+}
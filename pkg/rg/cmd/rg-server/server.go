@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/lib"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/output"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backend runs the queries. It is an interface so that the server can run against a fake.
+type backend interface {
+	ExecRows(ctx context.Context, query string, options *rg.ExecOptions) ([]rg.Row, error)
+}
+
+// rgBackend runs the queries against Azure Resource Graph with the client of the server.
+type rgBackend struct {
+	client *rg.Client
+}
+
+func (b rgBackend) ExecRows(ctx context.Context, query string, options *rg.ExecOptions) ([]rg.Row, error) {
+	options.Client = b.client
+	return rg.ExecRows(ctx, query, options)
+}
+
+// server serves the named queries at GET /queries/{name} and their list at GET /queries.
+type server struct {
+	backend backend
+	library *lib.Library
+	callers []callerConfig
+	timeout time.Duration
+	cache   *cache
+	limiter *rateLimiter
+	audit   *auditLog
+}
+
+func newServer(cfg *config, backend backend, audit *auditLog) *server {
+	return &server{
+		backend: backend,
+		library: cfg.library,
+		callers: cfg.Callers,
+		timeout: time.Duration(cfg.Timeout),
+		cache:   newCache(time.Duration(cfg.CacheTTL), cfg.CacheMaxEntries),
+		limiter: newRateLimiter(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.Burst),
+		audit:   audit,
+	}
+}
+
+// httpError is an error with the HTTP status to respond with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func errorf(status int, format string, args ...any) error {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/queries":
+		s.serveList(w, r)
+	case strings.HasPrefix(path, "/queries/") && !strings.Contains(path[len("/queries/"):], "/"):
+		s.serveQuery(w, r, path[len("/queries/"):])
+	default:
+		writeError(w, errorf(http.StatusNotFound, "not found"))
+	}
+}
+
+// serveList responds with the names, descriptions and parameters of the queries.
+func (s *server) serveList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, errorf(http.StatusMethodNotAllowed, "method %s is not allowed", r.Method))
+		return
+	}
+
+	if _, err := s.authenticate(r); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	type parameter struct {
+		Name        string `json:"name"`
+		Type        string `json:"type"`
+		Description string `json:"description,omitempty"`
+		Required    bool   `json:"required"`
+		Default     string `json:"default,omitempty"`
+	}
+
+	type query struct {
+		Name        string      `json:"name"`
+		Description string      `json:"description,omitempty"`
+		Version     string      `json:"version,omitempty"`
+		Parameters  []parameter `json:"parameters"`
+	}
+
+	queries := s.library.Queries()
+	result := make([]query, 0, len(queries))
+	for _, q := range queries {
+		item := query{Name: q.Name, Description: q.Description, Version: q.Version, Parameters: []parameter{}}
+		for _, p := range q.Parameters {
+			item.Parameters = append(item.Parameters, parameter{
+				Name:        p.Name,
+				Type:        string(p.Type),
+				Description: p.Description,
+				Required:    p.Required,
+				Default:     p.Default,
+			})
+		}
+		result = append(result, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// serveQuery runs the query and responds with its rows.
+func (s *server) serveQuery(w http.ResponseWriter, r *http.Request, name string) {
+	start := time.Now()
+	entry := auditEntry{
+		Time:       start.UTC(),
+		RemoteAddr: r.RemoteAddr,
+		Query:      name,
+		Parameters: flattenValues(r.URL.Query()),
+		Status:     http.StatusOK,
+	}
+
+	defer func() {
+		entry.Duration = time.Since(start).Seconds()
+		s.audit.write(entry)
+	}()
+
+	rw := &responseWriter{ResponseWriter: w}
+	err := s.execQuery(rw, r, name, &entry)
+	if err != nil {
+		entry.Error = err.Error()
+
+		// The status and a part of the rows are already sent, so the error can only be logged.
+		if rw.written {
+			log.Printf("writing the rows of query '%s': %s", name, err)
+			return
+		}
+
+		var he *httpError
+		if errors.As(err, &he) {
+			entry.Status = he.status
+		} else {
+			entry.Status = http.StatusBadGateway
+		}
+		writeError(w, err)
+	}
+}
+
+// responseWriter records whether the response has started, after which the status cannot change.
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (s *server) execQuery(w http.ResponseWriter, r *http.Request, name string, entry *auditEntry) error {
+	if r.Method != http.MethodGet {
+		return errorf(http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+	}
+
+	caller, err := s.authenticate(r)
+	if err != nil {
+		return err
+	}
+	entry.Caller = caller
+
+	if ok, wait := s.limiter.allow(caller); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		return errorf(http.StatusTooManyRequests, "rate limit exceeded, retry in %s", wait.Round(time.Second))
+	}
+
+	q, ok := s.library.Get(name)
+	if !ok {
+		return errorf(http.StatusNotFound, "query '%s' not found", name)
+	}
+
+	params := r.URL.Query()
+	format, err := responseFormat(params.Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		return err
+	}
+
+	text, key, err := bindParameters(q, params)
+	if err != nil {
+		return err
+	}
+
+	rows, cached := s.cache.get(key)
+	if !cached {
+		ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+		defer cancel()
+
+		rows, err = s.backend.ExecRows(ctx, text, q.ExecOptions(nil))
+		if err != nil {
+			return err
+		}
+
+		s.cache.put(key, rows)
+	}
+
+	entry.Rows = len(rows)
+	entry.Cached = cached
+	return writeRows(w, format, rows)
+}
+
+// authenticate returns the caller name by the API key. When there are no callers in the config,
+// the caller is identified by the IP address.
+func (s *server) authenticate(r *http.Request) (string, error) {
+	if len(s.callers) == 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return host, nil
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			key = strings.TrimSpace(auth[len("Bearer "):])
+		}
+	}
+
+	if key != "" {
+		for _, c := range s.callers {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(c.Key)) == 1 {
+				return c.Name, nil
+			}
+		}
+	}
+
+	return "", errorf(http.StatusUnauthorized, "a valid API key is required")
+}
+
+// bindParameters returns the query text with the let statements of the parameters, and the cache key.
+func bindParameters(q *lib.Query, values url.Values) (string, string, error) {
+	params := lib.Params{}
+	for name, v := range values {
+		if reservedParameters[name] {
+			continue
+		}
+
+		if len(v) > 1 {
+			return "", "", errorf(http.StatusBadRequest, "parameter '%s' is given more than once", name)
+		}
+		params[name] = v[0]
+	}
+
+	text, err := q.Bind(params)
+	if err != nil {
+		return "", "", errorf(http.StatusBadRequest, "%s", err)
+	}
+
+	// The bound parameters are in the order of the query, so the text identifies the results.
+	return text, q.Name + "\n" + text, nil
+}
+
+// responseFormat returns the output format from the format parameter or the Accept header.
+func responseFormat(format string, accept string) (output.Format, error) {
+	if format == "" {
+		if strings.Contains(accept, "text/csv") {
+			return output.FormatCSV, nil
+		}
+		return output.FormatJSON, nil
+	}
+
+	switch f, err := output.ParseFormat(format); {
+	case err != nil:
+		return "", errorf(http.StatusBadRequest, "%s", err)
+	case f == output.FormatJSON || f == output.FormatCSV:
+		return f, nil
+	default:
+		return "", errorf(http.StatusBadRequest, "unsupported format '%s', expected json or csv", format)
+	}
+}
+
+func writeRows(w http.ResponseWriter, format output.Format, rows []rg.Row) error {
+	if format == output.FormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	enc, err := output.NewEncoder(w, format, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	return enc.Close()
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// flattenValues returns the URL query parameters for the audit log, with the repeated values joined.
+func flattenValues(values url.Values) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := make(map[string]string, len(values))
+	for name, v := range values {
+		sorted := append([]string(nil), v...)
+		sort.Strings(sorted)
+		result[name] = strings.Join(sorted, ",")
+	}
+
+	return result
+}
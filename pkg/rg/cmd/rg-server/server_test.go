@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBackend returns the same rows for all queries and records the queries.
+type fakeBackend struct {
+	rows []rg.Row
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (b *fakeBackend) ExecRows(_ context.Context, query string, _ *rg.ExecOptions) ([]rg.Row, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.queries = append(b.queries, query)
+	return b.rows, nil
+}
+
+const testConfig = `
+rateLimit:
+  requestsPerMinute: 60
+  burst: 3
+callers:
+  - name: portal
+    key: secret
+queries:
+  - name: public-ips
+    description: Public IP addresses in a location.
+    parameters:
+      - name: region
+        required: true
+      - name: top
+        type: long
+    query: |
+      resources
+      | where type =~ 'microsoft.network/publicipaddresses' and location =~ region
+      | project name, ip = tostring(properties.ipAddress)
+`
+
+// newTestServer returns the server with the test config and the buffer of its audit log.
+func newTestServer(t *testing.T, backend backend) (*server, *bytes.Buffer) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rg-server.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var audit bytes.Buffer
+	return newServer(cfg, backend, newAuditLog(&audit)), &audit
+}
+
+func get(s *server, target string, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if key != "" {
+		r.Header.Set("X-API-Key", key)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+var ipColumns = []rg.Column{{Name: "name", Type: rg.ColumnDataTypeString}, {Name: "ip", Type: rg.ColumnDataTypeString}}
+
+func TestServerQuery(t *testing.T) {
+	backend := &fakeBackend{rows: []rg.Row{rg.NewRow(ipColumns, []any{"a", "10.0.0.1"})}}
+	s, audit := newTestServer(t, backend)
+
+	w := get(s, "/queries/public-ips?region=westeurope", "secret")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got status %d, %s", w.Code, w.Body)
+	}
+
+	var rows []map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &rows); err != nil || len(rows) != 1 || rows[0]["ip"] != "10.0.0.1" {
+		t.Errorf("got rows %s, %v", w.Body, err)
+	}
+
+	if len(backend.queries) != 1 || !strings.HasPrefix(backend.queries[0], "let region = 'westeurope';\nlet top = long(null);\nresources") {
+		t.Errorf("got queries %q", backend.queries)
+	}
+
+	// The same parameters are served from the cache, as CSV this time.
+	w = get(s, "/queries/public-ips?region=westeurope&format=csv", "secret")
+	if w.Code != http.StatusOK || w.Body.String() != "name,ip\na,10.0.0.1\n" {
+		t.Errorf("got status %d, %q", w.Code, w.Body)
+	}
+
+	if len(backend.queries) != 1 {
+		t.Errorf("got %d queries, want the second request cached", len(backend.queries))
+	}
+
+	var entries []auditEntry
+	for _, line := range strings.Split(strings.TrimSpace(audit.String()), "\n") {
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) != 2 || entries[0].Caller != "portal" || entries[0].Cached || !entries[1].Cached || entries[1].Rows != 1 {
+		t.Errorf("unexpected audit entries %+v", entries)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s, _ := newTestServer(t, &fakeBackend{})
+	s.limiter.rate = 0
+
+	tests := []struct {
+		target string
+		key    string
+		status int
+	}{
+		{"/queries/public-ips?region=westeurope", "", http.StatusUnauthorized},
+		{"/queries/public-ips?region=westeurope", "wrong", http.StatusUnauthorized},
+		{"/queries/other", "secret", http.StatusNotFound},
+		{"/queries/public-ips", "secret", http.StatusBadRequest},
+		{"/queries/public-ips?region=westeurope&top=many", "secret", http.StatusBadRequest},
+		{"/queries/public-ips?region=westeurope&region=northeurope", "secret", http.StatusBadRequest},
+		{"/queries/public-ips?region=westeurope&format=xml", "secret", http.StatusBadRequest},
+		{"/other", "secret", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if w := get(s, tt.target, tt.key); w.Code != tt.status {
+			t.Errorf("GET %s: got status %d, want %d: %s", tt.target, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+func TestServerWriteError(t *testing.T) {
+	// The first row fills the buffer of the encoder, so the response has started when the second one fails.
+	backend := &fakeBackend{rows: []rg.Row{
+		rg.NewRow(ipColumns, []any{strings.Repeat("a", 8192), "10.0.0.1"}),
+		rg.NewRow(ipColumns, []any{"b", math.NaN()}),
+	}}
+	s, audit := newTestServer(t, backend)
+
+	w := get(s, "/queries/public-ips?region=westeurope", "secret")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("got status %d and the error in the body, want the rows cut short", w.Code)
+	}
+
+	var e auditEntry
+	if err := json.Unmarshal(audit.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Status != http.StatusOK || e.Error == "" {
+		t.Errorf("got audit entry %+v, want the error with the status sent", e)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	s, _ := newTestServer(t, &fakeBackend{})
+
+	for i := 0; i < 3; i++ {
+		if w := get(s, "/queries/public-ips?region=westeurope", "secret"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want the burst allowed", i+1, w.Code)
+		}
+	}
+
+	w := get(s, "/queries/public-ips?region=westeurope", "secret")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("got status %d and Retry-After %q, want 429 and 1", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestServerList(t *testing.T) {
+	s, _ := newTestServer(t, &fakeBackend{})
+
+	w := get(s, "/queries", "secret")
+	var queries []struct {
+		Name       string `json:"name"`
+		Parameters []struct {
+			Name     string `json:"name"`
+			Type     string `json:"type"`
+			Required bool   `json:"required"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &queries); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queries) != 1 || queries[0].Name != "public-ips" || len(queries[0].Parameters) != 2 || queries[0].Parameters[1].Type != "long" {
+		t.Errorf("got %s", w.Body)
+	}
+}
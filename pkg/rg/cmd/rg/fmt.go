@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/kql"
+	"io"
+	"os"
+)
+
+// runFmt formats the queries in the files, or the query from stdin when no files are given.
+func runFmt(args []string, stdin io.Reader, stdout io.Writer) error {
+	var write, list bool
+
+	fs := flag.NewFlagSet("rg fmt", flag.ContinueOnError)
+	fs.BoolVar(&write, "w", false, "write the result to the files instead of stdout")
+	fs.BoolVar(&list, "l", false, "list the files whose formatting differs")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: rg fmt [flags] [file.kql ...]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		if write || list {
+			return errors.New("-w and -l need files")
+		}
+
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := kql.Format(string(data))
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(stdout, formatted)
+		return err
+	}
+
+	for _, file := range fs.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := kql.Format(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		changed := !bytes.Equal(data, []byte(formatted))
+		if list && changed {
+			_, _ = fmt.Fprintln(stdout, file)
+		}
+
+		switch {
+		case write && changed:
+			if err := os.WriteFile(file, []byte(formatted), 0o644); err != nil {
+				return err
+			}
+		case !write && !list:
+			if _, err := io.WriteString(stdout, formatted); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/kql"
+	"io"
+)
+
+// runLint checks the query for syntax errors and common pitfalls without running it.
+func runLint(args []string, stdin io.Reader, stdout io.Writer) error {
+	var file string
+
+	fs := flag.NewFlagSet("rg lint", flag.ContinueOnError)
+	fs.StringVar(&file, "f", "", "read the query from `file`, use - for stdin")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: rg lint [flags] [query]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	query, err := readQuery(fs.Args(), file, stdin)
+	if err != nil {
+		return err
+	}
+
+	diagnostics, err := kql.Lint(query)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range diagnostics {
+		_, _ = fmt.Fprintln(stdout, d)
+	}
+
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(diagnostics))
+	}
+
+	return nil
+}
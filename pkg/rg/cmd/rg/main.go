@@ -0,0 +1,312 @@
+// Command rg runs Azure Resource Graph queries and writes results to stdout.
+//
+// Usage:
+//
+//	rg [flags] [query]
+//	rg snapshot [flags] [query]
+//	rg diff [flags] old.json new.json
+//	rg queries [-lib dir]
+//	rg lint [flags] [query]
+//	rg fmt [-w] [-l] [file.kql ...]
+//
+// The query text is taken from the command line arguments, from the file given
+// with -f, or from stdin when neither is given (or when the argument is "-").
+// With -q, the query is the saved query by name from the library directory given with -lib
+// or the RG_LIBRARY environment variable, and its parameters are given with -p.
+//
+// The syntax of the query is checked before it is sent, unless -nocheck is given. The lint
+// command also reports common pitfalls, like case-sensitive comparisons of resource types.
+// The fmt command formats the query files, or the query from stdin, with each operator on its
+// own line; -w rewrites the files and -l lists the files which are not formatted.
+//
+// Examples:
+//
+//	rg 'resources | project name, type | order by name asc'
+//	rg -s 00000000-0000-0000-0000-000000000000 -first 10 -o jsonl 'resources'
+//	rg -f query.kql -o csv > result.csv
+//	rg -lib ./queries -q public-ips -p region=westeurope
+//
+// The snapshot command saves the rows of the query keyed by an ID column, and the diff
+// command prints the rows which were added, removed or modified between two snapshots:
+//
+//	rg snapshot -out yesterday.json 'resources | project id, name, sku, tags'
+//	rg snapshot -out today.json 'resources | project id, name, sku, tags'
+//	rg diff yesterday.json today.json
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/kql"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/lib"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/output"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+)
+
+// listFlag is a flag which accumulates comma-separated values and can be repeated.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rg: ")
+
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "snapshot":
+			return runSnapshot(args[1:], stdin, stdout)
+		case "diff":
+			return runDiff(args[1:], stdout)
+		case "queries":
+			return runQueries(args[1:], stdout)
+		case "lint":
+			return runLint(args[1:], stdin, stdout)
+		case "fmt":
+			return runFmt(args[1:], stdin, stdout)
+		}
+	}
+
+	return runQuery(args, stdin, stdout)
+}
+
+// client runs the queries, nil for the shared default client. The tests set it to run against a fake.
+var client *rg.Client
+
+// queryFlags are the flags of the commands which run a query.
+type queryFlags struct {
+	subscriptions    listFlag
+	managementGroups listFlag
+	file             string
+	first            int
+	skip             int
+	verbose          bool
+	noCheck          bool
+	stable           bool
+	orderKey         string
+	library          string
+	name             string
+	params           paramsFlag
+}
+
+// paramsFlag is a flag which accumulates the parameters of saved queries given as name=value.
+type paramsFlag lib.Params
+
+func (p *paramsFlag) String() string {
+	var pairs []string
+	for name, value := range *p {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", name, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (p *paramsFlag) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("the parameter must be name=value, got '%s'", value)
+	}
+
+	if *p == nil {
+		*p = paramsFlag{}
+	}
+	(*p)[strings.TrimSpace(name)] = v
+	return nil
+}
+
+func (q *queryFlags) register(fs *flag.FlagSet) {
+	fs.Var(&q.subscriptions, "s", "subscription `ids` to query, comma-separated or repeated")
+	fs.Var(&q.managementGroups, "m", "management group `names` to query, comma-separated or repeated")
+	fs.StringVar(&q.file, "f", "", "read the query from `file`, use - for stdin")
+	fs.IntVar(&q.first, "first", 0, "return at most `n` rows, 0 for all rows")
+	fs.IntVar(&q.skip, "skip", 0, "skip the first `n` rows")
+	fs.BoolVar(&q.verbose, "v", false, "log paging progress to stderr")
+	fs.BoolVar(&q.noCheck, "nocheck", false, "don't check the query syntax before sending it")
+	fs.BoolVar(&q.stable, "stable", false, "sort the results by -orderkey unless the query sorts them, and drop duplicate rows across pages")
+	fs.StringVar(&q.orderKey, "orderkey", "id", "the `column` of -stable")
+	fs.StringVar(&q.library, "lib", os.Getenv("RG_LIBRARY"), "the saved query library `dir`, the default is $RG_LIBRARY")
+	fs.StringVar(&q.name, "q", "", "run the saved query `name` from the library")
+	fs.Var(&q.params, "p", "the saved query parameter as `name=value`, can be repeated")
+}
+
+// query returns the query text and the options to run it with, either from the arguments, the file
+// or stdin, or the saved query from the library with its parameters bound.
+func (q *queryFlags) query(args []string, stdin io.Reader) (string, *rg.ExecOptions, error) {
+	query, options, err := q.readQuery(args, stdin)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !q.noCheck {
+		if err := kql.Check(query); err != nil {
+			return "", nil, fmt.Errorf("the query has a syntax error, use -nocheck to send it anyway: %w", err)
+		}
+	}
+
+	return query, options, nil
+}
+
+func (q *queryFlags) readQuery(args []string, stdin io.Reader) (string, *rg.ExecOptions, error) {
+	if q.name == "" {
+		if len(q.params) > 0 {
+			return "", nil, errors.New("the parameters need a saved query given with -q")
+		}
+
+		query, err := readQuery(args, q.file, stdin)
+		return query, q.execOptions(), err
+	}
+
+	if len(args) > 0 || q.file != "" {
+		return "", nil, errors.New("the query must be given either as an argument, with -f or with -q, not several")
+	}
+
+	library, err := loadLibrary(q.library)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return library.Bind(q.name, lib.Params(q.params), q.execOptions())
+}
+
+// execOptions returns the options to run the query with.
+func (q *queryFlags) execOptions() *rg.ExecOptions {
+	return &rg.ExecOptions{
+		Client:            client,
+		Subscriptions:     q.subscriptions,
+		ManagementGroups:  q.managementGroups,
+		First:             q.first,
+		Skip:              q.skip,
+		EnsureStableOrder: q.stable,
+		OrderKey:          q.orderKey,
+	}
+}
+
+// setupLog discards the log unless verbose, and returns the function which restores it.
+func (q *queryFlags) setupLog() func() {
+	if q.verbose {
+		return func() {}
+	}
+
+	// The pagers log every page they fetch.
+	log.SetOutput(io.Discard)
+	return func() {
+		log.SetOutput(os.Stderr)
+	}
+}
+
+func runQuery(args []string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		flags  queryFlags
+		format string
+	)
+
+	fs := flag.NewFlagSet("rg", flag.ContinueOnError)
+	flags.register(fs)
+	fs.StringVar(&format, "o", "json", "output `format`: "+formatNames())
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage:\n  rg [flags] [query]\n  rg snapshot [flags] [query]\n  rg diff [flags] old.json new.json\n  rg queries [-lib dir]\n  rg lint [flags] [query]\n  rg fmt [-w] [-l] [file.kql ...]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	defer flags.setupLog()()
+
+	query, options, err := flags.query(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	outputFormat, err := output.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	enc, err := output.NewEncoder(stdout, outputFormat, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Rows keep the columns in the order the query projects them.
+	err = rg.StreamRows(ctx, query, options, func(row rg.Row) error {
+		return enc.Encode(row)
+	})
+	if closeErr := enc.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// formatNames returns the comma-separated list of supported output formats.
+func formatNames() string {
+	var names []string
+	for _, f := range output.PossibleFormatValues() {
+		names = append(names, string(f))
+	}
+	return strings.Join(names, ", ")
+}
+
+// readQuery returns the query text from the arguments, the file or stdin, in this order.
+func readQuery(args []string, file string, stdin io.Reader) (string, error) {
+	if file != "" && len(args) > 0 {
+		return "", errors.New("the query must be given either as an argument or with -f, not both")
+	}
+
+	var data []byte
+	var err error
+	switch {
+	case file == "-" || (file == "" && (len(args) == 0 || (len(args) == 1 && args[0] == "-"))):
+		data, err = io.ReadAll(stdin)
+	case file != "":
+		data, err = os.ReadFile(file)
+	default:
+		data = []byte(strings.Join(args, " "))
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	query := strings.TrimSpace(string(data))
+	if query == "" {
+		return "", errors.New("the query is empty")
+	}
+
+	return query, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestListFlag(t *testing.T) {
+	var l listFlag
+	for _, value := range []string{"a, b", "", "c,"} {
+		if err := l.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual([]string(l), want) || l.String() != "a,b,c" {
+		t.Errorf("got %q, want %q", l, want)
+	}
+}
+
+func TestParamsFlag(t *testing.T) {
+	var p paramsFlag
+	for _, value := range []string{"region=westeurope", " top =5", "filter=a=b"} {
+		if err := p.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := p.String(); got != "filter=a=b,region=westeurope,top=5" {
+		t.Errorf("got %s", got)
+	}
+
+	for _, value := range []string{"region", "=westeurope"} {
+		if err := p.Set(value); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+// parseQueryFlags parses the arguments with the flags of the commands which run a query,
+// and returns the query and the options to run it with.
+func parseQueryFlags(t *testing.T, args []string, stdin string) (string, *queryFlags, error) {
+	t.Helper()
+
+	var flags queryFlags
+	fs := flag.NewFlagSet("rg", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	query, _, err := flags.query(fs.Args(), strings.NewReader(stdin))
+	return query, &flags, err
+}
+
+func TestQueryFlags(t *testing.T) {
+	query, flags, err := parseQueryFlags(t, []string{
+		"-s", "a,b", "-s", "c", "-m", "mg", "-first", "5", "-skip", "2", "-stable", "-orderkey", "name",
+		"resources", "|", "project name",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query != "resources | project name" {
+		t.Errorf("got query %q", query)
+	}
+
+	options := flags.execOptions()
+	if !reflect.DeepEqual(options.Subscriptions, []string{"a", "b", "c"}) || !reflect.DeepEqual(options.ManagementGroups, []string{"mg"}) {
+		t.Errorf("got scopes %v %v", options.Subscriptions, options.ManagementGroups)
+	}
+
+	if options.First != 5 || options.Skip != 2 || !options.EnsureStableOrder || options.OrderKey != "name" {
+		t.Errorf("unexpected options %+v", options)
+	}
+}
+
+func TestQueryFlagsSources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "query.kql")
+	if err := os.WriteFile(file, []byte("resources | project id\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args  []string
+		stdin string
+		want  string
+	}{
+		{[]string{"resources"}, "", "resources"},
+		{[]string{"-f", file}, "", "resources | project id"},
+		{nil, " resources | take 1\n", "resources | take 1"},
+		{[]string{"-"}, "resources | take 1", "resources | take 1"},
+		{[]string{"-f", "-"}, "resources | take 1", "resources | take 1"},
+		{[]string{"-nocheck", "resources | where"}, "", "resources | where"},
+	}
+
+	for _, tt := range tests {
+		got, _, err := parseQueryFlags(t, tt.args, tt.stdin)
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestQueryFlagsErrors(t *testing.T) {
+	tests := []struct {
+		args  []string
+		stdin string
+		want  string
+	}{
+		{[]string{"-f", "query.kql", "resources"}, "", "not both"},
+		{nil, " \n", "the query is empty"},
+		{[]string{"resources | where"}, "", "use -nocheck"},
+		{[]string{"-p", "region=westeurope", "resources"}, "", "need a saved query"},
+		{[]string{"-q", "public-ips", "resources"}, "", "not several"},
+		{[]string{"-lib", "", "-q", "public-ips"}, "", "must be given with -lib"},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := parseQueryFlags(t, tt.args, tt.stdin); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got error %v, want %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestQueryFlagsLibrary(t *testing.T) {
+	dir := t.TempDir()
+	text := "// ---\n// parameters:\n//   - name: region\n//     required: true\n// ---\nresources | where location =~ region\n"
+	if err := os.WriteFile(filepath.Join(dir, "public-ips.kql"), []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	query, _, err := parseQueryFlags(t, []string{"-lib", dir, "-q", "public-ips", "-p", "region=westeurope"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "let region = 'westeurope';\nresources | where location =~ region"; query != want {
+		t.Errorf("got query %q, want %q", query, want)
+	}
+
+	if _, _, err := parseQueryFlags(t, []string{"-lib", dir, "-q", "public-ips"}, ""); err == nil {
+		t.Error("the query ran without the required parameter, want an error")
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`{
+		"columns": [{"name": "name", "type": "string"}, {"name": "sku", "type": "object"}],
+		"rows": [["a", {"name": "S1"}], ["b", null]]
+	}`}}
+	client = rgtest.NewClient(t, server, nil)
+	defer func() {
+		client = nil
+	}()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", "[\n{\"name\":\"a\",\"sku\":{\"name\":\"S1\"}},\n{\"name\":\"b\",\"sku\":null}\n]\n"},
+		{"jsonl", "{\"name\":\"a\",\"sku\":{\"name\":\"S1\"}}\n{\"name\":\"b\",\"sku\":null}\n"},
+		{"csv", "name,sku.name\na,S1\nb,\n"},
+		{"TSV", "name\tsku.name\na\tS1\nb\t\n"},
+		{"md", "| name | sku.name | sku |\n| --- | --- | --- |\n| a | S1 |  |\n| b |  |  |\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		if err := run([]string{"-o", tt.format, "resources | project name, sku"}, strings.NewReader(""), &stdout); err != nil {
+			t.Errorf("-o %s: %v", tt.format, err)
+			continue
+		}
+
+		if got := stdout.String(); got != tt.want {
+			t.Errorf("-o %s: got %q, want %q", tt.format, got, tt.want)
+		}
+	}
+
+	// The rows are requested in table format, so that they keep the order of the columns.
+	if format := server.Requests()[0].Options.ResultFormat; format != "table" {
+		t.Errorf("got result format '%s', want table", format)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := run([]string{"-o", "xml", "resources"}, strings.NewReader(""), &stdout); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("got error %v, want the unknown output format", err)
+	}
+
+	if err := run([]string{"-unknown"}, strings.NewReader(""), io.Discard); err == nil {
+		t.Error("run succeeded with an unknown flag, want an error")
+	}
+
+	if err := run([]string{"-h"}, strings.NewReader(""), io.Discard); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("got error %v, want %v", err, flag.ErrHelp)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/lib"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// loadLibrary loads the saved query library from the directory.
+func loadLibrary(dir string) (*lib.Library, error) {
+	if dir == "" {
+		return nil, errors.New("the saved query library must be given with -lib or $RG_LIBRARY")
+	}
+
+	library := lib.New()
+	if err := library.LoadDir(dir); err != nil {
+		return nil, err
+	}
+
+	return library, nil
+}
+
+// runQueries writes the saved queries of the library with their parameters.
+func runQueries(args []string, stdout io.Writer) error {
+	var dir string
+
+	fs := flag.NewFlagSet("rg queries", flag.ContinueOnError)
+	fs.StringVar(&dir, "lib", os.Getenv("RG_LIBRARY"), "the saved query library `dir`, the default is $RG_LIBRARY")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: rg queries [-lib dir]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	library, err := loadLibrary(dir)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, q := range library.Queries() {
+		var params []string
+		for _, p := range q.Parameters {
+			param := p.Name + ":" + string(p.Type)
+			if !p.Required {
+				param = "[" + param + "]"
+			}
+			params = append(params, param)
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", q.Name, strings.Join(params, " "), q.Description)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/snapshot"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// runSnapshot runs the query and writes the snapshot of its results to the file or stdout.
+func runSnapshot(args []string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		flags queryFlags
+		key   string
+		out   string
+	)
+
+	fs := flag.NewFlagSet("rg snapshot", flag.ContinueOnError)
+	flags.register(fs)
+	fs.StringVar(&key, "key", snapshot.DefaultKey, "the `column` which identifies the rows")
+	fs.StringVar(&out, "out", "", "write the snapshot to `file` instead of stdout")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: rg snapshot [flags] [query]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	defer flags.setupLog()()
+
+	query, options, err := flags.query(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	s, err := snapshot.Take(ctx, query, key, options)
+	if err != nil {
+		return err
+	}
+
+	if out != "" {
+		return s.Save(out)
+	}
+
+	return s.Write(stdout)
+}
+
+// runDiff compares two snapshot files and writes the diff.
+func runDiff(args []string, stdout io.Writer) error {
+	var format string
+
+	fs := flag.NewFlagSet("rg diff", flag.ContinueOnError)
+	fs.StringVar(&format, "o", "text", "output `format`: text, json")
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: rg diff [flags] old.json new.json\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return errors.New("diff needs the old and the new snapshot files")
+	}
+
+	old, err := snapshot.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	current, err := snapshot.Load(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diff, err := snapshot.Compare(old, current)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "text":
+		return diff.WriteText(stdout)
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	default:
+		return fmt.Errorf("unknown output format '%s', expected text or json", format)
+	}
+}
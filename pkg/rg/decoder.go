@@ -0,0 +1,56 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"bytes"
+	"encoding/json"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/armresourcegraph2"
+)
+
+// Decoder unmarshals the JSON returned by Azure Resource Graph into the result types.
+// The backends differ in subtle ways, e.g. how field names are matched, how numbers are
+// decoded into interface values, and which json.Unmarshaler methods are called.
+type Decoder interface {
+	Unmarshal(data []byte, v any) error
+}
+
+// DecoderFunc is an adapter to use a function as a [Decoder], e.g. to use json v2:
+//
+//	rg.DecoderFunc(func(data []byte, v any) error {
+//		return jsonv2.Unmarshal(data, v)
+//	})
+type DecoderFunc func(data []byte, v any) error
+
+// Unmarshal calls f(data, v).
+func (f DecoderFunc) Unmarshal(data []byte, v any) error {
+	return f(data, v)
+}
+
+var (
+	// JsoniterDecoder is the jsoniter default configuration. It is the fastest and the default decoder.
+	// It matches field names case-insensitively and decodes numbers into interface values as float64.
+	JsoniterDecoder Decoder = jsoniter.ConfigDefault
+
+	// JsoniterCompatibleDecoder is jsoniter configured to be compatible with encoding/json.
+	JsoniterCompatibleDecoder Decoder = jsoniter.ConfigCompatibleWithStandardLibrary
+
+	// LenientDecoder is jsoniter with tolerant decoding of datetime, timespan and numeric values,
+	// see [ExecOptions.Lenient].
+	LenientDecoder Decoder = armresourcegraph2.LenientJSON
+
+	// StdDecoder is the standard library encoding/json.
+	StdDecoder Decoder = DecoderFunc(json.Unmarshal)
+
+	// StdUseNumberDecoder is the standard library encoding/json which decodes numbers into interface
+	// values as [json.Number], so that large integers keep their precision.
+	StdUseNumberDecoder Decoder = DecoderFunc(unmarshalUseNumber)
+)
+
+func unmarshalUseNumber(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
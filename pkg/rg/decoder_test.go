@@ -0,0 +1,110 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"testing"
+)
+
+// decoders are the decoders of the package by name.
+var decoders = map[string]rg.Decoder{
+	"JsoniterDecoder":           rg.JsoniterDecoder,
+	"JsoniterCompatibleDecoder": rg.JsoniterCompatibleDecoder,
+	"LenientDecoder":            rg.LenientDecoder,
+	"StdDecoder":                rg.StdDecoder,
+	"StdUseNumberDecoder":       rg.StdUseNumberDecoder,
+}
+
+func TestDecoders(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"name": "a", "size": 1}, {"name": "b", "size": 9007199254740993}]`,
+	}}
+	tableServer := &rgtest.Server{Pages: []string{
+		`{"columns": [{"name": "name", "type": "string"}, {"name": "size", "type": "integer"}], "rows": [["a", 1], ["b", 9007199254740993]]}`,
+	}}
+
+	for name, decoder := range decoders {
+		client := rgtest.NewClient(t, server, &rg.ClientOptions{Decoder: decoder})
+		items, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if len(items) != 2 || items[0] != (record{"a", 1}) || items[1].Size != 9007199254740993 {
+			t.Errorf("%s: got %v", name, items)
+		}
+
+		// The numbers of dynamic values are float64, except with json.Number.
+		rows := decodeTable(t, rgtest.NewClient(t, tableServer, &rg.ClientOptions{Decoder: decoder}))
+		size := rows[1][1]
+		if name == "StdUseNumberDecoder" {
+			if size != json.Number("9007199254740993") {
+				t.Errorf("%s: got size %v of %T, want json.Number keeping the precision", name, size, size)
+			}
+		} else if _, ok := size.(float64); !ok || rows[0][0] != "a" {
+			t.Errorf("%s: got rows %v, want float64 numbers", name, rows)
+		}
+	}
+}
+
+// decodeTable returns the rows of the first page of the table-format results decoded by the client.
+func decodeTable(t *testing.T, client *rg.Client) [][]any {
+	t.Helper()
+
+	pager, err := rg.NewPager(context.Background(), "resources", &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := pager.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows [][]any
+	if err := pager.Decode(page, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	return rows
+}
+
+func TestDecoderErrors(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`[{"name": "a", "size": "large"}]`}}
+
+	for name, decoder := range decoders {
+		client := rgtest.NewClient(t, server, &rg.ClientOptions{Decoder: decoder})
+		if _, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client}); err == nil {
+			t.Errorf("%s: Exec succeeded, want an error decoding the size", name)
+		}
+	}
+
+	// The lenient decoding takes precedence over the decoder of the client.
+	lenientServer := &rgtest.Server{Pages: []string{`[{"name": "a", "size": "42"}]`}}
+	client := rgtest.NewClient(t, lenientServer, &rg.ClientOptions{Decoder: rg.StdDecoder})
+	items, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client, Lenient: true})
+	if err != nil || len(items) != 1 || items[0].Size != 42 {
+		t.Errorf("got %v, %v, want the size decoded leniently", items, err)
+	}
+
+	// The errors of custom decoders are returned with the type being decoded.
+	failed := errors.New("failed")
+	client = rgtest.NewClient(t, server, &rg.ClientOptions{Decoder: rg.DecoderFunc(func([]byte, any) error { return failed })})
+	_, err = rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client})
+	if !errors.Is(err, failed) || err.Error() != "unmarshalling type []rg_test.record: failed" {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+
+	tableServer := &rgtest.Server{Pages: []string{`{"columns": [{"name": "name", "type": "string"}], "rows": {"name": "a"}}`}}
+	client = rgtest.NewClient(t, tableServer, nil)
+	if _, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client}); err == nil {
+		t.Error("Exec succeeded with table rows which are not an array, want an error")
+	}
+}
@@ -0,0 +1,353 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The values of the "rg" struct tag of the fields filled by [ExecOptions.Enrich].
+const (
+	tagSubscriptionName    = "subscriptionName"
+	tagManagementGroupPath = "managementGroupPath"
+	tagResourceGroupTags   = "resourceGroupTags"
+)
+
+// defaultLookupTTL is the default [ClientOptions.LookupTTL].
+const defaultLookupTTL = time.Hour
+
+// resourceGroupsQuery returns the tags of the resource groups.
+const resourceGroupsQuery = `resourcecontainers
+| where type =~ 'microsoft.resources/subscriptions/resourcegroups'
+| project subscriptionId, resourceGroup, tags
+| order by subscriptionId asc, resourceGroup asc`
+
+// resourceGroupRecord is a row of resourceGroupsQuery.
+type resourceGroupRecord struct {
+	SubscriptionID string            `json:"subscriptionId"`
+	ResourceGroup  string            `json:"resourceGroup"`
+	Tags           map[string]string `json:"tags"`
+}
+
+// lookups is the cache of the subscriptions and the resource groups of a client.
+type lookups struct {
+	mu  sync.Mutex
+	ttl time.Duration
+
+	subscriptions       map[string]*Subscription
+	subscriptionsLoaded time.Time
+
+	// resourceGroups are the tags of the resource groups by subscription and resource group in lower case.
+	resourceGroups       map[string]map[string]map[string]string
+	resourceGroupsLoaded map[string]time.Time
+}
+
+func newLookups(ttl time.Duration) *lookups {
+	if ttl <= 0 {
+		ttl = defaultLookupTTL
+	}
+
+	return &lookups{
+		ttl:                  ttl,
+		resourceGroups:       map[string]map[string]map[string]string{},
+		resourceGroupsLoaded: map[string]time.Time{},
+	}
+}
+
+// subscription returns the subscription by ID, loading all subscriptions when the cache is stale.
+// It returns nil for the subscriptions which the client can't see.
+func (l *lookups) subscription(ctx context.Context, client *Client, id string) (*Subscription, error) {
+	if time.Since(l.subscriptionsLoaded) > l.ttl {
+		list, err := Subscriptions(ctx, nil, &ExecOptions{Client: client})
+		if err != nil {
+			return nil, fmt.Errorf("looking up subscriptions: %w", err)
+		}
+
+		subscriptions := make(map[string]*Subscription, len(list))
+		for i := range list {
+			subscriptions[strings.ToLower(list[i].ID)] = &list[i]
+		}
+
+		l.subscriptions = subscriptions
+		l.subscriptionsLoaded = time.Now()
+	}
+
+	return l.subscriptions[strings.ToLower(id)], nil
+}
+
+// loadResourceGroups loads the resource groups of the subscriptions which are not cached or are stale.
+func (l *lookups) loadResourceGroups(ctx context.Context, client *Client, subscriptions []string) error {
+	var stale []string
+	for _, id := range subscriptions {
+		if time.Since(l.resourceGroupsLoaded[id]) > l.ttl {
+			stale = append(stale, id)
+		}
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	loaded := map[string]map[string]map[string]string{}
+	for _, id := range stale {
+		loaded[id] = map[string]map[string]string{}
+	}
+
+	err := Stream(ctx, resourceGroupsQuery, &ExecOptions{Client: client, Subscriptions: stale}, func(row resourceGroupRecord) error {
+		if groups, ok := loaded[strings.ToLower(row.SubscriptionID)]; ok {
+			groups[strings.ToLower(row.ResourceGroup)] = row.Tags
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("looking up resource groups: %w", err)
+	}
+
+	now := time.Now()
+	for id, groups := range loaded {
+		l.resourceGroups[id] = groups
+		l.resourceGroupsLoaded[id] = now
+	}
+
+	return nil
+}
+
+// enrichPlan are the fields of a struct type which [enrich] reads and fills.
+type enrichPlan struct {
+	subscriptionID []int
+	resourceGroup  []int
+	id             []int
+	targets        []enrichTarget
+}
+
+// enrichTarget is a field tagged with the "rg" tag.
+type enrichTarget struct {
+	index []int
+	tag   string
+}
+
+func (p *enrichPlan) needs(tag string) bool {
+	for _, t := range p.targets {
+		if t.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// enrichPlans are the plans by struct type.
+var enrichPlans sync.Map
+
+var (
+	stringType     = reflect.TypeOf("")
+	stringsType    = reflect.TypeOf([]string(nil))
+	stringMapType  = reflect.TypeOf(map[string]string(nil))
+	resourceIDType = reflect.TypeOf(ResourceID{})
+)
+
+// enrichPlanFor returns the plan of the struct type.
+func enrichPlanFor(t reflect.Type) (*enrichPlan, error) {
+	if plan, ok := enrichPlans.Load(t); ok {
+		return plan.(*enrichPlan), nil
+	}
+
+	plan := &enrichPlan{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		if tag, ok := f.Tag.Lookup("rg"); ok {
+			var valid bool
+			switch tag {
+			case tagSubscriptionName:
+				valid = f.Type == stringType
+			case tagManagementGroupPath:
+				valid = f.Type == stringType || f.Type == stringsType
+			case tagResourceGroupTags:
+				valid = f.Type == stringMapType
+			default:
+				return nil, fmt.Errorf("field '%s' of %v has unknown tag rg:\"%s\"", f.Name, t, tag)
+			}
+
+			if !valid {
+				return nil, fmt.Errorf("field '%s' of %v tagged rg:\"%s\" has unsupported type %v", f.Name, t, tag, f.Type)
+			}
+
+			plan.targets = append(plan.targets, enrichTarget{index: f.Index, tag: tag})
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		switch {
+		case strings.EqualFold(name, "subscriptionId") && f.Type == stringType:
+			plan.subscriptionID = f.Index
+		case strings.EqualFold(name, "resourceGroup") && f.Type == stringType:
+			plan.resourceGroup = f.Index
+		case strings.EqualFold(name, "id") && (f.Type == stringType || f.Type == resourceIDType):
+			plan.id = f.Index
+		}
+	}
+
+	if len(plan.targets) > 0 && plan.subscriptionID == nil && plan.id == nil {
+		return nil, fmt.Errorf("%v has fields with the rg tag but no subscriptionId or id field", t)
+	}
+
+	enrichPlans.Store(t, plan)
+	return plan, nil
+}
+
+// scope returns the subscription and the resource group of the row in lower case.
+func (p *enrichPlan) scope(row reflect.Value) (string, string) {
+	var subscriptionID, resourceGroup string
+	if p.subscriptionID != nil {
+		if f, err := row.FieldByIndexErr(p.subscriptionID); err == nil {
+			subscriptionID = f.String()
+		}
+	}
+
+	if p.resourceGroup != nil {
+		if f, err := row.FieldByIndexErr(p.resourceGroup); err == nil {
+			resourceGroup = f.String()
+		}
+	}
+
+	if (subscriptionID == "" || resourceGroup == "") && p.id != nil {
+		if f, err := row.FieldByIndexErr(p.id); err == nil {
+			var id ResourceID
+			if f.Type() == resourceIDType {
+				id = f.Interface().(ResourceID)
+			} else {
+				id, _ = ParseResourceID(f.String())
+			}
+
+			if subscriptionID == "" {
+				subscriptionID = id.SubscriptionID
+			}
+			if resourceGroup == "" && strings.EqualFold(id.SubscriptionID, subscriptionID) {
+				resourceGroup = id.ResourceGroup
+			}
+		}
+	}
+
+	return strings.ToLower(subscriptionID), strings.ToLower(resourceGroup)
+}
+
+// enrich fills the fields tagged with "rg" of the rows, which is a slice of structs or pointers to them.
+// See [ExecOptions.Enrich].
+func enrich(ctx context.Context, options *ExecOptions, rows reflect.Value) error {
+	elemType := rows.Type().Elem()
+	pointer := elemType.Kind() == reflect.Pointer
+	if pointer {
+		elemType = elemType.Elem()
+	}
+
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("enriching type %v: the rows must be structs", rows.Type().Elem())
+	}
+
+	plan, err := enrichPlanFor(elemType)
+	if err != nil {
+		return err
+	}
+
+	if len(plan.targets) == 0 || rows.Len() == 0 {
+		return nil
+	}
+
+	client, err := getClient(options)
+	if err != nil {
+		return err
+	}
+
+	l := client.lookups
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if plan.needs(tagResourceGroupTags) {
+		seen := map[string]bool{}
+		var subscriptions []string
+		for i := 0; i < rows.Len(); i++ {
+			row := rows.Index(i)
+			if pointer {
+				if row.IsNil() {
+					continue
+				}
+				row = row.Elem()
+			}
+
+			if id, _ := plan.scope(row); id != "" && !seen[id] {
+				seen[id] = true
+				subscriptions = append(subscriptions, id)
+			}
+		}
+
+		if err := l.loadResourceGroups(ctx, client, subscriptions); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		if pointer {
+			if row.IsNil() {
+				continue
+			}
+			row = row.Elem()
+		}
+
+		subscriptionID, resourceGroup := plan.scope(row)
+		if subscriptionID == "" {
+			continue
+		}
+
+		for _, target := range plan.targets {
+			field, err := row.FieldByIndexErr(target.index)
+			if err != nil {
+				// The field is in a nil embedded struct.
+				continue
+			}
+
+			switch target.tag {
+			case tagSubscriptionName, tagManagementGroupPath:
+				subscription, err := l.subscription(ctx, client, subscriptionID)
+				if err != nil {
+					return err
+				}
+				if subscription == nil {
+					continue
+				}
+
+				switch {
+				case target.tag == tagSubscriptionName:
+					field.SetString(subscription.Name)
+				case field.Kind() == reflect.String:
+					field.SetString(strings.Join(subscription.ManagementGroupPath(), "/"))
+				default:
+					field.Set(reflect.ValueOf(subscription.ManagementGroupPath()))
+				}
+			case tagResourceGroupTags:
+				if tags, ok := l.resourceGroups[subscriptionID][resourceGroup]; ok && resourceGroup != "" {
+					// The rows get their own copies, so that changing them doesn't change the cache.
+					copied := make(map[string]string, len(tags))
+					for k, v := range tags {
+						copied[k] = v
+					}
+					field.Set(reflect.ValueOf(copied))
+				}
+			}
+		}
+	}
+
+	return nil
+}
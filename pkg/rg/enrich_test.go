@@ -0,0 +1,213 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queryRouter sends the queries which contain a key of the routes to its server, and the other queries to main.
+type queryRouter struct {
+	main   *rgtest.Server
+	routes map[string]*rgtest.Server
+}
+
+func (r *queryRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var request rgtest.Request
+	_ = json.Unmarshal(body, &request)
+	for key, server := range r.routes {
+		if strings.Contains(request.Query, key) {
+			server.ServeHTTP(w, req)
+			return
+		}
+	}
+
+	r.main.ServeHTTP(w, req)
+}
+
+const resourceGroupsPage = `[
+	{"subscriptionId": "sub1", "resourceGroup": "RG1", "tags": {"owner": "a"}},
+	{"subscriptionId": "sub2", "resourceGroup": "rg2", "tags": {"owner": "b"}}
+]`
+
+// enrichServers returns the router serving the rows, the subscriptions and the resource groups.
+func enrichServers(rows string) (*queryRouter, *rgtest.Server, *rgtest.Server) {
+	subscriptions := &rgtest.Server{Pages: []string{subscriptionsPage}}
+	resourceGroups := &rgtest.Server{Pages: []string{resourceGroupsPage}}
+	return &queryRouter{
+		main: &rgtest.Server{Pages: []string{rows}},
+		routes: map[string]*rgtest.Server{
+			"'microsoft.resources/subscriptions'":                subscriptions,
+			"'microsoft.resources/subscriptions/resourcegroups'": resourceGroups,
+		},
+	}, subscriptions, resourceGroups
+}
+
+type enrichedRecord struct {
+	ID               string            `json:"id"`
+	SubscriptionID   string            `json:"subscriptionId"`
+	ResourceGroup    string            `json:"resourceGroup"`
+	SubscriptionName string            `rg:"subscriptionName"`
+	Path             string            `rg:"managementGroupPath"`
+	ManagementGroups []string          `rg:"managementGroupPath"`
+	GroupTags        map[string]string `rg:"resourceGroupTags"`
+}
+
+const enrichRows = `[
+	{"id": "/subscriptions/sub1/resourceGroups/RG1/providers/Microsoft.Compute/virtualMachines/vm1", "subscriptionId": "sub1", "resourceGroup": "rg1"},
+	{"id": "/subscriptions/SUB2/resourceGroups/rg2/providers/Microsoft.Compute/virtualMachines/vm2"},
+	{"id": "/subscriptions/unknown/resourceGroups/rg3/providers/Microsoft.Compute/virtualMachines/vm3"}
+]`
+
+func TestEnrich(t *testing.T) {
+	router, subscriptions, resourceGroups := enrichServers(enrichRows)
+	client := rgtest.NewClient(t, router, nil)
+
+	items, err := rg.Exec[enrichedRecord](context.Background(), "resources | project id, subscriptionId, resourceGroup", &rg.ExecOptions{Client: client, Enrich: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []enrichedRecord{
+		{SubscriptionName: "Prod", Path: "Tenant Root Group/Alpha", ManagementGroups: []string{"Tenant Root Group", "Alpha"}, GroupTags: map[string]string{"owner": "a"}},
+		// The subscription and the resource group come from the ID.
+		{SubscriptionName: "Dev", Path: "Tenant Root Group/b-unit", ManagementGroups: []string{"Tenant Root Group", "b-unit"}, GroupTags: map[string]string{"owner": "b"}},
+		// The subscription is not visible to the client.
+		{},
+	}
+	for i := range items {
+		got := items[i]
+		got.ID, got.SubscriptionID, got.ResourceGroup = "", "", ""
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("row %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	if requests := resourceGroups.Requests(); len(requests) != 1 || !reflect.DeepEqual(requests[0].Subscriptions, []string{"sub1", "sub2", "unknown"}) {
+		t.Errorf("the resource groups are looked up with %+v, want one request for the subscriptions of the rows", requests)
+	}
+
+	// The lookups are cached by the client, and the rows have their own copies of the tags.
+	items[0].GroupTags["owner"] = "changed"
+	items, err = rg.Exec[enrichedRecord](context.Background(), "resources | project id, subscriptionId, resourceGroup", &rg.ExecOptions{Client: client, Enrich: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(subscriptions.Requests()) != 1 || len(resourceGroups.Requests()) != 1 {
+		t.Errorf("got %d subscriptions and %d resource groups lookups, want them cached", len(subscriptions.Requests()), len(resourceGroups.Requests()))
+	}
+
+	if items[0].GroupTags["owner"] != "a" {
+		t.Errorf("got the tags %v, want them unchanged", items[0].GroupTags)
+	}
+}
+
+func TestEnrichLookupTTL(t *testing.T) {
+	router, subscriptions, _ := enrichServers(enrichRows)
+	client := rgtest.NewClient(t, router, &rg.ClientOptions{LookupTTL: 50 * time.Millisecond})
+
+	type record struct {
+		ID               rg.ResourceID `json:"id"`
+		SubscriptionName string        `rg:"subscriptionName"`
+	}
+
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(60 * time.Millisecond)
+		}
+
+		items, err := rg.Exec[*record](context.Background(), "resources | project id", &rg.ExecOptions{Client: client, Enrich: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(items) != 3 || items[0].SubscriptionName != "Prod" || items[1].SubscriptionName != "Dev" {
+			t.Errorf("got %+v %+v, want the names from the IDs", items[0], items[1])
+		}
+	}
+
+	if len(subscriptions.Requests()) != 2 {
+		t.Errorf("got %d subscriptions lookups, want 2 as the cache expires", len(subscriptions.Requests()))
+	}
+}
+
+func TestEnrichExecInto(t *testing.T) {
+	router, _, _ := enrichServers(enrichRows)
+	client := rgtest.NewClient(t, router, nil)
+
+	var items []enrichedRecord
+	if err := rg.ExecInto(context.Background(), "resources | project id", &rg.ExecOptions{Client: client, Enrich: true, First: 2}, &items); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 || items[1].SubscriptionName != "Dev" || items[1].GroupTags["owner"] != "b" {
+		t.Errorf("got %+v", items)
+	}
+}
+
+func TestEnrichErrors(t *testing.T) {
+	router, _, _ := enrichServers(enrichRows)
+	client := rgtest.NewClient(t, router, nil)
+	options := &rg.ExecOptions{Client: client, Enrich: true}
+
+	type unknownTag struct {
+		ID   string `json:"id"`
+		Name string `rg:"name"`
+	}
+	if _, err := rg.Exec[unknownTag](context.Background(), "resources", options); err == nil || !strings.Contains(err.Error(), `unknown tag rg:"name"`) {
+		t.Errorf("got error %v, want the unknown tag error", err)
+	}
+
+	type wrongType struct {
+		ID   string `json:"id"`
+		Tags string `rg:"resourceGroupTags"`
+	}
+	if _, err := rg.Exec[wrongType](context.Background(), "resources", options); err == nil || !strings.Contains(err.Error(), "has unsupported type string") {
+		t.Errorf("got error %v, want the unsupported type error", err)
+	}
+
+	type noID struct {
+		Name             string `json:"name"`
+		SubscriptionName string `rg:"subscriptionName"`
+	}
+	if _, err := rg.Exec[noID](context.Background(), "resources", options); err == nil || !strings.Contains(err.Error(), "no subscriptionId or id field") {
+		t.Errorf("got error %v, want the missing ID error", err)
+	}
+
+	if _, err := rg.Exec[map[string]any](context.Background(), "resources", options); err == nil || !strings.Contains(err.Error(), "the rows must be structs") {
+		t.Errorf("got error %v, want the structs error", err)
+	}
+
+	// The rows without the rg tag don't need the lookups.
+	failing := &queryRouter{
+		main:   &rgtest.Server{Pages: []string{enrichRows}},
+		routes: map[string]*rgtest.Server{"resourcecontainers": {Status: http.StatusForbidden, Body: `{"error": {"code": "AuthorizationFailed", "message": "denied"}}`}},
+	}
+	options.Client = rgtest.NewClient(t, failing, nil)
+	if _, err := rg.Exec[record](context.Background(), "resources", options); err != nil {
+		t.Errorf("got error %v for rows without the rg tag", err)
+	}
+
+	if _, err := rg.Exec[enrichedRecord](context.Background(), "resources", options); err == nil || !strings.Contains(err.Error(), "looking up resource groups") {
+		t.Errorf("got error %v, want the lookup error", err)
+	}
+}
@@ -0,0 +1,261 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HierarchyNodeType is the type of a [HierarchyNode].
+type HierarchyNodeType string
+
+const (
+	// NodeManagementGroup is a management group.
+	NodeManagementGroup HierarchyNodeType = "managementGroup"
+
+	// NodeSubscription is a subscription.
+	NodeSubscription HierarchyNodeType = "subscription"
+)
+
+// HierarchyNode is a management group or a subscription in the [Hierarchy].
+type HierarchyNode struct {
+	Type HierarchyNodeType
+
+	// Name is the name of the management group, which is its ID, or the ID of the subscription.
+	Name string
+
+	// DisplayName is the display name of the management group, or the name of the subscription.
+	DisplayName string
+
+	// Parent is the parent management group, nil for the roots.
+	Parent *HierarchyNode
+
+	// Children are the child management groups followed by the subscriptions, sorted by display name.
+	Children []*HierarchyNode
+}
+
+// Walk calls fn for the node and its descendants in depth-first order. When fn returns false,
+// the children of the node are skipped.
+func (n *HierarchyNode) Walk(fn func(node *HierarchyNode) bool) {
+	if !fn(n) {
+		return
+	}
+
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Ancestors returns the management groups above the node, from the parent up to the root.
+func (n *HierarchyNode) Ancestors() []*HierarchyNode {
+	var result []*HierarchyNode
+	for p := n.Parent; p != nil; p = p.Parent {
+		result = append(result, p)
+	}
+	return result
+}
+
+// Descendants returns the management groups and subscriptions below the node in depth-first order.
+func (n *HierarchyNode) Descendants() []*HierarchyNode {
+	var result []*HierarchyNode
+	for _, child := range n.Children {
+		child.Walk(func(node *HierarchyNode) bool {
+			result = append(result, node)
+			return true
+		})
+	}
+	return result
+}
+
+// Subscriptions returns the IDs of the subscriptions in the subtree of the node, including the node itself.
+func (n *HierarchyNode) Subscriptions() []string {
+	var result []string
+	n.Walk(func(node *HierarchyNode) bool {
+		if node.Type == NodeSubscription {
+			result = append(result, node.Name)
+		}
+		return true
+	})
+	return result
+}
+
+// Scope returns a copy of the options which runs the query against the subtree of the node: the
+// management group, which includes all management groups and subscriptions below it, or the subscription.
+// The options can be nil.
+//
+// Example:
+//
+//	tree, err := rg.ManagementGroupTree(ctx, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	for _, unit := range tree.Get("business-units").Children {
+//		items, err := rg.Exec[record](ctx, query, unit.Scope(nil))
+//		...
+//	}
+func (n *HierarchyNode) Scope(options *ExecOptions) *ExecOptions {
+	var result ExecOptions
+	if options != nil {
+		result = *options
+	}
+
+	result.Subscriptions = nil
+	result.ManagementGroups = nil
+	if n.Type == NodeSubscription {
+		result.Subscriptions = []string{n.Name}
+	} else {
+		result.ManagementGroups = []string{n.Name}
+	}
+
+	return &result
+}
+
+// Hierarchy is the tree of the management groups and subscriptions, see [ManagementGroupTree].
+type Hierarchy struct {
+	// Roots are the nodes without parents: normally the tenant root group, but also the highest
+	// management groups and subscriptions visible to the caller when the root is not.
+	Roots []*HierarchyNode
+
+	// groups are the management groups by their names in lower case.
+	groups map[string]*HierarchyNode
+
+	// subscriptions are the subscriptions by their IDs in lower case. They are apart from the management
+	// groups, as the name of a management group can be the ID of a subscription.
+	subscriptions map[string]*HierarchyNode
+}
+
+// Get returns the management group or subscription by name, compared case-insensitively, or nil.
+// The management group is returned when a subscription has the same ID, see [Hierarchy.GetSubscription].
+func (h *Hierarchy) Get(name string) *HierarchyNode {
+	if node := h.groups[strings.ToLower(name)]; node != nil {
+		return node
+	}
+
+	return h.subscriptions[strings.ToLower(name)]
+}
+
+// GetSubscription returns the subscription by ID, compared case-insensitively, or nil.
+func (h *Hierarchy) GetSubscription(id string) *HierarchyNode {
+	return h.subscriptions[strings.ToLower(id)]
+}
+
+// Walk calls fn for the nodes of all roots in depth-first order, see [HierarchyNode.Walk].
+func (h *Hierarchy) Walk(fn func(node *HierarchyNode) bool) {
+	for _, root := range h.Roots {
+		root.Walk(fn)
+	}
+}
+
+// hierarchyQuery returns the management groups and the subscriptions with their parents.
+const hierarchyQuery = `resourcecontainers
+| where type =~ 'microsoft.management/managementgroups' or type =~ 'microsoft.resources/subscriptions'
+| project type, name, subscriptionId,
+    displayName = tostring(properties.displayName),
+    managementGroupParent = tostring(properties.details.parent.name),
+    subscriptionParent = tostring(properties.managementGroupAncestorsChain[0].name)
+| order by type asc, name asc`
+
+// hierarchyRecord is a row of hierarchyQuery.
+type hierarchyRecord struct {
+	Type                  string `json:"type"`
+	Name                  string `json:"name"`
+	SubscriptionID        string `json:"subscriptionId"`
+	DisplayName           string `json:"displayName"`
+	ManagementGroupParent string `json:"managementGroupParent"`
+	SubscriptionParent    string `json:"subscriptionParent"`
+}
+
+// ManagementGroupTree queries resourcecontainers for the management groups and subscriptions visible
+// to the caller and returns them as a tree. Only [ExecOptions.Client] of the options is used.
+//
+// Example:
+//
+//	tree, err := rg.ManagementGroupTree(ctx, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	tree.Walk(func(node *rg.HierarchyNode) bool {
+//		fmt.Printf("%s%s\n", strings.Repeat("  ", len(node.Ancestors())), node.DisplayName)
+//		return true
+//	})
+func ManagementGroupTree(ctx context.Context, options *ExecOptions) (*Hierarchy, error) {
+	execOptions := &ExecOptions{}
+	if options != nil {
+		execOptions.Client = options.Client
+	}
+
+	records, err := Exec[hierarchyRecord](ctx, hierarchyQuery, execOptions)
+	if err != nil {
+		return nil, fmt.Errorf("querying management groups: %w", err)
+	}
+
+	return newHierarchy(records), nil
+}
+
+func newHierarchy(records []hierarchyRecord) *Hierarchy {
+	result := &Hierarchy{groups: map[string]*HierarchyNode{}, subscriptions: map[string]*HierarchyNode{}}
+	parents := map[*HierarchyNode]string{}
+	var nodes []*HierarchyNode
+	for _, r := range records {
+		node := &HierarchyNode{Type: NodeManagementGroup, Name: r.Name, DisplayName: r.DisplayName}
+		parent := r.ManagementGroupParent
+		byName := result.groups
+		if strings.EqualFold(r.Type, "microsoft.resources/subscriptions") {
+			node = &HierarchyNode{Type: NodeSubscription, Name: r.SubscriptionID, DisplayName: r.Name}
+			parent = r.SubscriptionParent
+			byName = result.subscriptions
+		}
+
+		if node.DisplayName == "" {
+			node.DisplayName = node.Name
+		}
+
+		byName[strings.ToLower(node.Name)] = node
+		parents[node] = parent
+		nodes = append(nodes, node)
+	}
+
+	for _, node := range nodes {
+		parent := result.groups[strings.ToLower(parents[node])]
+		if parent == nil || isAncestor(node, parent) {
+			result.Roots = append(result.Roots, node)
+			continue
+		}
+
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	sortNodes(result.Roots)
+	for _, node := range nodes {
+		sortNodes(node.Children)
+	}
+
+	return result
+}
+
+// isAncestor tells if the node is the other node or above it, which guards against cycles.
+func isAncestor(node *HierarchyNode, other *HierarchyNode) bool {
+	for p := other; p != nil; p = p.Parent {
+		if p == node {
+			return true
+		}
+	}
+	return false
+}
+
+// sortNodes sorts the management groups before the subscriptions, and then by display name.
+func sortNodes(nodes []*HierarchyNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Type != nodes[j].Type {
+			return nodes[i].Type == NodeManagementGroup
+		}
+		return strings.ToLower(nodes[i].DisplayName) < strings.ToLower(nodes[j].DisplayName)
+	})
+}
@@ -0,0 +1,136 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// guid is both the name of a management group and the ID of a subscription.
+const guid = "00000000-0000-0000-0000-000000000001"
+
+const hierarchyPage = `[
+	{"type": "microsoft.management/managementgroups", "name": "tenant", "displayName": "Tenant Root Group"},
+	{"type": "microsoft.management/managementgroups", "name": "b-unit", "displayName": "Beta", "managementGroupParent": "tenant"},
+	{"type": "microsoft.management/managementgroups", "name": "a-unit", "displayName": "alpha", "managementGroupParent": "tenant"},
+	{"type": "microsoft.management/managementgroups", "name": "` + guid + `", "displayName": "Guid group", "managementGroupParent": "tenant"},
+	{"type": "microsoft.management/managementgroups", "name": "cycle1", "displayName": "Cycle 1", "managementGroupParent": "cycle2"},
+	{"type": "microsoft.management/managementgroups", "name": "cycle2", "displayName": "Cycle 2", "managementGroupParent": "cycle1"},
+	{"type": "microsoft.management/managementgroups", "name": "orphan-mg", "displayName": "Orphan group", "managementGroupParent": "gone"},
+	{"type": "microsoft.management/managementgroups", "name": "nameless", "managementGroupParent": "b-unit"},
+	{"type": "microsoft.resources/subscriptions", "name": "Prod", "subscriptionId": "` + guid + `", "subscriptionParent": "a-unit"},
+	{"type": "microsoft.resources/subscriptions", "name": "Dev", "subscriptionId": "sub3", "subscriptionParent": "a-unit"},
+	{"type": "microsoft.resources/subscriptions", "name": "Staging", "subscriptionId": "sub4", "subscriptionParent": "` + guid + `"},
+	{"type": "microsoft.resources/subscriptions", "name": "Orphan", "subscriptionId": "sub2", "subscriptionParent": "missing"},
+	{"type": "microsoft.resources/subscriptions", "name": "Under a subscription", "subscriptionId": "sub5", "subscriptionParent": "sub3"}
+]`
+
+func managementGroupTree(t *testing.T) *rg.Hierarchy {
+	t.Helper()
+
+	client := rgtest.NewClient(t, &rgtest.Server{Pages: []string{hierarchyPage}}, nil)
+	tree, err := rg.ManagementGroupTree(context.Background(), &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tree
+}
+
+func TestManagementGroupTree(t *testing.T) {
+	tree := managementGroupTree(t)
+
+	var lines []string
+	tree.Walk(func(node *rg.HierarchyNode) bool {
+		lines = append(lines, strings.Repeat("  ", len(node.Ancestors()))+node.DisplayName)
+		return true
+	})
+
+	// The management groups come before the subscriptions, then the nodes are sorted by display name
+	// ignoring case. The nodes with missing parents, and the first one found in a cycle, are roots.
+	want := []string{
+		"Cycle 2",
+		"  Cycle 1",
+		"Orphan group",
+		"Tenant Root Group",
+		"  alpha",
+		"    Dev",
+		"    Prod",
+		"  Beta",
+		"    nameless",
+		"  Guid group",
+		"    Staging",
+		"Orphan",
+		"Under a subscription",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestHierarchyGet(t *testing.T) {
+	tree := managementGroupTree(t)
+
+	// The management group and the subscription with the same name are both in the tree.
+	if group := tree.Get(guid); group == nil || group.Type != rg.NodeManagementGroup || len(group.Children) != 1 || group.Children[0].Name != "sub4" {
+		t.Errorf("got %+v, want the management group", group)
+	}
+
+	if subscription := tree.GetSubscription(guid); subscription == nil || subscription.Type != rg.NodeSubscription || subscription.Parent.Name != "a-unit" {
+		t.Errorf("got %+v, want the subscription", subscription)
+	}
+
+	if node := tree.Get("SUB3"); node == nil || node.DisplayName != "Dev" {
+		t.Errorf("got %+v, want subscription sub3", node)
+	}
+
+	if tree.Get("missing") != nil || tree.GetSubscription("a-unit") != nil {
+		t.Error("got nodes which are not in the tree")
+	}
+}
+
+func TestHierarchyNode(t *testing.T) {
+	tree := managementGroupTree(t)
+
+	var ancestors []string
+	for _, node := range tree.Get("sub3").Ancestors() {
+		ancestors = append(ancestors, node.Name)
+	}
+	if !reflect.DeepEqual(ancestors, []string{"a-unit", "tenant"}) {
+		t.Errorf("got ancestors %v", ancestors)
+	}
+
+	if got := tree.Get("tenant").Subscriptions(); !reflect.DeepEqual(got, []string{"sub3", guid, "sub4"}) {
+		t.Errorf("got subscriptions %v", got)
+	}
+
+	if got := len(tree.Get("tenant").Descendants()); got != 7 {
+		t.Errorf("got %d descendants, want 7", got)
+	}
+
+	// Walk skips the children of the nodes for which fn returns false.
+	var visited int
+	tree.Get("tenant").Walk(func(node *rg.HierarchyNode) bool {
+		visited++
+		return node.Name != "a-unit"
+	})
+	if visited != 6 {
+		t.Errorf("visited %d nodes, want 6", visited)
+	}
+
+	options := &rg.ExecOptions{Subscriptions: []string{"other"}, First: 10}
+	scoped := tree.Get("a-unit").Scope(options)
+	if scoped.Subscriptions != nil || !reflect.DeepEqual(scoped.ManagementGroups, []string{"a-unit"}) || scoped.First != 10 || options.Subscriptions == nil {
+		t.Errorf("got scope %+v", scoped)
+	}
+
+	if scoped := tree.GetSubscription(guid).Scope(nil); !reflect.DeepEqual(scoped.Subscriptions, []string{guid}) || scoped.ManagementGroups != nil {
+		t.Errorf("got scope %+v", scoped)
+	}
+}
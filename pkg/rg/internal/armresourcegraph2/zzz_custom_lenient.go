@@ -0,0 +1,286 @@
+package armresourcegraph2
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/modern-go/reflect2"
+)
+
+// This is the customisation of the original Azure SDK package
+// for tolerant decoding of the values returned by Resource Graph.
+
+// LenientJSON is the jsoniter API which decodes values leniently:
+//
+//   - time.Time from datetime strings in RFC 3339 format with or without the time zone, and in
+//     several other common formats, and from numbers as Unix time in seconds.
+//   - time.Duration from KQL timespan strings like "1.02:03:04.5", ISO 8601 durations like "PT5M",
+//     Go duration strings like "1h30m", and from numbers as nanoseconds.
+//   - integer, float and bool fields from strings like "42", "1.5" and "true".
+//
+// Empty strings and nulls decode as zero values. Types which implement json.Unmarshaler or
+// encoding.TextUnmarshaler keep decoding themselves.
+var LenientJSON = newLenientJSON()
+
+func newLenientJSON() jsoniter.API {
+	api := jsoniter.Config{EscapeHTML: true}.Froze()
+	api.RegisterExtension(&lenientExtension{})
+	return api
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// lenientExtension creates the lenient decoders for the types it knows.
+type lenientExtension struct {
+	jsoniter.DummyExtension
+}
+
+func (e *lenientExtension) CreateDecoder(typ reflect2.Type) jsoniter.ValDecoder {
+	t := typ.Type1()
+	switch t {
+	case timeType:
+		return &lenientTimeDecoder{}
+	case durationType:
+		return &lenientDurationDecoder{}
+	}
+
+	if t.Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) ||
+		t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return &lenientScalarDecoder{typ: t}
+	}
+
+	return nil
+}
+
+// lenientScalarDecoder decodes numbers and booleans from JSON values or strings.
+type lenientScalarDecoder struct {
+	typ reflect.Type
+}
+
+func (d *lenientScalarDecoder) Decode(ptr unsafe.Pointer, iter *jsoniter.Iterator) {
+	var text string
+	switch iter.WhatIsNext() {
+	case jsoniter.NilValue:
+		iter.Skip()
+		return
+	case jsoniter.StringValue:
+		text = strings.TrimSpace(iter.ReadString())
+	case jsoniter.NumberValue:
+		text = string(iter.ReadNumber())
+	case jsoniter.BoolValue:
+		text = strconv.FormatBool(iter.ReadBool())
+	default:
+		iter.ReportError("decode "+d.typ.String(), "expects a number, a bool or a string")
+		return
+	}
+
+	if text == "" {
+		return
+	}
+
+	v := reflect.NewAt(d.typ, ptr).Elem()
+	if err := setScalar(v, text); err != nil {
+		iter.ReportError("decode "+d.typ.String(), err.Error())
+	}
+}
+
+func setScalar(v reflect.Value, text string) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			// Integers are sometimes written as floats, e.g. "2.0".
+			f, ferr := strconv.ParseFloat(text, 64)
+			if ferr != nil || f != float64(int64(f)) || v.OverflowInt(int64(f)) {
+				return err
+			}
+			i = int64(f)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			f, ferr := strconv.ParseFloat(text, 64)
+			if ferr != nil || f < 0 || f != float64(uint64(f)) || v.OverflowUint(uint64(f)) {
+				return err
+			}
+			u = uint64(f)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+
+	return nil
+}
+
+// timeLayouts are the layouts tried after RFC 3339 when decoding time leniently.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+	"1/2/2006 3:04:05 PM",
+	"1/2/2006 15:04:05",
+	"1/2/2006",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// lenientTimeDecoder decodes time.Time from datetime strings and Unix time numbers.
+type lenientTimeDecoder struct{}
+
+func (d *lenientTimeDecoder) Decode(ptr unsafe.Pointer, iter *jsoniter.Iterator) {
+	switch iter.WhatIsNext() {
+	case jsoniter.NilValue:
+		iter.Skip()
+	case jsoniter.NumberValue:
+		f, err := iter.ReadNumber().Float64()
+		if err != nil {
+			iter.ReportError("decode time.Time", err.Error())
+			return
+		}
+		sec := int64(f)
+		*(*time.Time)(ptr) = time.Unix(sec, int64((f-float64(sec))*1e9)).UTC()
+	case jsoniter.StringValue:
+		t, err := ParseTime(strings.TrimSpace(iter.ReadString()))
+		if err != nil {
+			iter.ReportError("decode time.Time", err.Error())
+			return
+		}
+		*(*time.Time)(ptr) = t
+	default:
+		iter.ReportError("decode time.Time", "expects a string or a number")
+	}
+}
+
+// ParseTime parses the datetime text in the formats Azure Resource Graph uses: RFC 3339 with
+// or without the time zone, and the other timeLayouts. Empty text is the zero time.
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	// Handles RFC 3339 with or without the time zone, which Azure sometimes omits for UTC.
+	var rfc3339 timeRFC3339
+	if err := rfc3339.UnmarshalText([]byte(s)); err == nil {
+		return time.Time(rfc3339), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unknown datetime format '%s'", s)
+}
+
+// lenientDurationDecoder decodes time.Duration from timespan strings and numbers of nanoseconds.
+type lenientDurationDecoder struct{}
+
+func (d *lenientDurationDecoder) Decode(ptr unsafe.Pointer, iter *jsoniter.Iterator) {
+	switch iter.WhatIsNext() {
+	case jsoniter.NilValue:
+		iter.Skip()
+	case jsoniter.NumberValue:
+		*(*time.Duration)(ptr) = time.Duration(iter.ReadInt64())
+	case jsoniter.StringValue:
+		v, err := parseDuration(strings.TrimSpace(iter.ReadString()))
+		if err != nil {
+			iter.ReportError("decode time.Duration", err.Error())
+			return
+		}
+		*(*time.Duration)(ptr) = v
+	default:
+		iter.ReportError("decode time.Duration", "expects a string or a number")
+	}
+}
+
+var (
+	// timespanRegex matches KQL and .NET timespans: [-][d.]hh:mm[:ss[.fffffff]].
+	timespanRegex = regexp.MustCompile(`^(-)?(?:(\d+)\.)?(\d+):(\d+)(?::(\d+)(?:\.(\d+))?)?$`)
+
+	// iso8601DurationRegex matches ISO 8601 durations without years and months: [-]P[nD][T[nH][nM][n[.n]S]].
+	iso8601DurationRegex = regexp.MustCompile(`^(-)?P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+)
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	if m := timespanRegex.FindStringSubmatch(s); m != nil {
+		d := time.Duration(atoi(m[2]))*24*time.Hour +
+			time.Duration(atoi(m[3]))*time.Hour +
+			time.Duration(atoi(m[4]))*time.Minute +
+			time.Duration(atoi(m[5]))*time.Second
+		if m[6] != "" {
+			// Fraction of a second, up to nanoseconds.
+			fraction := (m[6] + "000000000")[:9]
+			d += time.Duration(atoi(fraction))
+		}
+		if m[1] != "" {
+			d = -d
+		}
+		return d, nil
+	}
+
+	if m := iso8601DurationRegex.FindStringSubmatch(strings.ToUpper(s)); m != nil && s != "P" && !strings.HasSuffix(strings.ToUpper(s), "T") {
+		d := time.Duration(atof(m[2])*float64(24*time.Hour)) +
+			time.Duration(atof(m[3])*float64(time.Hour)) +
+			time.Duration(atof(m[4])*float64(time.Minute)) +
+			time.Duration(atof(m[5])*float64(time.Second))
+		if m[1] != "" {
+			d = -d
+		}
+		return d, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+
+	return 0, fmt.Errorf("unknown timespan format '%s'", s)
+}
+
+// atoi converts the digits matched by a regex, empty text is zero.
+func atoi(s string) int64 {
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return i
+}
+
+// atof converts the number matched by a regex, empty text is zero.
+func atof(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,155 @@
+package armresourcegraph2
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// This is the customisation of the original Azure SDK package
+// which pages through the query results and leaves the data raw
+// for the caller to unmarshal into its own types.
+
+// Pages executes a query and returns the pager over the raw result pages.
+func Pages(client *Client, ctx context.Context, query QueryRequest) *QueryPager {
+	return &QueryPager{
+		client: client,
+		ctx:    ctx,
+		query:  query,
+	}
+}
+
+// QueryPager iterates over the query result pages.
+type QueryPager struct {
+	client  *Client
+	ctx     context.Context
+	query   QueryRequest
+	options *ClientResourcesOptions
+	page    *QueryPage
+	index   int
+}
+
+// QueryPage is the response returned by the query for a single page, with the data left raw.
+type QueryPage struct {
+	// Index is the zero-based index of the page.
+	Index int `json:"-"`
+
+	// Number of records returned in the current page.
+	Count *int64 `json:"count,omitempty"`
+
+	// Query output in JObject array or Table format.
+	Data jsoniter.RawMessage `json:"data,omitempty"`
+
+	// Indicates whether the query results are truncated.
+	ResultTruncated *ResultTruncated `json:"resultTruncated,omitempty"`
+
+	// Number of total records matching the query.
+	TotalRecords *int64 `json:"totalRecords,omitempty"`
+
+	// When present, the value can be passed to a subsequent query call (together with the same query and scopes used in the current
+	// request) to retrieve the next page of data.
+	SkipToken *string `json:"$skipToken,omitempty"`
+
+	// QuotaRemaining is the number of queries the caller can send before being throttled,
+	// from the x-ms-user-quota-remaining header.
+	QuotaRemaining *int `json:"-"`
+
+	// QuotaResetsAfter is the time until the quota resets, from the x-ms-user-quota-resets-after header.
+	QuotaResetsAfter *time.Duration `json:"-"`
+}
+
+// HasNext tells if there is next page.
+func (q *QueryPager) HasNext() bool {
+	if q.page == nil {
+		return true
+	}
+
+	return q.page.SkipToken != nil && *q.page.SkipToken != ""
+}
+
+// Next returns the current page and advances to the next page.
+func (q *QueryPager) Next() (*QueryPage, error) {
+	log.Printf("QueryPager: getting page %d", q.index)
+	// This is broadly a copy of Client.Resources with modifications
+	req, err := q.client.resourcesCreateRequest(q.ctx, q.query, q.options)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := q.client.pl.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return nil, runtime.NewResponseError(resp)
+	}
+
+	page, err := q.unmarshalPage(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Index = q.index
+	page.readQuota(resp.Header)
+	q.index++
+	q.page = page
+	if q.query.Options == nil {
+		q.query.Options = &QueryRequestOptions{}
+	}
+	q.query.Options.SkipToken = page.SkipToken
+
+	// The skip token already captures the offset and the size of the next pages,
+	// and $skip would override it, so $skip and $top only apply to the first page.
+	q.query.Options.Skip = nil
+	q.query.Options.Top = nil
+
+	return page, nil
+}
+
+// This is copied and adjusted from the Azure SDK code.
+func (q *QueryPager) unmarshalPage(resp *http.Response) (*QueryPage, error) {
+	payload, err := runtime.Payload(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var result QueryPage
+	if len(payload) == 0 {
+		// No payload means no data and no next page.
+		return &result, nil
+	}
+
+	trimmed := bytes.TrimPrefix(payload, []byte("\xef\xbb\xbf"))
+	err = jsoniter.Unmarshal(trimmed, &result)
+	if err != nil {
+		err = fmt.Errorf("unmarshalling type %T: %s", result, err)
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (p *QueryPage) readQuota(header http.Header) {
+	p.QuotaRemaining, p.QuotaResetsAfter = ReadQuota(header)
+}
+
+// ReadQuota reads the throttling quota headers, which are described in
+// https://learn.microsoft.com/en-us/azure/governance/resource-graph/concepts/guidance-for-throttled-requests.
+// The values are nil when the headers are missing, e.g. in the responses of throttled requests.
+func ReadQuota(header http.Header) (remaining *int, resetsAfter *time.Duration) {
+	if v, err := strconv.Atoi(header.Get("x-ms-user-quota-remaining")); err == nil {
+		remaining = &v
+	}
+
+	if v, err := parseDuration(header.Get("x-ms-user-quota-resets-after")); err == nil && v > 0 {
+		resetsAfter = &v
+	}
+
+	return remaining, resetsAfter
+}
@@ -0,0 +1,292 @@
+// Package jsondiff compares JSON documents and sets of JSON rows keyed by a column.
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind is the kind of change.
+type Kind string
+
+const (
+	Added    Kind = "added"
+	Removed  Kind = "removed"
+	Modified Kind = "modified"
+)
+
+// Change is a change of a single value in a JSON document.
+type Change struct {
+	// Path is the JSON path of the value, e.g. "$.properties.sku.name", "$.tags['cost center']" or "$.zones[0]".
+	Path string `json:"path"`
+
+	// Kind is the kind of change.
+	Kind Kind `json:"kind"`
+
+	// Old is the value before the change, nil when the value is added.
+	Old json.RawMessage `json:"old,omitempty"`
+
+	// New is the value after the change, nil when the value is removed.
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// Compare returns the changes from the old to the new JSON document, ordered by path.
+// Objects are compared key by key and arrays element by element, other values are compared whole.
+func Compare(old, current []byte) ([]Change, error) {
+	oldValue, err := decode(old)
+	if err != nil {
+		return nil, err
+	}
+
+	newValue, err := decode(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	if err := compare("$", oldValue, newValue, &changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// Rows is the difference between two sets of rows keyed by their IDs.
+type Rows struct {
+	// Added are the sorted keys of the rows which are only in the new set.
+	Added []string
+
+	// Removed are the sorted keys of the rows which are only in the old set.
+	Removed []string
+
+	// Modified are the sorted keys of the rows which are in both sets and differ.
+	Modified []string
+
+	// Changes are the changes of the modified rows by key.
+	Changes map[string][]Change
+}
+
+// IsEmpty tells if the sets of rows are the same.
+func (r *Rows) IsEmpty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Modified) == 0
+}
+
+// CompareRows returns the difference between the old and the new sets of rows.
+func CompareRows(old, current map[string]json.RawMessage) (*Rows, error) {
+	result := &Rows{Changes: map[string][]Change{}}
+	for key, newRow := range current {
+		oldRow, ok := old[key]
+		if !ok {
+			result.Added = append(result.Added, key)
+			continue
+		}
+
+		if bytes.Equal(oldRow, newRow) {
+			continue
+		}
+
+		changes, err := Compare(oldRow, newRow)
+		if err != nil {
+			return nil, fmt.Errorf("comparing row '%s': %w", key, err)
+		}
+
+		if len(changes) > 0 {
+			result.Modified = append(result.Modified, key)
+			result.Changes[key] = changes
+		}
+	}
+
+	for key := range old {
+		if _, ok := current[key]; !ok {
+			result.Removed = append(result.Removed, key)
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Modified)
+	return result, nil
+}
+
+// Key returns the key of the row: the value of the column in lower case, as Azure resource IDs
+// and names compare case-insensitively. Numbers and booleans are keyed by their JSON text.
+func Key(row []byte, column string) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return "", fmt.Errorf("the row is not a JSON object: %w", err)
+	}
+
+	raw, ok := fields[column]
+	if !ok {
+		return "", fmt.Errorf("the row has no key column '%s'", column)
+	}
+
+	value, err := decode(raw)
+	if err != nil {
+		return "", err
+	}
+
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			break
+		}
+		return strings.ToLower(value), nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+
+	return "", fmt.Errorf("the key column '%s' must be a non-empty string or a number, got %s", column, raw)
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func compare(path string, old, current any, changes *[]Change) error {
+	switch oldValue := old.(type) {
+	case map[string]any:
+		if newValue, ok := current.(map[string]any); ok {
+			return compareObjects(path, oldValue, newValue, changes)
+		}
+	case []any:
+		if newValue, ok := current.([]any); ok {
+			return compareArrays(path, oldValue, newValue, changes)
+		}
+	case json.Number:
+		if newValue, ok := current.(json.Number); ok && equalNumbers(oldValue, newValue) {
+			return nil
+		}
+	default:
+		if old == current {
+			return nil
+		}
+	}
+
+	return addChange(path, Modified, old, current, changes)
+}
+
+func compareObjects(path string, old, current map[string]any, changes *[]Change) error {
+	keys := make([]string, 0, len(old)+len(current))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldValue, inOld := old[key]
+		newValue, inNew := current[key]
+		keyPath := path + pathKey(key)
+
+		var err error
+		switch {
+		case !inOld:
+			err = addChange(keyPath, Added, nil, newValue, changes)
+		case !inNew:
+			err = addChange(keyPath, Removed, oldValue, nil, changes)
+		default:
+			err = compare(keyPath, oldValue, newValue, changes)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compareArrays(path string, old, current []any, changes *[]Change) error {
+	for i := 0; i < len(old) || i < len(current); i++ {
+		indexPath := path + "[" + strconv.Itoa(i) + "]"
+
+		var err error
+		switch {
+		case i >= len(old):
+			err = addChange(indexPath, Added, nil, current[i], changes)
+		case i >= len(current):
+			err = addChange(indexPath, Removed, old[i], nil, changes)
+		default:
+			err = compare(indexPath, old[i], current[i], changes)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func equalNumbers(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+
+	// The same number can be written differently, e.g. 1 and 1.0.
+	x, errX := a.Float64()
+	y, errY := b.Float64()
+	return errX == nil && errY == nil && x == y
+}
+
+func addChange(path string, kind Kind, old, current any, changes *[]Change) error {
+	change := Change{Path: path, Kind: kind}
+
+	var err error
+	if kind != Added {
+		if change.Old, err = marshal(old); err != nil {
+			return err
+		}
+	}
+	if kind != Removed {
+		if change.New, err = marshal(current); err != nil {
+			return err
+		}
+	}
+
+	*changes = append(*changes, change)
+	return nil
+}
+
+// marshal returns the value as compact JSON without escaping HTML characters, which are common in tags.
+func marshal(v any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// pathKey returns the JSON path segment of the object key.
+func pathKey(key string) string {
+	if identifierRegex.MatchString(key) {
+		return "." + key
+	}
+
+	return "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key) + "']"
+}
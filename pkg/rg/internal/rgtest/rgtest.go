@@ -0,0 +1,162 @@
+//go:build go1.18
+// +build go1.18
+
+// Package rgtest has a fake Azure Resource Graph for the tests, and the client which sends the queries to it.
+package rgtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Credential returns the token without talking to Entra ID, "token" when it is empty.
+type Credential struct {
+	Token string
+}
+
+func (c Credential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token := c.Token
+	if token == "" {
+		token = "token"
+	}
+
+	return azcore.AccessToken{Token: token, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// Request is the body of a query request as the fake server sees it.
+type Request struct {
+	Query            string   `json:"query"`
+	Subscriptions    []string `json:"subscriptions"`
+	ManagementGroups []string `json:"managementGroups"`
+	Options          struct {
+		Top          *int   `json:"$top"`
+		Skip         *int   `json:"$skip"`
+		SkipToken    string `json:"$skipToken"`
+		ResultFormat string `json:"resultFormat"`
+	} `json:"options"`
+}
+
+// Server serves the pages in order, the skip token of a page is the index of the next page.
+// The pages are the data of the responses: JSON arrays of rows, or JSON objects with the
+// columns and the rows for the table format. When Status is set, it responds with the status
+// and Body instead.
+type Server struct {
+	Pages  []string
+	Status int
+	Body   string
+	Header http.Header
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// Requests returns the requests the server received.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	for name, values := range s.Header {
+		w.Header()[name] = values
+	}
+
+	if s.Status != 0 {
+		w.WriteHeader(s.Status)
+		_, _ = io.WriteString(w, s.Body)
+		return
+	}
+
+	index := 0
+	if req.Options.SkipToken != "" {
+		index, _ = strconv.Atoi(req.Options.SkipToken)
+	}
+
+	skipToken := ""
+	if index+1 < len(s.Pages) {
+		skipToken = fmt.Sprintf(`, "$skipToken": "%d"`, index+1)
+	}
+
+	count := rowCount(s.Pages[index])
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"count": %d, "data": %s, "totalRecords": %d, "resultTruncated": "false"%s}`, count, s.Pages[index], count, skipToken)
+}
+
+// rowCount returns the number of rows of the page data in either format.
+func rowCount(data string) int {
+	var rows []json.RawMessage
+	if err := json.Unmarshal([]byte(data), &rows); err == nil {
+		return len(rows)
+	}
+
+	var table struct {
+		Rows []json.RawMessage `json:"rows"`
+	}
+	_ = json.Unmarshal([]byte(data), &table)
+	return len(table.Rows)
+}
+
+// NewClient returns the client which sends the queries to the handler. The options can be nil,
+// their cloud, transport and retries are replaced.
+func NewClient(t testing.TB, handler http.Handler, options *rg.ClientOptions) *rg.Client {
+	t.Helper()
+
+	client, err := rg.NewClient(Credential{}, ClientOptions(t, handler, options))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+// ClientOptions returns the options of the clients which send the queries to the handler, see [NewClient].
+func ClientOptions(t testing.TB, handler http.Handler, options *rg.ClientOptions) *rg.ClientOptions {
+	t.Helper()
+
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	if options == nil {
+		options = &rg.ClientOptions{}
+	}
+
+	options.ClientOptions = arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Cloud: cloud.Configuration{
+				ActiveDirectoryAuthorityHost: server.URL,
+				Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+					cloud.ResourceManager: {Endpoint: server.URL, Audience: "https://management.azure.com"},
+				},
+			},
+			Transport: server.Client(),
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+		},
+		DisableRPRegistration: true,
+	}
+
+	return options
+}
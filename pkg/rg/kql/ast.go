@@ -0,0 +1,269 @@
+package kql
+
+// Node is a node of the syntax tree of a query.
+type Node interface {
+	// Pos is the position of the first token of the node.
+	Pos() Position
+}
+
+// Expr is an expression: a scalar expression or a tabular one like a table name or a [*Pipeline].
+type Expr interface {
+	Node
+	expr()
+}
+
+// Query is a parsed query: the let statements followed by the tabular expression.
+type Query struct {
+	Lets []*LetStmt
+	Body *Pipeline
+}
+
+func (q *Query) Pos() Position {
+	if len(q.Lets) > 0 {
+		return q.Lets[0].Pos()
+	}
+	return q.Body.Pos()
+}
+
+// LetStmt is the statement "let Name = Value;".
+type LetStmt struct {
+	LetPos Position
+	Name   *Ident
+	Value  Expr
+}
+
+func (l *LetStmt) Pos() Position { return l.LetPos }
+
+// Pipeline is a tabular expression: the source, like a table name, piped into the operators.
+type Pipeline struct {
+	// Source is the source of the rows: an expression like a table name or a subquery,
+	// or the [*Operator] of a leading union.
+	Source    Expr
+	Operators []*Operator
+}
+
+func (p *Pipeline) Pos() Position { return p.Source.Pos() }
+
+// Operator is a tabular operator like "where" or "summarize". Which fields are set depends on the
+// operator, e.g. "summarize count() by type" has the aggregate in Args and the group in By.
+type Operator struct {
+	// OpPos is the position of the operator name.
+	OpPos Position
+
+	// Name is the operator name in lower case, e.g. "where" or "project-away".
+	Name string
+
+	// Params are the operator parameters like "kind=leftouter" of join.
+	Params []*Assign
+
+	// Args are the operator arguments, e.g. the predicate of where, the columns of project,
+	// the aggregates of summarize or the right side of join.
+	Args []Expr
+
+	// By are the group by expressions of summarize, and the sort expressions of order by and top.
+	By []Expr
+
+	// On are the join conditions.
+	On []Expr
+
+	// Limit is the limit of mv-expand.
+	Limit Expr
+
+	// Raw is the text of the arguments of the operators which aren't parsed, e.g. parse.
+	Raw string
+}
+
+func (o *Operator) Pos() Position { return o.OpPos }
+
+// Ident is a name, e.g. of a table, a column, a function or a variable.
+type Ident struct {
+	NamePos Position
+	Name    string
+}
+
+// BasicLit is a literal like 'text', 10, 1d, true or datetime(2024-01-01), with its text as in the query.
+type BasicLit struct {
+	ValuePos Position
+	Kind     TokenKind
+	Value    string
+}
+
+// Binary is a binary expression like "a == b", "a and b" or "a in ('x', 'y')".
+type Binary struct {
+	X     Expr
+	OpPos Position
+	Op    string
+	Y     Expr
+}
+
+// Unary is a unary expression like "-a" or "!a".
+type Unary struct {
+	OpPos Position
+	Op    string
+	X     Expr
+}
+
+// Call is a function call like "tostring(properties.name)".
+type Call struct {
+	Fun  Expr
+	Args []Expr
+}
+
+// Member is a member access like "properties.name".
+type Member struct {
+	X    Expr
+	Name *Ident
+}
+
+// Index is an index access like "tags['env']" or "items[0]".
+type Index struct {
+	X     Expr
+	Index Expr
+}
+
+// Paren is an expression in parentheses, e.g. a subquery "(resourcecontainers | where ...)".
+type Paren struct {
+	Lparen Position
+	X      Expr
+}
+
+// List is a list like "('a', 'b')" of the in operator or "[1, 2]" of dynamic arrays.
+type List struct {
+	Lbrack Position
+	Open   string
+	Elems  []Expr
+}
+
+// Object is an object of a dynamic literal like "{"a": 1}".
+type Object struct {
+	Lbrace Position
+	Keys   []Expr
+	Values []Expr
+}
+
+// Range is the range "From .. To" of the between operator.
+type Range struct {
+	From Expr
+	To   Expr
+}
+
+// Assign is a named expression like "name = expr" of project, extend and summarize,
+// or a parameter like "kind=leftouter".
+type Assign struct {
+	Name Expr
+	X    Expr
+}
+
+// Sorted is a sort expression of order by and top, like "name desc nulls last".
+type Sorted struct {
+	X     Expr
+	Order string
+	Nulls string
+}
+
+// Star is "*", e.g. in "count(*)" or "distinct *".
+type Star struct {
+	StarPos Position
+}
+
+func (x *Ident) Pos() Position    { return x.NamePos }
+func (x *BasicLit) Pos() Position { return x.ValuePos }
+func (x *Binary) Pos() Position   { return x.X.Pos() }
+func (x *Unary) Pos() Position    { return x.OpPos }
+func (x *Call) Pos() Position     { return x.Fun.Pos() }
+func (x *Member) Pos() Position   { return x.X.Pos() }
+func (x *Index) Pos() Position    { return x.X.Pos() }
+func (x *Paren) Pos() Position    { return x.Lparen }
+func (x *List) Pos() Position     { return x.Lbrack }
+func (x *Object) Pos() Position   { return x.Lbrace }
+func (x *Range) Pos() Position    { return x.From.Pos() }
+func (x *Assign) Pos() Position   { return x.Name.Pos() }
+func (x *Sorted) Pos() Position   { return x.X.Pos() }
+func (x *Star) Pos() Position     { return x.StarPos }
+func (x *Pipeline) expr()         {}
+func (x *Operator) expr()         {}
+func (x *Ident) expr()            {}
+func (x *BasicLit) expr()         {}
+func (x *Binary) expr()           {}
+func (x *Unary) expr()            {}
+func (x *Call) expr()             {}
+func (x *Member) expr()           {}
+func (x *Index) expr()            {}
+func (x *Paren) expr()            {}
+func (x *List) expr()             {}
+func (x *Object) expr()           {}
+func (x *Range) expr()            {}
+func (x *Assign) expr()           {}
+func (x *Sorted) expr()           {}
+func (x *Star) expr()             {}
+
+// Inspect traverses the tree in depth-first order: it calls fn for the node, and then for its
+// children when fn returns true.
+func Inspect(node Node, fn func(Node) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+
+	exprs := func(list []Expr) {
+		for _, x := range list {
+			Inspect(x, fn)
+		}
+	}
+
+	switch n := node.(type) {
+	case *Query:
+		for _, l := range n.Lets {
+			Inspect(l, fn)
+		}
+		Inspect(n.Body, fn)
+	case *LetStmt:
+		Inspect(n.Name, fn)
+		Inspect(n.Value, fn)
+	case *Pipeline:
+		Inspect(n.Source, fn)
+		for _, o := range n.Operators {
+			Inspect(o, fn)
+		}
+	case *Operator:
+		for _, p := range n.Params {
+			Inspect(p, fn)
+		}
+		exprs(n.Args)
+		exprs(n.By)
+		exprs(n.On)
+		if n.Limit != nil {
+			Inspect(n.Limit, fn)
+		}
+	case *Binary:
+		Inspect(n.X, fn)
+		Inspect(n.Y, fn)
+	case *Unary:
+		Inspect(n.X, fn)
+	case *Call:
+		Inspect(n.Fun, fn)
+		exprs(n.Args)
+	case *Member:
+		Inspect(n.X, fn)
+		Inspect(n.Name, fn)
+	case *Index:
+		Inspect(n.X, fn)
+		Inspect(n.Index, fn)
+	case *Paren:
+		Inspect(n.X, fn)
+	case *List:
+		exprs(n.Elems)
+	case *Object:
+		for i := range n.Keys {
+			Inspect(n.Keys[i], fn)
+			Inspect(n.Values[i], fn)
+		}
+	case *Range:
+		Inspect(n.From, fn)
+		Inspect(n.To, fn)
+	case *Assign:
+		Inspect(n.Name, fn)
+		Inspect(n.X, fn)
+	case *Sorted:
+		Inspect(n.X, fn)
+	}
+}
@@ -0,0 +1,232 @@
+package kql
+
+import "strings"
+
+// brackets are the closing brackets by the opening ones.
+var brackets = map[string]string{"(": ")", "[": "]", "{": "}"}
+
+// Check checks the query for syntax errors, see [Parse]. The error is a [*SyntaxError] with the
+// position in the query.
+func Check(query string) error {
+	_, err := Parse(query)
+	return err
+}
+
+// describe returns the token for error messages.
+func describe(t Token) string {
+	if t.Kind == TokenEOF {
+		return t.Kind.String()
+	}
+	return "'" + t.Text + "'"
+}
+
+// lastStatement returns the tokens of the last non-empty statement, without the EOF token.
+func lastStatement(tokens []Token) []Token {
+	statements := split(tokens[:len(tokens)-1], ";")
+	for i := len(statements) - 1; i >= 0; i-- {
+		if len(statements[i]) > 0 {
+			return statements[i]
+		}
+	}
+	return nil
+}
+
+// split splits the tokens by the punctuation outside of brackets.
+func split(tokens []Token, sep string) [][]Token {
+	var result [][]Token
+	depth, start := 0, 0
+	for i, t := range tokens {
+		switch {
+		case t.Kind != TokenPunctuation:
+		case brackets[t.Text] != "":
+			depth++
+		case t.Text == ")" || t.Text == "]" || t.Text == "}":
+			depth--
+		case t.Text == sep && depth == 0:
+			result = append(result, tokens[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(result, tokens[start:])
+}
+
+// ProjectedColumns returns the names of the columns of the query results in order, when they can be
+// told from the query text: the query ends with project, summarize, distinct or count, possibly followed
+// by operators which don't change the columns like where, order by, take or extend.
+// It returns false when the columns depend on the table schema, e.g. for "resources | where ...".
+func ProjectedColumns(query string) ([]string, bool) {
+	tokens, err := Tokenize(query)
+	if err != nil {
+		return nil, false
+	}
+
+	var columns []string
+	known := false
+	for i, segment := range split(lastStatement(tokens), "|") {
+		if i == 0 || len(segment) == 0 {
+			// The first segment is the table, or an expression we don't evaluate.
+			continue
+		}
+
+		args := segment[1:]
+		switch strings.ToLower(segment[0].Text) {
+		case "where", "filter", "order", "sort", "take", "limit", "top", "sample", "mv-expand", "mvexpand":
+			// The columns don't change.
+		case "project", "distinct":
+			columns, known = columnNames(args)
+		case "summarize":
+			columns, known = summarizeColumns(args)
+		case "count":
+			columns, known = []string{"Count"}, true
+		case "extend":
+			if known {
+				var added []string
+				added, known = columnNames(args)
+				columns = appendColumns(columns, added)
+			}
+		case "project-away":
+			if known {
+				var removed []string
+				removed, known = columnNames(args)
+				columns = removeColumns(columns, removed)
+			}
+		case "project-rename":
+			if known {
+				known = renameColumns(columns, args)
+			}
+		default:
+			known = false
+		}
+	}
+
+	if !known {
+		return nil, false
+	}
+
+	return columns, true
+}
+
+// columnNames returns the names of the columns of the comma-separated expressions,
+// e.g. "name", "ip = tostring(properties.ipAddress)" or "properties.sku" named "properties_sku".
+func columnNames(tokens []Token) ([]string, bool) {
+	var result []string
+	for _, expr := range split(tokens, ",") {
+		name, ok := columnName(expr)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, name)
+	}
+
+	return result, true
+}
+
+func columnName(expr []Token) (string, bool) {
+	if len(expr) >= 2 && expr[0].Kind == TokenIdentifier && expr[1].Is("=") {
+		return expr[0].Text, true
+	}
+
+	// A path like properties.sku.name, which Azure Resource Graph names properties_sku_name.
+	var parts []string
+	for i, t := range expr {
+		switch {
+		case i%2 == 0 && t.Kind == TokenIdentifier:
+			parts = append(parts, t.Text)
+		case i%2 == 1 && t.Is("."):
+		default:
+			return "", false
+		}
+	}
+
+	if len(parts) == 0 || len(expr)%2 == 0 {
+		return "", false
+	}
+
+	return strings.Join(parts, "_"), true
+}
+
+// summarizeColumns returns the columns of summarize: the by columns followed by the aggregates.
+func summarizeColumns(tokens []Token) ([]string, bool) {
+	by := len(tokens)
+	depth := 0
+	for i, t := range tokens {
+		switch {
+		case t.Kind == TokenPunctuation && brackets[t.Text] != "":
+			depth++
+		case t.Is(")") || t.Is("]") || t.Is("}"):
+			depth--
+		case depth == 0 && t.Is("by"):
+			by = i
+		}
+	}
+
+	var aggregates []string
+	for _, expr := range split(tokens[:by], ",") {
+		switch {
+		case len(expr) == 0:
+		case len(expr) >= 2 && expr[0].Kind == TokenIdentifier && expr[1].Is("="):
+			aggregates = append(aggregates, expr[0].Text)
+		case len(expr) == 3 && expr[0].Is("count") && expr[1].Is("(") && expr[2].Is(")"):
+			aggregates = append(aggregates, "count_")
+		default:
+			return nil, false
+		}
+	}
+
+	var result []string
+	if by < len(tokens) {
+		var ok bool
+		if result, ok = columnNames(tokens[by+1:]); !ok {
+			return nil, false
+		}
+	}
+
+	return append(result, aggregates...), true
+}
+
+// appendColumns appends the columns which are not in the list yet, as extend replaces the existing ones.
+func appendColumns(columns []string, added []string) []string {
+	for _, name := range added {
+		if indexColumn(columns, name) < 0 {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+func removeColumns(columns []string, removed []string) []string {
+	var result []string
+	for _, name := range columns {
+		if indexColumn(removed, name) < 0 {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// renameColumns renames the columns by the comma-separated "new = old" expressions.
+func renameColumns(columns []string, tokens []Token) bool {
+	for _, expr := range split(tokens, ",") {
+		if len(expr) != 3 || expr[0].Kind != TokenIdentifier || !expr[1].Is("=") || expr[2].Kind != TokenIdentifier {
+			return false
+		}
+
+		i := indexColumn(columns, expr[2].Text)
+		if i < 0 {
+			return false
+		}
+		columns[i] = expr[0].Text
+	}
+
+	return true
+}
+
+func indexColumn(columns []string, name string) int {
+	for i, c := range columns {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
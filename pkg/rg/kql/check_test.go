@@ -0,0 +1,29 @@
+package kql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProjectedColumns(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"resources | project name, ip = tostring(properties.ipAddress), properties.sku", []string{"name", "ip", "properties_sku"}},
+		{"resources | summarize n = count() by type, location", []string{"type", "location", "n"}},
+		{"resources | count", []string{"Count"}},
+		{"resources | distinct type | order by type asc | take 5", []string{"type"}},
+		{"resources | project a, b | extend c = 1 | project-away a | project-rename d = b", []string{"d", "c"}},
+		{"let x = 1; resources | project name", []string{"name"}},
+		{"resources | where name == 'a'", nil},
+		{"resources | project name | join (resourcecontainers) on subscriptionId", nil},
+	}
+
+	for _, tt := range tests {
+		got, ok := ProjectedColumns(tt.query)
+		if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ProjectedColumns(%q) = %q, %v, want %q", tt.query, got, ok, tt.want)
+		}
+	}
+}
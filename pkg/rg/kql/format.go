@@ -0,0 +1,300 @@
+package kql
+
+import (
+	"errors"
+	"strings"
+)
+
+// indentation is the indentation of a nesting level.
+const indentation = "    "
+
+// keywords are the words which Format writes in lower case, in addition to the operator names
+// and the word comparison operators.
+var keywords = map[string]bool{
+	"let": true, "by": true, "on": true, "and": true, "or": true, "asc": true, "desc": true,
+	"nulls": true, "first": true, "last": true, "with": true, "of": true, "to": true, "typeof": true,
+	"matches": true, "regex": true, "between": true, "!between": true, "true": true, "false": true,
+	"limit": true,
+}
+
+// spaceBeforeParen are the keywords followed by a space before "(", unlike function names.
+var spaceBeforeParen = map[string]bool{
+	"and": true, "or": true, "on": true, "by": true, "with": true, "of": true,
+	"between": true, "!between": true,
+}
+
+// Format formats the query: each tabular operator on its own line starting with "|", subqueries
+// indented in parentheses, one let statement per line, single spaces around binary operators and
+// after commas, and keywords in lower case. Comments are kept. The query must be free of syntax
+// errors, otherwise the error is a [*SyntaxError].
+//
+// Example:
+//
+//	resources
+//	| join kind=leftouter (
+//	    resourcecontainers
+//	    | where type =~ 'microsoft.resources/subscriptions'
+//	    | project subscriptionId, subscriptionName = name
+//	) on subscriptionId
+//	| order by name asc
+func Format(query string) (string, error) {
+	if _, err := Parse(query); err != nil {
+		return "", err
+	}
+
+	tokens, err := tokenize(query, true)
+	if err != nil {
+		return "", err
+	}
+
+	f := &formatter{text: query, tokens: tokens, multiline: multilineParens(tokens), lineStart: true}
+	result := f.format()
+
+	// The formatting only changes the whitespace and the case of keywords, make sure of it.
+	if !sameTokens(query, result) {
+		return "", errors.New("formatting changed the query")
+	}
+
+	return result, nil
+}
+
+type formatter struct {
+	text      string
+	tokens    []Token
+	multiline map[int]bool
+	sb        strings.Builder
+	indent    int
+	lineStart bool
+	noSpace   bool
+}
+
+// multilineParens returns the indexes of the "(" tokens with pipes inside, which are formatted as indented blocks.
+func multilineParens(tokens []Token) map[int]bool {
+	result := map[int]bool{}
+	var open []int
+	for i, t := range tokens {
+		switch {
+		case t.Is("(") || t.Is("[") || t.Is("{"):
+			open = append(open, i)
+		case t.Is(")") || t.Is("]") || t.Is("}"):
+			open = open[:len(open)-1]
+		case t.Is("|") && len(open) > 0 && tokens[open[len(open)-1]].Is("("):
+			result[open[len(open)-1]] = true
+		}
+	}
+	return result
+}
+
+func (f *formatter) newline() {
+	f.sb.WriteString("\n")
+	f.lineStart = true
+}
+
+func (f *formatter) write(text string, space bool) {
+	switch {
+	case f.lineStart:
+		f.sb.WriteString(strings.Repeat(indentation, f.indent))
+	case space && !f.noSpace:
+		f.sb.WriteString(" ")
+	}
+
+	f.sb.WriteString(text)
+	f.lineStart = false
+	f.noSpace = false
+}
+
+func (f *formatter) format() string {
+	var (
+		open []bool // the open parentheses, true for multi-line ones
+		prev []Token
+	)
+
+	for i := 0; i < len(f.tokens); i++ {
+		t := f.tokens[i]
+		if t.Kind == TokenEOF {
+			break
+		}
+
+		if i > 0 && f.lineStart && f.tokens[i-1].Pos.Line+strings.Count(f.tokens[i-1].Text, "\n")+1 < t.Pos.Line {
+			// Keep a blank line between the statements and around comments.
+			if t.Kind == TokenComment || f.tokens[i-1].Kind == TokenComment || f.tokens[i-1].Is(";") {
+				f.newline()
+			}
+		}
+
+		switch {
+		case t.Kind == TokenComment:
+			if f.lineStart {
+				f.write(t.Text, false)
+			} else {
+				f.sb.WriteString(" " + t.Text)
+			}
+			f.newline()
+			continue
+		case t.Is("|"):
+			if !f.lineStart {
+				f.newline()
+			}
+			f.write("|", false)
+		case t.Is(";") && len(open) == 0:
+			f.write(";", false)
+			f.newline()
+		case t.Is("("):
+			f.write("(", needSpace(prev, t))
+			open = append(open, f.multiline[i])
+			if f.multiline[i] {
+				f.indent++
+				f.newline()
+			} else {
+				f.noSpace = true
+			}
+		case t.Is(")") && len(open) > 0 && open[len(open)-1]:
+			open = open[:len(open)-1]
+			f.indent--
+			if !f.lineStart {
+				f.newline()
+			}
+			f.write(")", false)
+		case t.Is(")"):
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
+			f.write(")", false)
+		case t.Kind == TokenIdentifier && rawLiterals[strings.ToLower(t.Text)] && i+1 < len(f.tokens) && f.tokens[i+1].Is("("):
+			// A literal like datetime(2024-01-01) is written as it is.
+			end := i + 1
+			for depth := 0; ; end++ {
+				if f.tokens[end].Is("(") {
+					depth++
+				} else if f.tokens[end].Is(")") {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			f.write(strings.ToLower(t.Text)+f.text[f.tokens[i+1].Pos.Offset:f.tokens[end].Pos.Offset+1], needSpace(prev, t))
+			prev = append(prev, t, f.tokens[end])
+			i = end
+			continue
+		default:
+			f.write(keywordCase(back(prev, 1), t), needSpace(prev, t))
+			if t.Is("[") || t.Is("{") || t.Is(".") || isParamAssign(prev, t) || isUnary(prev, t) {
+				f.noSpace = true
+			}
+		}
+
+		prev = append(prev, t)
+	}
+
+	return strings.TrimRight(f.sb.String(), " \n") + "\n"
+}
+
+// keywordCase returns the text of the token, in lower case for keywords.
+func keywordCase(prev Token, t Token) string {
+	if t.Kind != TokenIdentifier {
+		return t.Text
+	}
+
+	lower := strings.ToLower(t.Text)
+	if keywords[lower] || comparisonOperators[lower] || listOperators[lower] || operatorParams[lower] ||
+		prev.Is("|") || prev.Kind == TokenEOF && lower == "union" {
+		return lower
+	}
+
+	return t.Text
+}
+
+// back returns the n-th token from the end, or an EOF token when there are fewer tokens.
+func back(tokens []Token, n int) Token {
+	if n <= len(tokens) {
+		return tokens[len(tokens)-n]
+	}
+	return Token{Kind: TokenEOF}
+}
+
+// isParam tells if the tokens end with an operator parameter name like "kind" or "hint.strategy".
+func isParam(tokens []Token) bool {
+	last := back(tokens, 1)
+	return last.Kind == TokenIdentifier && (operatorParams[strings.ToLower(last.Text)] || back(tokens, 2).Is("."))
+}
+
+// isParamAssign tells if t is the "=" of an operator parameter like "kind=leftouter".
+func isParamAssign(prev []Token, t Token) bool {
+	return t.Is("=") && isParam(prev)
+}
+
+// isParamValue tells if the tokens end with the value of an operator parameter.
+func isParamValue(tokens []Token) bool {
+	return len(tokens) >= 2 && back(tokens, 2).Is("=") && isParam(tokens[:len(tokens)-2])
+}
+
+// isUnary tells if t, following the tokens, is a unary operator.
+func isUnary(prev []Token, t Token) bool {
+	if !t.Is("-") && !t.Is("+") && !t.Is("!") {
+		return false
+	}
+
+	last := back(prev, 1)
+	switch last.Kind {
+	case TokenEOF:
+		return true
+	case TokenPunctuation:
+		return !last.Is(")") && !last.Is("]") && !last.Is("}")
+	case TokenIdentifier:
+		lower := strings.ToLower(last.Text)
+		return keywords[lower] || comparisonOperators[lower] || listOperators[lower] || back(prev, 2).Is("|")
+	default:
+		return false
+	}
+}
+
+// needSpace tells if there is a space between the previous tokens and t.
+func needSpace(tokens []Token, t Token) bool {
+	prev, prev2 := back(tokens, 1), back(tokens, 2)
+	switch {
+	case prev.Kind == TokenEOF:
+		return false
+	case t.Is(",") || t.Is(")") || t.Is("]") || t.Is("}") || t.Is(";") || t.Is(":") || t.Is("."):
+		return false
+	case prev.Is("."):
+		return false
+	case isParamAssign(tokens, t):
+		return false
+	case prev.Is("=") && isParam(tokens[:len(tokens)-1]):
+		return false
+	case t.Is("("):
+		if prev.Kind != TokenIdentifier {
+			return !prev.Is("(") && !prev.Is("[")
+		}
+		lower := strings.ToLower(prev.Text)
+		return spaceBeforeParen[lower] || comparisonOperators[lower] || listOperators[lower] ||
+			prev2.Is("|") || isParamValue(tokens) ||
+			lower == "join" || lower == "union" || lower == "lookup"
+	case t.Is("["):
+		return prev.Kind != TokenIdentifier && !prev.Is(")") && !prev.Is("]") && !prev.Is("(")
+	case t.Is("*") && prev.Kind == TokenIdentifier && prev.Pos.Offset+len(prev.Text) == t.Pos.Offset:
+		// A column pattern like "properties*".
+		return false
+	case prev.Is("(") || prev.Is("["):
+		return false
+	}
+
+	return true
+}
+
+// sameTokens tells if the queries have the same tokens, other than the case of identifiers.
+func sameTokens(a, b string) bool {
+	ta, errA := tokenize(a, true)
+	tb, errB := tokenize(b, true)
+	if errA != nil || errB != nil || len(ta) != len(tb) {
+		return false
+	}
+
+	for i := range ta {
+		if ta[i].Kind != tb[i].Kind || !strings.EqualFold(ta[i].Text, tb[i].Text) {
+			return false
+		}
+	}
+
+	return true
+}
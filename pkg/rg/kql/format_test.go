@@ -0,0 +1,54 @@
+package kql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			"resources|where name=='a'|join kind=leftouter(resourcecontainers|project subscriptionId,subscriptionName=name)on subscriptionId|ORDER BY name ASC",
+			"resources\n| where name == 'a'\n| join kind=leftouter (\n    resourcecontainers\n    | project subscriptionId, subscriptionName = name\n) on subscriptionId\n| order by name asc\n",
+		},
+		{
+			"let x=1;let y = 'a';resources | where x>1 // keep\n| project name",
+			"let x = 1;\nlet y = 'a';\nresources\n| where x > 1 // keep\n| project name\n",
+		},
+		{
+			"// all the resources\n\nresources | where name in ('a','b') and size between (1 .. 2)",
+			"// all the resources\n\nresources\n| where name in ('a', 'b') and size between (1 .. 2)\n",
+		},
+		{
+			"resources | where tags['cost center'] =~ 'IT' | extend sku = tostring(properties.sku.name)",
+			"resources\n| where tags['cost center'] =~ 'IT'\n| extend sku = tostring(properties.sku.name)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Format(tt.query)
+		if err != nil {
+			t.Errorf("Format(%q) failed: %v", tt.query, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Format(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+
+		// Formatting the formatted query changes nothing.
+		if again, err := Format(got); err != nil || again != got {
+			t.Errorf("Format(%q) = %q, %v, want it unchanged", got, again, err)
+		}
+	}
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	var syntaxError *SyntaxError
+	if _, err := Format("resources | where (name == 'a'"); !errors.As(err, &syntaxError) {
+		t.Errorf("got error %v, want a syntax error", err)
+	}
+}
@@ -0,0 +1,396 @@
+package kql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The rules of [Lint].
+const (
+	// RuleCaseSensitiveType reports case-sensitive comparisons of resource types, like "type == '...'",
+	// which miss rows as Azure Resource Graph doesn't normalise the case of the types.
+	RuleCaseSensitiveType = "case-sensitive-type"
+
+	// RuleUnorderedPaging reports queries without a final order by, which can return duplicate or
+	// missing rows when the results are paged. Queries ending in take or limit, or taking at most
+	// a page of rows, are not reported.
+	RuleUnorderedPaging = "unordered-paging"
+
+	// RuleUnsupportedJoin reports joins which Azure Resource Graph doesn't support: unsupported kinds,
+	// too many joins, and cross-table joins other than between resources and resourcecontainers.
+	RuleUnsupportedJoin = "unsupported-join"
+
+	// RuleUnsupportedOperator reports tabular operators which Azure Resource Graph doesn't support.
+	RuleUnsupportedOperator = "unsupported-operator"
+
+	// RuleUnknownTable reports tables which Azure Resource Graph doesn't have.
+	RuleUnknownTable = "unknown-table"
+)
+
+// Diagnostic is a problem found by [Lint].
+type Diagnostic struct {
+	Pos     Position
+	Rule    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Rule)
+}
+
+// Tables are the names of the Azure Resource Graph tables in lower case.
+var Tables = []string{
+	"advisorresources", "alertsmanagementresources", "appserviceresources", "authorizationresources",
+	"chaosresources", "communitygalleryresources", "computeresources", "desktopvirtualizationresources",
+	"dnsresources", "edgeorderresources", "elasticsanresources", "extendedlocationresources",
+	"featureresources", "guestconfigurationresources", "healthresourcechanges", "healthresources",
+	"insightresources", "iotsecurityresources", "kubernetesconfigurationresources", "maintenanceresources",
+	"managedservicesresources", "migrateresources", "networkresources", "patchassessmentresources",
+	"patchinstallationresources", "policyresources", "recoveryservicesresources", "resourcechanges",
+	"resourcecontainerchanges", "resourcecontainers", "resources", "securityresources",
+	"servicefabricresources", "servicehealthresources", "spotresources", "supportresources", "tagsresources",
+}
+
+// supportedOperators are the tabular operators which Azure Resource Graph supports.
+var supportedOperators = map[string]bool{
+	"count": true, "distinct": true, "extend": true, "join": true, "limit": true, "mv-expand": true,
+	"order": true, "parse": true, "project": true, "project-away": true, "sample": true,
+	"sample-distinct": true, "sort": true, "summarize": true, "take": true, "top": true, "union": true,
+	"where": true,
+}
+
+// supportedJoinKinds are the join kinds which Azure Resource Graph supports.
+var supportedJoinKinds = map[string]bool{
+	"innerunique": true, "inner": true, "leftouter": true, "fullouter": true,
+}
+
+// maxJoins is the maximum number of joins in a query.
+const maxJoins = 3
+
+// caseInsensitiveOperators are the case-insensitive counterparts of the case-sensitive operators.
+var caseInsensitiveOperators = map[string]string{
+	"==":  "=~",
+	"!=":  "!~",
+	"<>":  "!~",
+	"in":  "in~",
+	"!in": "!in~",
+}
+
+// Lint parses the query and checks it for common pitfalls of Azure Resource Graph queries, see the
+// Rule constants. The error is a [*SyntaxError] when the query can't be parsed.
+//
+// Example:
+//
+//	diagnostics, err := kql.Lint("resources | where type == 'Microsoft.Compute/virtualMachines'")
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	for _, d := range diagnostics {
+//		fmt.Println(d)
+//	}
+func Lint(query string) ([]Diagnostic, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return LintQuery(q), nil
+}
+
+// LintQuery checks the parsed query, see [Lint].
+func LintQuery(q *Query) []Diagnostic {
+	l := &linter{lets: map[string]bool{}}
+	for _, let := range q.Lets {
+		l.lets[strings.ToLower(let.Name.Name)] = true
+	}
+
+	Inspect(q, func(n Node) bool {
+		switch n := n.(type) {
+		case *Binary:
+			l.checkComparison(n)
+		case *Pipeline:
+			l.checkTable(n.Source)
+		case *Operator:
+			l.checkOperator(n, tableName(q.Body))
+		}
+		return true
+	})
+
+	l.checkOrder(q.Body)
+
+	sort.SliceStable(l.result, func(i, j int) bool {
+		return l.result[i].Pos.Offset < l.result[j].Pos.Offset
+	})
+
+	return l.result
+}
+
+type linter struct {
+	lets       map[string]bool
+	joins      int
+	crossJoins int
+	result     []Diagnostic
+}
+
+func (l *linter) report(pos Position, rule string, format string, args ...any) {
+	l.result = append(l.result, Diagnostic{Pos: pos, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) checkComparison(b *Binary) {
+	replacement, ok := caseInsensitiveOperators[b.Op]
+	if !ok {
+		return
+	}
+
+	name := columnPath(b.X)
+	if !strings.HasSuffix(strings.ToLower(name), "type") || !isStrings(b.Y) {
+		return
+	}
+
+	l.report(b.OpPos, RuleCaseSensitiveType, "'%s' compares '%s' case-sensitively, use '%s' as the case of resource types varies", b.Op, name, replacement)
+}
+
+// columnPath returns the column path like "properties.targetResourceType" of the expression,
+// also inside tostring(), or empty when the expression is not a column.
+func columnPath(x Expr) string {
+	switch x := x.(type) {
+	case *Ident:
+		return x.Name
+	case *Member:
+		if p := columnPath(x.X); p != "" {
+			return p + "." + x.Name.Name
+		}
+	case *Call:
+		if f, ok := x.Fun.(*Ident); ok && strings.EqualFold(f.Name, "tostring") && len(x.Args) == 1 {
+			return columnPath(x.Args[0])
+		}
+	}
+	return ""
+}
+
+// isStrings tells if the expression is a string literal or a list of them.
+func isStrings(x Expr) bool {
+	switch x := x.(type) {
+	case *BasicLit:
+		return x.Kind == TokenString
+	case *List:
+		for _, e := range x.Elems {
+			if !isStrings(e) {
+				return false
+			}
+		}
+		return len(x.Elems) > 0
+	}
+	return false
+}
+
+func (l *linter) checkTable(source Expr) {
+	if paren, ok := source.(*Paren); ok {
+		source = paren.X
+	}
+
+	id, ok := source.(*Ident)
+	if !ok || l.lets[strings.ToLower(id.Name)] || isTable(id.Name) {
+		return
+	}
+
+	l.report(id.NamePos, RuleUnknownTable, "Azure Resource Graph has no table '%s'", id.Name)
+}
+
+func isTable(name string) bool {
+	i := sort.SearchStrings(Tables, strings.ToLower(name))
+	return i < len(Tables) && Tables[i] == strings.ToLower(name)
+}
+
+// tableName returns the name of the table the expression reads, or empty when it is not a single table.
+func tableName(x Expr) string {
+	switch x := x.(type) {
+	case *Ident:
+		return strings.ToLower(x.Name)
+	case *Paren:
+		return tableName(x.X)
+	case *Pipeline:
+		return tableName(x.Source)
+	}
+	return ""
+}
+
+func (l *linter) checkOperator(op *Operator, left string) {
+	if !supportedOperators[op.Name] {
+		l.report(op.OpPos, RuleUnsupportedOperator, "Azure Resource Graph doesn't support the '%s' operator", op.Name)
+	}
+
+	for _, x := range op.Args {
+		if op.Name == "union" || op.Name == "join" {
+			l.checkTable(x)
+		}
+	}
+
+	if op.Name != "join" {
+		return
+	}
+
+	l.joins++
+	if l.joins == maxJoins+1 {
+		l.report(op.OpPos, RuleUnsupportedJoin, "Azure Resource Graph supports at most %d joins in a query", maxJoins)
+	}
+
+	for _, p := range op.Params {
+		kind, ok := p.X.(*BasicLit)
+		if name := p.Name.(*Ident).Name; name == "kind" && ok && !supportedJoinKinds[strings.ToLower(kind.Value)] {
+			l.report(kind.ValuePos, RuleUnsupportedJoin, "Azure Resource Graph doesn't support the join kind '%s', use innerunique, inner, leftouter or fullouter", kind.Value)
+		}
+	}
+
+	right := ""
+	if len(op.Args) > 0 {
+		right = tableName(op.Args[0])
+	}
+
+	if left == "" || right == "" || left == right || l.lets[left] || l.lets[right] {
+		return
+	}
+
+	if !(left == "resources" && right == "resourcecontainers" || left == "resourcecontainers" && right == "resources") {
+		l.crossJoins++
+		if l.crossJoins > 1 {
+			l.report(op.OpPos, RuleUnsupportedJoin, "Azure Resource Graph supports one cross-table join other than between resources and resourcecontainers, joining '%s' with '%s'", left, right)
+		}
+	}
+}
+
+// reorderingOperators are the operators which don't keep the order of the rows.
+var reorderingOperators = map[string]bool{
+	"summarize": true, "join": true, "union": true, "distinct": true, "sample": true, "sample-distinct": true,
+}
+
+// checkOrder reports the main pipeline when its results are not sorted at the end, as the results
+// are paged with skip tokens which only give consistent pages for sorted results.
+func (l *linter) checkOrder(p *Pipeline) {
+	if !isOrdered(p) {
+		l.report(p.Pos(), RuleUnorderedPaging, "the query has no final 'order by', so paged results may have duplicate or missing rows, e.g. add '| order by id asc'")
+	}
+}
+
+// isOrdered tells if the results of the pipeline are sorted at the end, are a single row or are not paged.
+func isOrdered(p *Pipeline) bool {
+	sorted := false
+	for i, op := range p.Operators {
+		switch {
+		case op.Name == "order" || op.Name == "sort" || op.Name == "top":
+			sorted = true
+		case op.Name == "count" || op.Name == "summarize" && len(op.By) == 0:
+			// A single row.
+			return true
+		case op.Name == "take" || op.Name == "limit":
+			// A final take picks the rows itself, and a take which fits a page is not paged,
+			// unless the later operators add rows.
+			sorted = i == len(p.Operators)-1 || fitsPage(op)
+		case reorderingOperators[op.Name] || op.Name == "mv-expand":
+			sorted = false
+		}
+	}
+
+	return sorted
+}
+
+// maxPageSize is the maximum number of rows in a page of Azure Resource Graph results.
+const maxPageSize = 1000
+
+// fitsPage tells if the count of take or limit is a number of rows which fits a single page.
+func fitsPage(op *Operator) bool {
+	if len(op.Args) != 1 {
+		return false
+	}
+
+	lit, ok := op.Args[0].(*BasicLit)
+	if !ok || lit.Kind != TokenNumber {
+		return false
+	}
+
+	n, err := strconv.Atoi(lit.Value)
+	return err == nil && n <= maxPageSize
+}
+
+// EnsureOrder returns the query with "| order by key asc" appended when its results are not sorted
+// at the end, see [RuleUnorderedPaging]. The query is returned as it is when it is already sorted,
+// returns a single row, ends in take or limit or takes at most a page of rows, or can't be parsed, in which case running it reports the error.
+// The error tells that the results don't have the key column, see [ProjectedColumns], or that
+// it can't be told, e.g. after "summarize ... by bin(...)", so the query needs its own final order.
+//
+// Example:
+//
+//	kql.EnsureOrder("resources | project id, name", "id")
+//	// resources | project id, name
+//	// | order by id asc
+func EnsureOrder(query string, key string) (string, error) {
+	q, err := Parse(query)
+	if err != nil || isOrdered(q.Body) {
+		return query, nil
+	}
+
+	// The order goes right after the last token, before a trailing semicolon and comments.
+	tokens, err := Tokenize(query)
+	if err != nil || len(tokens) < 2 {
+		return query, nil
+	}
+
+	if err := checkColumn(query, tokens, key); err != nil {
+		return "", err
+	}
+
+	last := tokens[len(tokens)-2]
+	if last.Is(";") && len(tokens) > 2 {
+		last = tokens[len(tokens)-3]
+	}
+
+	end := last.Pos.Offset + len(last.Text)
+	return query[:end] + "\n| order by " + key + " asc" + query[end:], nil
+}
+
+// checkColumn checks that the results of the query have the column. When the columns can't be told
+// from the query, the column must be mentioned by the last operator which picks the columns.
+func checkColumn(query string, tokens []Token, column string) error {
+	if columns, ok := ProjectedColumns(query); ok {
+		if indexColumn(columns, column) < 0 {
+			return fmt.Errorf("the query results have no column '%s' to order by, the columns are %s", column, strings.Join(columns, ", "))
+		}
+		return nil
+	}
+
+	// The tables of Azure Resource Graph have the id column, and so do the results until an operator picks the columns.
+	kept := true
+	for i, segment := range split(lastStatement(tokens), "|") {
+		if i == 0 || len(segment) == 0 {
+			continue
+		}
+
+		switch strings.ToLower(segment[0].Text) {
+		case "project", "project-keep", "distinct", "summarize", "make-series":
+			kept = mentions(segment[1:], column)
+		case "count":
+			kept = false
+		case "project-away", "project-rename":
+			kept = kept && !mentions(segment[1:], column)
+		}
+	}
+
+	if !kept {
+		return fmt.Errorf("can't tell if the query results have the column '%s' to order by, add a final 'order by' to the query", column)
+	}
+
+	return nil
+}
+
+// mentions tells if the tokens have the identifier.
+func mentions(tokens []Token, name string) bool {
+	for _, t := range tokens {
+		if t.Kind == TokenIdentifier && t.Text == name {
+			return true
+		}
+	}
+	return false
+}
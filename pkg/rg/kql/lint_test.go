@@ -0,0 +1,120 @@
+package kql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"resources | where type =~ 'microsoft.compute/virtualmachines' | order by id asc", nil},
+		{"resources | summarize count()", nil},
+		{"resources | where type == 'Microsoft.Compute/virtualMachines' | order by name asc", []string{
+			"line 1, column 24: '==' compares 'type' case-sensitively, use '=~' as the case of resource types varies (case-sensitive-type)",
+		}},
+		{"resources | where tostring(properties.targetResourceType) in ('a', 'b') | order by id asc", []string{
+			"line 1, column 59: 'in' compares 'properties.targetResourceType' case-sensitively, use 'in~' as the case of resource types varies (case-sensitive-type)",
+		}},
+		{"resources | order by name asc | summarize count() by type", []string{
+			"line 1, column 1: the query has no final 'order by', so paged results may have duplicate or missing rows, e.g. add '| order by id asc' (unordered-paging)",
+		}},
+		{"resources | join kind=leftanti (resourcecontainers) on subscriptionId | order by id asc", []string{
+			"line 1, column 23: Azure Resource Graph doesn't support the join kind 'leftanti', use innerunique, inner, leftouter or fullouter (unsupported-join)",
+		}},
+		{"resources | join (resourcecontainers) on subscriptionId | join (resourcecontainers) on subscriptionId | join (resourcecontainers) on subscriptionId | join (resourcecontainers) on subscriptionId | order by id asc", []string{
+			"line 1, column 151: Azure Resource Graph supports at most 3 joins in a query (unsupported-join)",
+		}},
+		{"resources | join (securityresources) on id | join (advisorresources) on id | order by id asc", []string{
+			"line 1, column 46: Azure Resource Graph supports one cross-table join other than between resources and resourcecontainers, joining 'resources' with 'advisorresources' (unsupported-join)",
+		}},
+		{"resources | make-series count() on timestamp step 1d | order by id asc", []string{
+			"line 1, column 13: Azure Resource Graph doesn't support the 'make-series' operator (unsupported-operator)",
+		}},
+		{"vms | order by id asc", []string{
+			"line 1, column 1: Azure Resource Graph has no table 'vms' (unknown-table)",
+		}},
+		{"let vms = resources | where type =~ 'microsoft.compute/virtualmachines';\nvms | order by id asc", nil},
+		{"resources | take 5000", nil},
+		{"resources | project id, name | limit 10", nil},
+		{"resources | take 100 | project id, name", nil},
+		{"resources | take 5000 | project id, name", []string{
+			"line 1, column 1: the query has no final 'order by', so paged results may have duplicate or missing rows, e.g. add '| order by id asc' (unordered-paging)",
+		}},
+		{"resources | take 100 | mv-expand properties.ipConfigurations", []string{
+			"line 1, column 1: the query has no final 'order by', so paged results may have duplicate or missing rows, e.g. add '| order by id asc' (unordered-paging)",
+		}},
+	}
+
+	for _, tt := range tests {
+		diagnostics, err := Lint(tt.query)
+		if err != nil {
+			t.Errorf("Lint(%q) failed: %v", tt.query, err)
+			continue
+		}
+
+		var got []string
+		for _, d := range diagnostics {
+			got = append(got, d.String())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("Lint(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	if _, err := Lint("resources | where"); err == nil {
+		t.Error("Lint succeeded with a syntax error, want an error")
+	}
+}
+
+func TestEnsureOrder(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"resources", "resources\n| order by id asc"},
+		{"resources | project id, name;  // all", "resources | project id, name\n| order by id asc;  // all"},
+		{"resources | project id, name | order by name asc", "resources | project id, name | order by name asc"},
+		{"resources | summarize count()", "resources | summarize count()"},
+		{"resources | summarize count() by id, bin(todatetime(properties.createdTime), 1d)", "resources | summarize count() by id, bin(todatetime(properties.createdTime), 1d)\n| order by id asc"},
+		{"resources | join kind=leftouter (resourcecontainers | project subscriptionId, subscriptionName = name) on subscriptionId", "resources | join kind=leftouter (resourcecontainers | project subscriptionId, subscriptionName = name) on subscriptionId\n| order by id asc"},
+		{"resources | take 10", "resources | take 10"},
+		{"resources | take 10 | project id, name", "resources | take 10 | project id, name"},
+		{"resources | where", "resources | where"},
+	}
+
+	for _, tt := range tests {
+		got, err := EnsureOrder(tt.query, "id")
+		if err != nil {
+			t.Errorf("EnsureOrder(%q) failed: %v", tt.query, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("EnsureOrder(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureOrderNoColumn(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"resources | distinct type", "the query results have no column 'id' to order by, the columns are type"},
+		{"resources | summarize count() by type, bin(todatetime(properties.createdTime), 1d)", "can't tell if the query results have the column 'id'"},
+		{"resources | join kind=leftouter (resourcecontainers | project subscriptionId, subscriptionName = name) on subscriptionId | project name, type, subscriptionName", "the query results have no column 'id' to order by, the columns are name, type, subscriptionName"},
+		{"resources | project-away id", "can't tell if the query results have the column 'id'"},
+		{"resources | project-keep name*", "can't tell if the query results have the column 'id'"},
+	}
+
+	for _, tt := range tests {
+		_, err := EnsureOrder(tt.query, "id")
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("EnsureOrder(%q) error %v, want %q", tt.query, err, tt.want)
+		}
+	}
+}
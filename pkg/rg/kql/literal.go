@@ -0,0 +1,161 @@
+// Package kql has helpers for the Kusto Query Language dialect of Azure Resource Graph: literals for
+// query parameters, and a parser and a linter which find problems in queries without running them.
+package kql
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is a KQL scalar type of query parameters.
+type Type string
+
+const (
+	TypeString   Type = "string"
+	TypeLong     Type = "long"
+	TypeReal     Type = "real"
+	TypeBool     Type = "bool"
+	TypeDatetime Type = "datetime"
+	TypeTimespan Type = "timespan"
+	TypeDynamic  Type = "dynamic"
+)
+
+// PossibleTypeValues returns the possible values for the Type const type.
+func PossibleTypeValues() []Type {
+	return []Type{TypeString, TypeLong, TypeReal, TypeBool, TypeDatetime, TypeTimespan, TypeDynamic}
+}
+
+// ParseType returns the type by its name. KQL synonyms like "int", "double" and "boolean" are accepted.
+func ParseType(name string) (Type, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "string":
+		return TypeString, nil
+	case "long", "int":
+		return TypeLong, nil
+	case "real", "double", "decimal":
+		return TypeReal, nil
+	case "bool", "boolean":
+		return TypeBool, nil
+	case "datetime", "date":
+		return TypeDatetime, nil
+	case "timespan", "time":
+		return TypeTimespan, nil
+	case "dynamic":
+		return TypeDynamic, nil
+	default:
+		return "", fmt.Errorf("unknown type '%s'", name)
+	}
+}
+
+// Parse converts the text, e.g. from a URL query or a command line flag, into the Go value of the type:
+// string, int64, float64, bool, [time.Time], [time.Duration] or any unmarshalled from JSON for dynamic.
+// Datetimes are in RFC 3339 format or dates like "2006-01-02", timespans are Go durations like "1h30m".
+func (t Type) Parse(text string) (any, error) {
+	switch t {
+	case TypeString:
+		return text, nil
+	case TypeLong:
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case TypeReal:
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case TypeBool:
+		return strconv.ParseBool(strings.TrimSpace(text))
+	case TypeDatetime:
+		text = strings.TrimSpace(text)
+		if v, err := time.Parse(time.RFC3339Nano, text); err == nil {
+			return v, nil
+		}
+		return time.Parse("2006-01-02", text)
+	case TypeTimespan:
+		return time.ParseDuration(strings.TrimSpace(text))
+	case TypeDynamic:
+		var v any
+		if err := jsoniter.UnmarshalFromString(text, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unknown type '%s'", t)
+	}
+}
+
+// Null returns the KQL null literal of the type, e.g. "long(null)". Strings can't be null in KQL,
+// so it is the empty string for them.
+func (t Type) Null() string {
+	if t == TypeString {
+		return "''"
+	}
+	return string(t) + "(null)"
+}
+
+// Quote returns the KQL string literal for s.
+func Quote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + "'"
+}
+
+// Literal returns the KQL literal for the Go value. Strings, integers, floats, bools, [time.Time] and
+// [time.Duration] become scalar literals, nil, slices, maps and structs become dynamic literals.
+func Literal(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "dynamic(null)", nil
+	case string:
+		return Quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return "datetime(" + v.UTC().Format(time.RFC3339Nano) + ")", nil
+	case time.Duration:
+		// A tick is 100 nanoseconds.
+		return "timespan(" + strconv.FormatInt(int64(v/100), 10) + "tick)", nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "long(" + strconv.FormatInt(rv.Int(), 10) + ")", nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "long(" + strconv.FormatUint(rv.Uint(), 10) + ")", nil
+	case reflect.Float32, reflect.Float64:
+		return "real(" + strconv.FormatFloat(rv.Float(), 'g', -1, 64) + ")", nil
+	case reflect.String:
+		return Quote(rv.String()), nil
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Pointer, reflect.Interface:
+		data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return "dynamic(" + string(data) + ")", nil
+	default:
+		return "", fmt.Errorf("no KQL literal for the value of type %T", v)
+	}
+}
+
+// identifierRegex matches the KQL identifiers which don't need quoting.
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsIdentifier tells if the name is a valid KQL identifier.
+func IsIdentifier(name string) bool {
+	return identifierRegex.MatchString(name)
+}
+
+// Let returns the let statement which binds the name to the literal of the value, e.g.
+// "let location = 'westeurope';". Prepending such statements to a query is the safe way to pass
+// parameters, as the values never become a part of the query text other than literals.
+func Let(name string, v any) (string, error) {
+	if !IsIdentifier(name) {
+		return "", fmt.Errorf("invalid parameter name '%s'", name)
+	}
+
+	literal, err := Literal(v)
+	if err != nil {
+		return "", fmt.Errorf("parameter '%s': %w", name, err)
+	}
+
+	return "let " + name + " = " + literal + ";", nil
+}
@@ -0,0 +1,70 @@
+package kql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{nil, "dynamic(null)"},
+		{"it's", `'it\'s'`},
+		{true, "true"},
+		{42, "long(42)"},
+		{uint8(7), "long(7)"},
+		{1.5, "real(1.5)"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)), "datetime(2024-01-02T02:04:05Z)"},
+		{90 * time.Minute, "timespan(54000000000tick)"},
+		{[]string{"a", "b"}, `dynamic(["a","b"])`},
+		{map[string]int{"a": 1}, `dynamic({"a":1})`},
+	}
+
+	for _, tt := range tests {
+		got, err := Literal(tt.value)
+		if err != nil {
+			t.Errorf("Literal(%v) failed: %v", tt.value, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Literal(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	if _, err := Literal(make(chan int)); err == nil {
+		t.Error("Literal(chan) succeeded, want an error")
+	}
+}
+
+func TestTypeNull(t *testing.T) {
+	for _, tt := range []struct {
+		t    Type
+		want string
+	}{
+		{TypeString, "''"},
+		{TypeLong, "long(null)"},
+		{TypeReal, "real(null)"},
+		{TypeBool, "bool(null)"},
+		{TypeDatetime, "datetime(null)"},
+		{TypeTimespan, "timespan(null)"},
+		{TypeDynamic, "dynamic(null)"},
+	} {
+		if got := tt.t.Null(); got != tt.want {
+			t.Errorf("%s.Null() = %s, want %s", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestLet(t *testing.T) {
+	got, err := Let("region", "westeurope")
+	if err != nil || got != "let region = 'westeurope';" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if _, err := Let("drop table", "x"); err == nil {
+		t.Error("Let succeeded with an invalid name, want an error")
+	}
+}
@@ -0,0 +1,801 @@
+package kql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse parses the query in the Azure Resource Graph dialect of KQL. The error is a [*SyntaxError]
+// with the line and column of the first error.
+//
+// The parser knows the expressions and the arguments of the tabular operators which Azure Resource
+// Graph supports. The arguments of other operators are kept as raw text, see [Operator.Raw], and
+// [Lint] reports the operators as unsupported.
+func Parse(query string) (*Query, error) {
+	tokens, err := Tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{text: query, tokens: tokens}
+	result, err := p.query()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+type parser struct {
+	text   string
+	tokens []Token
+	i      int
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) peekAt(n int) Token {
+	if p.i+n < len(p.tokens) {
+		return p.tokens[p.i+n]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parser) next() Token {
+	t := p.tokens[p.i]
+	if t.Kind != TokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(t Token, format string, args ...any) error {
+	return &SyntaxError{Pos: t.Pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(text string) (Token, error) {
+	t := p.next()
+	if !t.Is(text) {
+		return t, p.errorf(t, "expected '%s', found %s", text, describe(t))
+	}
+	return t, nil
+}
+
+// accept consumes the next token if it is the identifier or punctuation.
+func (p *parser) accept(text string) bool {
+	if p.peek().Is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) query() (*Query, error) {
+	result := &Query{}
+	for {
+		for p.accept(";") {
+		}
+
+		if p.peek().Kind == TokenEOF {
+			switch {
+			case result.Body == nil && len(result.Lets) > 0:
+				return nil, p.errorf(p.peek(), "the query has no tabular expression after the let statements")
+			case result.Body == nil:
+				return nil, p.errorf(p.peek(), "the query is empty")
+			}
+			return result, nil
+		}
+
+		if result.Body != nil {
+			return nil, p.errorf(p.peek(), "expected ';' or the end of the query, found %s", describe(p.peek()))
+		}
+
+		if p.peek().Is("let") {
+			let, err := p.let()
+			if err != nil {
+				return nil, err
+			}
+			result.Lets = append(result.Lets, let)
+
+			if !p.peek().Is(";") {
+				return nil, p.errorf(p.peek(), "expected ';' after the let statement, found %s", describe(p.peek()))
+			}
+			continue
+		}
+
+		body, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		result.Body = body
+	}
+}
+
+func (p *parser) let() (*LetStmt, error) {
+	letToken := p.next()
+	name := p.next()
+	if name.Kind != TokenIdentifier {
+		return nil, p.errorf(name, "expected a name after 'let', found %s", describe(name))
+	}
+
+	if _, err := p.expect("="); err != nil {
+		return nil, err
+	}
+
+	value, err := p.tabularOrScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	return &LetStmt{LetPos: letToken.Pos, Name: &Ident{NamePos: name.Pos, Name: name.Text}, Value: value}, nil
+}
+
+// tabularOrScalar parses an expression, which is a pipeline when it is followed by operators.
+func (p *parser) tabularOrScalar() (Expr, error) {
+	source, err := p.source()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.peek().Is("|") {
+		return source, nil
+	}
+
+	return p.operators(source)
+}
+
+func (p *parser) pipeline() (*Pipeline, error) {
+	source, err := p.source()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.operators(source)
+}
+
+// source parses the source of a pipeline: an expression like a table name, or a leading union.
+func (p *parser) source() (Expr, error) {
+	if t := p.peek(); t.Is("union") && !p.peekAt(1).Is("|") {
+		return p.operator()
+	}
+
+	return p.expr()
+}
+
+func (p *parser) operators(source Expr) (*Pipeline, error) {
+	result := &Pipeline{Source: source}
+	for p.accept("|") {
+		op, err := p.operator()
+		if err != nil {
+			return nil, err
+		}
+		result.Operators = append(result.Operators, op)
+	}
+
+	return result, nil
+}
+
+func (p *parser) operator() (*Operator, error) {
+	t := p.next()
+	if t.Kind != TokenIdentifier {
+		return nil, p.errorf(t, "expected a query operator after '|', found %s", describe(t))
+	}
+
+	op := &Operator{OpPos: t.Pos, Name: strings.ToLower(t.Text)}
+	var err error
+	switch op.Name {
+	case "where", "filter", "take", "limit", "sample":
+		var x Expr
+		if x, err = p.expr(); err == nil {
+			op.Args = []Expr{x}
+		}
+	case "project", "extend", "distinct", "project-reorder":
+		op.Args, err = p.list(p.named)
+	case "project-away", "project-keep":
+		op.Args, err = p.list(p.columnPattern)
+	case "project-rename":
+		op.Args, err = p.list(p.rename)
+	case "summarize":
+		if op.Params, err = p.params(); err != nil {
+			break
+		}
+		if !p.peek().Is("by") {
+			if op.Args, err = p.list(p.named); err != nil {
+				break
+			}
+		}
+		if p.accept("by") {
+			op.By, err = p.list(p.named)
+		}
+	case "order", "sort":
+		if _, err = p.expect("by"); err == nil {
+			op.By, err = p.list(p.sorted)
+		}
+	case "top":
+		var x Expr
+		if x, err = p.expr(); err != nil {
+			break
+		}
+		op.Args = []Expr{x}
+		if _, err = p.expect("by"); err == nil {
+			op.By, err = p.list(p.sorted)
+		}
+	case "count":
+	case "join", "lookup":
+		err = p.join(op)
+	case "union":
+		if op.Params, err = p.params(); err == nil {
+			op.Args, err = p.list(p.primary)
+		}
+	case "mv-expand", "mvexpand":
+		if op.Params, err = p.params(); err != nil {
+			break
+		}
+		if op.Args, err = p.list(p.expandItem); err != nil {
+			break
+		}
+		if p.accept("limit") {
+			op.Limit, err = p.expr()
+		}
+	case "sample-distinct":
+		var x, y Expr
+		if x, err = p.expr(); err != nil {
+			break
+		}
+		if _, err = p.expect("of"); err != nil {
+			break
+		}
+		if y, err = p.expr(); err == nil {
+			op.Args = []Expr{x, y}
+		}
+	default:
+		op.Raw, err = p.raw()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+func (p *parser) join(op *Operator) error {
+	var err error
+	if op.Params, err = p.params(); err != nil {
+		return err
+	}
+
+	right, err := p.primary()
+	if err != nil {
+		return err
+	}
+	op.Args = []Expr{right}
+
+	if _, err := p.expect("on"); err != nil {
+		return err
+	}
+
+	op.On, err = p.list(p.expr)
+	return err
+}
+
+// operatorParams are the names of the operator parameters like "kind=leftouter", other than hints.
+var operatorParams = map[string]bool{
+	"kind":           true,
+	"withsource":     true,
+	"isfuzzy":        true,
+	"bagexpansion":   true,
+	"with_itemindex": true,
+}
+
+// params parses the operator parameters like "kind=leftouter" or "hint.strategy=shuffle".
+func (p *parser) params() ([]*Assign, error) {
+	var result []*Assign
+	for {
+		t := p.peek()
+		if t.Kind != TokenIdentifier {
+			return result, nil
+		}
+
+		name := strings.ToLower(t.Text)
+		n := 1
+		if name == "hint" && p.peekAt(1).Is(".") && p.peekAt(2).Kind == TokenIdentifier {
+			name += "." + strings.ToLower(p.peekAt(2).Text)
+			n = 3
+		} else if !operatorParams[name] {
+			return result, nil
+		}
+
+		if !p.peekAt(n).Is("=") {
+			return result, nil
+		}
+
+		p.i += n + 1
+		value := p.next()
+		if value.Kind != TokenIdentifier && value.Kind != TokenString && value.Kind != TokenNumber {
+			return nil, p.errorf(value, "expected the value of '%s', found %s", name, describe(value))
+		}
+
+		result = append(result, &Assign{
+			Name: &Ident{NamePos: t.Pos, Name: name},
+			X:    &BasicLit{ValuePos: value.Pos, Kind: value.Kind, Value: value.Text},
+		})
+	}
+}
+
+// list parses the comma-separated items.
+func (p *parser) list(item func() (Expr, error)) ([]Expr, error) {
+	var result []Expr
+	for {
+		x, err := item()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, x)
+
+		if !p.accept(",") {
+			return result, nil
+		}
+	}
+}
+
+// named parses "name = expr" or "expr".
+func (p *parser) named() (Expr, error) {
+	if t := p.peek(); t.Kind == TokenIdentifier && p.peekAt(1).Is("=") {
+		p.i += 2
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return &Assign{Name: &Ident{NamePos: t.Pos, Name: t.Text}, X: x}, nil
+	}
+
+	if p.peek().Is("*") {
+		return &Star{StarPos: p.next().Pos}, nil
+	}
+
+	return p.expr()
+}
+
+// columnPattern parses a column name with wildcards like "properties*".
+func (p *parser) columnPattern() (Expr, error) {
+	start := p.peek()
+	var sb strings.Builder
+	for t := p.peek(); t.Kind == TokenIdentifier || t.Is("*"); t = p.peek() {
+		if sb.Len() > 0 && p.tokens[p.i-1].Pos.Offset+len(p.tokens[p.i-1].Text) != t.Pos.Offset {
+			break
+		}
+		sb.WriteString(p.next().Text)
+	}
+
+	if sb.Len() == 0 {
+		return nil, p.errorf(start, "expected a column name, found %s", describe(start))
+	}
+
+	return &Ident{NamePos: start.Pos, Name: sb.String()}, nil
+}
+
+// rename parses "new = old" of project-rename.
+func (p *parser) rename() (Expr, error) {
+	name := p.next()
+	if name.Kind != TokenIdentifier {
+		return nil, p.errorf(name, "expected a column name, found %s", describe(name))
+	}
+
+	if _, err := p.expect("="); err != nil {
+		return nil, err
+	}
+
+	old := p.next()
+	if old.Kind != TokenIdentifier {
+		return nil, p.errorf(old, "expected a column name, found %s", describe(old))
+	}
+
+	return &Assign{Name: &Ident{NamePos: name.Pos, Name: name.Text}, X: &Ident{NamePos: old.Pos, Name: old.Text}}, nil
+}
+
+// sorted parses "expr [asc|desc] [nulls first|last]".
+func (p *parser) sorted() (Expr, error) {
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Sorted{X: x}
+	if t := p.peek(); t.Is("asc") || t.Is("desc") {
+		result.Order = strings.ToLower(p.next().Text)
+	}
+
+	if p.accept("nulls") {
+		t := p.next()
+		if !t.Is("first") && !t.Is("last") {
+			return nil, p.errorf(t, "expected 'first' or 'last' after 'nulls', found %s", describe(t))
+		}
+		result.Nulls = strings.ToLower(t.Text)
+	}
+
+	return result, nil
+}
+
+// expandItem parses "[name =] expr [to typeof(type)]" of mv-expand.
+func (p *parser) expandItem() (Expr, error) {
+	x, err := p.named()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept("to") {
+		if _, err := p.expect("typeof"); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect("("); err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.Kind != TokenIdentifier {
+			return nil, p.errorf(t, "expected a type name, found %s", describe(t))
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	return x, nil
+}
+
+// raw returns the text up to the next pipe, semicolon or closing bracket outside of brackets.
+func (p *parser) raw() (string, error) {
+	start := p.peek().Pos.Offset
+	end := start
+	var open []Token
+	for {
+		t := p.peek()
+		switch {
+		case t.Kind == TokenEOF:
+			if len(open) > 0 {
+				return "", p.errorf(open[len(open)-1], "'%s' is not closed", open[len(open)-1].Text)
+			}
+			return strings.TrimSpace(p.text[start:end]), nil
+		case len(open) == 0 && (t.Is("|") || t.Is(";") || t.Is(")") || t.Is("]") || t.Is("}")):
+			return strings.TrimSpace(p.text[start:end]), nil
+		case t.Kind == TokenPunctuation && brackets[t.Text] != "":
+			open = append(open, t)
+		case t.Is(")") || t.Is("]") || t.Is("}"):
+			if brackets[open[len(open)-1].Text] != t.Text {
+				return "", p.errorf(t, "unexpected '%s'", t.Text)
+			}
+			open = open[:len(open)-1]
+		}
+
+		p.next()
+		end = t.Pos.Offset + len(t.Text)
+	}
+}
+
+// comparisonOperators are the binary operators of comparisons, all of the same precedence.
+var comparisonOperators = map[string]bool{
+	"==": true, "!=": true, "<>": true, "<": true, ">": true, "<=": true, ">=": true, "=~": true, "!~": true,
+	"contains": true, "!contains": true, "contains_cs": true, "!contains_cs": true,
+	"has": true, "!has": true, "has_cs": true, "!has_cs": true,
+	"hasprefix": true, "!hasprefix": true, "hasprefix_cs": true, "!hasprefix_cs": true,
+	"hassuffix": true, "!hassuffix": true, "hassuffix_cs": true, "!hassuffix_cs": true,
+	"startswith": true, "!startswith": true, "startswith_cs": true, "!startswith_cs": true,
+	"endswith": true, "!endswith": true, "endswith_cs": true, "!endswith_cs": true,
+	"like": true, "!like": true,
+}
+
+// listOperators are the binary operators with a list on the right side.
+var listOperators = map[string]bool{
+	"in": true, "!in": true, "in~": true, "!in~": true, "has_any": true, "has_all": true,
+}
+
+func (p *parser) expr() (Expr, error) {
+	return p.binary(0)
+}
+
+// binary parses the binary expressions of the precedence level and higher:
+// 0 is or, 1 is and, 2 are comparisons, 3 is + and -, 4 is *, / and %.
+func (p *parser) binary(level int) (Expr, error) {
+	if level > 4 {
+		return p.unary()
+	}
+
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		op := t.Text
+		if t.Kind == TokenIdentifier {
+			op = strings.ToLower(op)
+		} else if t.Kind != TokenPunctuation {
+			return x, nil
+		}
+
+		var y Expr
+		switch {
+		case level == 0 && op == "or", level == 1 && op == "and",
+			level == 3 && (op == "+" || op == "-"), level == 4 && (op == "*" || op == "/" || op == "%"):
+			p.next()
+			y, err = p.binary(level + 1)
+		case level == 2 && comparisonOperators[op]:
+			p.next()
+			y, err = p.binary(level + 1)
+		case level == 2 && op == "matches" && p.peekAt(1).Is("regex"):
+			p.i += 2
+			op = "matches regex"
+			y, err = p.binary(level + 1)
+		case level == 2 && listOperators[op]:
+			p.next()
+			y, err = p.parenList()
+		case level == 2 && (op == "between" || op == "!between"):
+			p.next()
+			y, err = p.between()
+		default:
+			return x, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		x = &Binary{X: x, OpPos: t.Pos, Op: op, Y: y}
+	}
+}
+
+// parenList parses "(a, b, ...)", or a subquery in parentheses.
+func (p *parser) parenList() (Expr, error) {
+	lparen, err := p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	elems, err := p.parenElems()
+	if err != nil {
+		return nil, err
+	}
+
+	return &List{Lbrack: lparen.Pos, Open: "(", Elems: elems}, nil
+}
+
+// parenElems parses the comma-separated expressions after '(' up to and including ')'.
+// The first expression can be a subquery.
+func (p *parser) parenElems() ([]Expr, error) {
+	first, err := p.tabularOrScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Expr{first}
+	for p.accept(",") {
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, x)
+	}
+
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// between parses "(from .. to)".
+func (p *parser) between() (Expr, error) {
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	from, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(".."); err != nil {
+		return nil, err
+	}
+
+	to, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return &Range{From: from, To: to}, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	if t := p.peek(); t.Is("-") || t.Is("+") || t.Is("!") {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{OpPos: t.Pos, Op: t.Text, X: x}, nil
+	}
+
+	return p.postfix()
+}
+
+func (p *parser) postfix() (Expr, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch t := p.peek(); {
+		case t.Is("."):
+			p.next()
+			name := p.next()
+			if name.Kind != TokenIdentifier {
+				return nil, p.errorf(name, "expected a name after '.', found %s", describe(name))
+			}
+			x = &Member{X: x, Name: &Ident{NamePos: name.Pos, Name: name.Text}}
+		case t.Is("["):
+			p.next()
+			index, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &Index{X: x, Index: index}
+		case t.Is("(") && isIdent(x):
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			x = &Call{Fun: x, Args: args}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func isIdent(x Expr) bool {
+	_, ok := x.(*Ident)
+	return ok
+}
+
+// args parses the arguments of a function call in parentheses.
+func (p *parser) args() ([]Expr, error) {
+	p.next()
+	if p.accept(")") {
+		return nil, nil
+	}
+
+	args, err := p.list(p.named)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+// rawLiterals are the literals like datetime(2024-01-01) with the values which are not expressions.
+var rawLiterals = map[string]bool{
+	"datetime": true,
+	"date":     true,
+	"timespan": true,
+	"time":     true,
+	"guid":     true,
+}
+
+func (p *parser) primary() (Expr, error) {
+	t := p.peek()
+	switch {
+	case t.Kind == TokenString || t.Kind == TokenNumber:
+		p.next()
+		return &BasicLit{ValuePos: t.Pos, Kind: t.Kind, Value: t.Text}, nil
+	case t.Kind == TokenIdentifier && rawLiterals[strings.ToLower(t.Text)] && p.peekAt(1).Is("("):
+		p.next()
+		end, err := p.closing()
+		if err != nil {
+			return nil, err
+		}
+		return &BasicLit{ValuePos: t.Pos, Kind: TokenIdentifier, Value: p.text[t.Pos.Offset:end]}, nil
+	case t.Kind == TokenIdentifier:
+		p.next()
+		return &Ident{NamePos: t.Pos, Name: t.Text}, nil
+	case t.Is("("):
+		p.next()
+		elems, err := p.parenElems()
+		if err != nil {
+			return nil, err
+		}
+		if len(elems) > 1 {
+			return &List{Lbrack: t.Pos, Open: "(", Elems: elems}, nil
+		}
+		return &Paren{Lparen: t.Pos, X: elems[0]}, nil
+	case t.Is("["):
+		p.next()
+		result := &List{Lbrack: t.Pos, Open: "["}
+		if !p.accept("]") {
+			elems, err := p.list(p.expr)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			result.Elems = elems
+		}
+		return result, nil
+	case t.Is("{"):
+		return p.object()
+	default:
+		return nil, p.errorf(t, "expected an expression, found %s", describe(t))
+	}
+}
+
+// closing skips the parenthesised tokens after the current one and returns the offset after the ')'.
+func (p *parser) closing() (int, error) {
+	open := p.next()
+	depth := 1
+	for {
+		t := p.next()
+		switch {
+		case t.Kind == TokenEOF:
+			return 0, p.errorf(open, "'(' is not closed")
+		case t.Is("("):
+			depth++
+		case t.Is(")"):
+			depth--
+			if depth == 0 {
+				return t.Pos.Offset + 1, nil
+			}
+		}
+	}
+}
+
+// object parses "{key: value, ...}" of dynamic literals.
+func (p *parser) object() (Expr, error) {
+	lbrace := p.next()
+	result := &Object{Lbrace: lbrace.Pos}
+	if p.accept("}") {
+		return result, nil
+	}
+
+	for {
+		key := p.next()
+		if key.Kind != TokenString && key.Kind != TokenIdentifier {
+			return nil, p.errorf(key, "expected a key, found %s", describe(key))
+		}
+
+		if _, err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+
+		result.Keys = append(result.Keys, &BasicLit{ValuePos: key.Pos, Kind: key.Kind, Value: key.Text})
+		result.Values = append(result.Values, value)
+
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	if _, err := p.expect("}"); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
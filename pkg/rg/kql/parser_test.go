@@ -0,0 +1,81 @@
+package kql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse("let min = 2;\nresources\n| where size > min\n| summarize n = count() by type\n| join kind=leftouter (resourcecontainers) on subscriptionId\n| order by n desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(q.Lets) != 1 || q.Lets[0].Name.Name != "min" || q.Pos() != (Position{Offset: 0, Line: 1, Column: 1}) {
+		t.Errorf("unexpected let statements %+v", q.Lets)
+	}
+
+	if source, ok := q.Body.Source.(*Ident); !ok || source.Name != "resources" || source.NamePos.Line != 2 {
+		t.Errorf("unexpected source %+v", q.Body.Source)
+	}
+
+	var names []string
+	for _, op := range q.Body.Operators {
+		names = append(names, op.Name)
+	}
+	if len(names) != 4 || names[0] != "where" || names[1] != "summarize" || names[2] != "join" || names[3] != "order" {
+		t.Fatalf("got operators %v", names)
+	}
+
+	summarize := q.Body.Operators[1]
+	if len(summarize.Args) != 1 || len(summarize.By) != 1 || summarize.OpPos != (Position{Offset: 44, Line: 4, Column: 3}) {
+		t.Errorf("unexpected summarize %+v", summarize)
+	}
+
+	join := q.Body.Operators[2]
+	if len(join.Params) != 1 || len(join.Args) != 1 || len(join.On) != 1 {
+		t.Errorf("unexpected join %+v", join)
+	}
+}
+
+func TestParseRaw(t *testing.T) {
+	q, err := Parse("resources | parse name with 'vm-' number:int")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if op := q.Body.Operators[0]; op.Name != "parse" || op.Raw != "name with 'vm-' number:int" {
+		t.Errorf("got %s with raw %q", op.Name, op.Raw)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"resources | where name == 'a", "line 1, column 27: unterminated string"},
+		{"resources | where (name == 'a'", "line 1, column 31: expected ')', found end of query"},
+		{"resources\n| where name ==", "line 2, column 16: expected an expression, found end of query"},
+		{"resources | project name,", "line 1, column 26: expected an expression, found end of query"},
+		{"resources | where name # 1", "line 1, column 24: unexpected character '#'"},
+		{"resources | where name == 'a')", "line 1, column 30: expected ';' or the end of the query, found ')'"},
+		{"let x = 1;\nresources | where x > 1 | order by name asc", ""},
+		{"resources | where name =~ 'a' // ')\n| project name", ""},
+	}
+
+	for _, tt := range tests {
+		err := Check(tt.query)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("Check(%q) failed: %v", tt.query, err)
+			}
+			continue
+		}
+
+		var syntaxError *SyntaxError
+		if !errors.As(err, &syntaxError) || err.Error() != tt.want {
+			t.Errorf("Check(%q) error %v, want %q", tt.query, err, tt.want)
+		}
+	}
+}
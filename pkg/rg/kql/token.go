@@ -0,0 +1,345 @@
+package kql
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind is the kind of a KQL token.
+type TokenKind int
+
+const (
+	// TokenEOF is the end of the query.
+	TokenEOF TokenKind = iota
+
+	// TokenIdentifier is a name, a keyword or a word operator like "project-away", "in~" or "!contains".
+	TokenIdentifier
+
+	// TokenString is a string literal including its quotes and prefixes.
+	TokenString
+
+	// TokenNumber is a number literal, possibly with a unit like "1d" or "10ms".
+	TokenNumber
+
+	// TokenPunctuation is an operator or punctuation like "|", "==" or "(".
+	TokenPunctuation
+
+	// TokenComment is a "//" comment up to the end of the line. [Tokenize] skips the comments.
+	TokenComment
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenEOF:
+		return "end of query"
+	case TokenIdentifier:
+		return "identifier"
+	case TokenString:
+		return "string"
+	case TokenNumber:
+		return "number"
+	case TokenPunctuation:
+		return "punctuation"
+	case TokenComment:
+		return "comment"
+	default:
+		return fmt.Sprintf("TokenKind(%d)", int(k))
+	}
+}
+
+// Position is a position in the query text.
+type Position struct {
+	// Offset is the byte offset, starting at 0.
+	Offset int
+
+	// Line is the line number, starting at 1.
+	Line int
+
+	// Column is the column number in characters, starting at 1.
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Token is a lexical token of a KQL query.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  Position
+}
+
+// Is tells if the token is the identifier or punctuation with the text, identifiers compare case-insensitively.
+func (t Token) Is(text string) bool {
+	switch t.Kind {
+	case TokenIdentifier:
+		return strings.EqualFold(t.Text, text)
+	case TokenPunctuation:
+		return t.Text == text
+	default:
+		return false
+	}
+}
+
+// SyntaxError is an error in the query text at a position.
+type SyntaxError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// punctuations are the operators and punctuation, the longer ones first.
+var punctuations = []string{
+	"==", "!=", "=~", "!~", "<>", "<=", ">=", "=>", "..",
+	"|", ",", "(", ")", "[", "]", "{", "}", "=", "<", ">", "+", "-", "*", "/", "%", ".", ";", ":", "!", "?",
+}
+
+// hyphenatedOperators are the query operators with hyphens in their names, which are lexed as single identifiers.
+var hyphenatedOperators = map[string]bool{
+	"project-away":    true,
+	"project-keep":    true,
+	"project-rename":  true,
+	"project-reorder": true,
+	"mv-expand":       true,
+	"mv-apply":        true,
+	"make-series":     true,
+	"sample-distinct": true,
+}
+
+// Tokenize splits the query into tokens, the last one is [TokenEOF]. Comments are skipped.
+// The error is a [*SyntaxError] for unterminated strings and unexpected characters.
+func Tokenize(query string) ([]Token, error) {
+	return tokenize(query, false)
+}
+
+// tokenize splits the query into tokens, including the comments when asked.
+func tokenize(query string, comments bool) ([]Token, error) {
+	l := lexer{text: query, pos: Position{Line: 1, Column: 1}, comments: comments}
+	var result []Token
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, t)
+		if t.Kind == TokenEOF {
+			return result, nil
+		}
+	}
+}
+
+type lexer struct {
+	text     string
+	pos      Position
+	comments bool
+}
+
+func (l *lexer) peek(n int) byte {
+	if l.pos.Offset+n < len(l.text) {
+		return l.text[l.pos.Offset+n]
+	}
+	return 0
+}
+
+// advance moves the position n bytes forward.
+func (l *lexer) advance(n int) {
+	end := l.pos.Offset + n
+	for l.pos.Offset < end {
+		r, size := utf8.DecodeRuneInString(l.text[l.pos.Offset:])
+		l.pos.Offset += size
+		if r == '\n' {
+			l.pos.Line++
+			l.pos.Column = 1
+		} else {
+			l.pos.Column++
+		}
+	}
+}
+
+func (l *lexer) errorf(pos Position, format string, args ...any) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) next() (Token, error) {
+	l.skipSpaceAndComments()
+
+	start := l.pos
+	if start.Offset >= len(l.text) {
+		return Token{Kind: TokenEOF, Pos: start}, nil
+	}
+
+	if l.comments && strings.HasPrefix(l.text[start.Offset:], "//") {
+		end := strings.IndexByte(l.text[start.Offset:], '\n')
+		if end < 0 {
+			end = len(l.text) - start.Offset
+		}
+		l.advance(end)
+		return Token{Kind: TokenComment, Text: strings.TrimRight(l.text[start.Offset:l.pos.Offset], " \t\r"), Pos: start}, nil
+	}
+
+	c := l.peek(0)
+	switch {
+	case c == '\'' || c == '"' || c == '`' && strings.HasPrefix(l.text[start.Offset:], "```"):
+		return l.string(start, 0)
+	case (c == '@' || c == 'h' || c == 'H') && (l.peek(1) == '\'' || l.peek(1) == '"'):
+		return l.string(start, 1)
+	case (c == 'h' || c == 'H') && l.peek(1) == '@' && (l.peek(2) == '\'' || l.peek(2) == '"'):
+		return l.string(start, 2)
+	case isDigit(c) || c == '.' && isDigit(l.peek(1)):
+		return l.number(start), nil
+	case isIdentifierStart(c):
+		return l.identifier(start), nil
+	case c == '!' && isIdentifierStart(l.peek(1)):
+		if t, ok := l.negatedOperator(start); ok {
+			return t, nil
+		}
+	}
+
+	for _, p := range punctuations {
+		if strings.HasPrefix(l.text[start.Offset:], p) {
+			l.advance(len(p))
+			return Token{Kind: TokenPunctuation, Text: p, Pos: start}, nil
+		}
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.text[start.Offset:])
+	return Token{}, l.errorf(start, "unexpected character %q", r)
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos.Offset < len(l.text) {
+		c := l.peek(0)
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.advance(1)
+		case c == '/' && l.peek(1) == '/' && !l.comments:
+			end := strings.IndexByte(l.text[l.pos.Offset:], '\n')
+			if end < 0 {
+				end = len(l.text) - l.pos.Offset
+			}
+			l.advance(end)
+		default:
+			return
+		}
+	}
+}
+
+// string lexes the string literal after the prefix of the given length, e.g. "@" for verbatim strings.
+func (l *lexer) string(start Position, prefix int) (Token, error) {
+	verbatim := strings.Contains(l.text[start.Offset:start.Offset+prefix], "@")
+	l.advance(prefix)
+
+	if strings.HasPrefix(l.text[l.pos.Offset:], "```") {
+		end := strings.Index(l.text[l.pos.Offset+3:], "```")
+		if end < 0 {
+			return Token{}, l.errorf(start, "unterminated multi-line string")
+		}
+		l.advance(end + 6)
+		return Token{Kind: TokenString, Text: l.text[start.Offset:l.pos.Offset], Pos: start}, nil
+	}
+
+	quote := l.peek(0)
+	l.advance(1)
+	for {
+		c := l.peek(0)
+		switch {
+		case l.pos.Offset >= len(l.text) || c == '\n':
+			return Token{}, l.errorf(start, "unterminated string")
+		case c == '\\' && !verbatim:
+			l.advance(2)
+		case c == quote && verbatim && l.peek(1) == quote:
+			l.advance(2)
+		case c == quote:
+			l.advance(1)
+			return Token{Kind: TokenString, Text: l.text[start.Offset:l.pos.Offset], Pos: start}, nil
+		default:
+			l.advance(1)
+		}
+	}
+}
+
+// number lexes the number with its unit, e.g. "10", "1.5e3", "1d" or "0x1f".
+func (l *lexer) number(start Position) Token {
+	for {
+		c := l.peek(0)
+		switch {
+		case isDigit(c) || isLetter(c) || c == '_':
+			l.advance(1)
+		case c == '.' && isDigit(l.peek(1)):
+			l.advance(1)
+		case (c == '+' || c == '-') && (l.peek(-1) == 'e' || l.peek(-1) == 'E') && isDigit(l.peek(1)):
+			l.advance(1)
+		default:
+			return Token{Kind: TokenNumber, Text: l.text[start.Offset:l.pos.Offset], Pos: start}
+		}
+	}
+}
+
+// identifier lexes the identifier, merging the hyphenated operators like "project-away" and "in~".
+func (l *lexer) identifier(start Position) Token {
+	for isIdentifierPart(l.peek(0)) {
+		l.advance(1)
+	}
+
+	if l.peek(0) == '-' && isLetter(l.peek(1)) {
+		end := l.pos.Offset + 1
+		for end < len(l.text) && isIdentifierPart(l.text[end]) {
+			end++
+		}
+		if hyphenatedOperators[strings.ToLower(l.text[start.Offset:end])] {
+			l.advance(end - l.pos.Offset)
+		}
+	}
+
+	if l.peek(0) == '~' {
+		word := strings.ToLower(l.text[start.Offset:l.pos.Offset])
+		if word == "in" || word == "!in" {
+			l.advance(1)
+		}
+	}
+
+	return Token{Kind: TokenIdentifier, Text: l.text[start.Offset:l.pos.Offset], Pos: start}
+}
+
+// negatedOperator lexes the negated word operator like "!contains" or "!in~", if it is one.
+func (l *lexer) negatedOperator(start Position) (Token, bool) {
+	end := start.Offset + 1
+	for end < len(l.text) && isIdentifierPart(l.text[end]) {
+		end++
+	}
+
+	word := strings.ToLower(l.text[start.Offset:end])
+	if word == "!in" && end < len(l.text) && l.text[end] == '~' {
+		end++
+		word += "~"
+	}
+
+	if !comparisonOperators[word] && !listOperators[word] && word != "!between" {
+		return Token{}, false
+	}
+
+	l.advance(end - start.Offset)
+	return Token{Kind: TokenIdentifier, Text: l.text[start.Offset:end], Pos: start}, true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentifierStart(c byte) bool {
+	return isLetter(c) || c == '_' || c == '$'
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c)
+}
@@ -0,0 +1,235 @@
+//go:build go1.18
+// +build go1.18
+
+// Package lib is a library of saved Azure Resource Graph queries, loaded from .kql files with
+// the metadata in front-matter: name, description, parameters with their types, default scopes
+// and the expected result type. See [Parse] for the file format.
+//
+// The queries are executed by name with the parameters bound as let statements, so that the
+// values never become a part of the query text other than literals.
+//
+// Example:
+//
+//	if err := lib.LoadDir("queries"); err != nil {
+//		panic(err)
+//	}
+//
+//	ips, err := lib.Exec[PublicIP](ctx, "public-ips", lib.Params{"region": "westeurope"}, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+package lib
+
+import (
+	"context"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Extension is the file extension of the query files.
+const Extension = ".kql"
+
+// Library is a set of saved queries by name. It is safe for concurrent use.
+type Library struct {
+	mu      sync.RWMutex
+	queries map[string]*Query
+}
+
+// New returns an empty library.
+func New() *Library {
+	return &Library{queries: map[string]*Query{}}
+}
+
+// Default is the library used by the package-level functions.
+var Default = New()
+
+// Add validates the query and adds it to the library. It is an error when the library
+// already has a query with the same name.
+func (l *Library) Add(q *Query) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if existing, ok := l.queries[q.Name]; ok {
+		if existing.Path != "" {
+			return fmt.Errorf("duplicate query '%s', already loaded from '%s'", q.Name, existing.Path)
+		}
+		return fmt.Errorf("duplicate query '%s'", q.Name)
+	}
+
+	l.queries[q.Name] = q
+	return nil
+}
+
+// LoadDir loads the .kql files in the directory and its subdirectories.
+func (l *Library) LoadDir(dir string) error {
+	return l.load(os.DirFS(dir), ".", func(p string) string {
+		return filepath.Join(dir, filepath.FromSlash(p))
+	})
+}
+
+// LoadFS loads the .kql files in the directory of the file system and its subdirectories.
+func (l *Library) LoadFS(fsys fs.FS, dir string) error {
+	return l.load(fsys, dir, func(p string) string {
+		return p
+	})
+}
+
+func (l *Library) load(fsys fs.FS, dir string, filePath func(p string) string) error {
+	return fs.WalkDir(fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.EqualFold(path.Ext(p), Extension) {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		q, err := Parse(filePath(p), data)
+		if err != nil {
+			return err
+		}
+
+		return l.Add(q)
+	})
+}
+
+// Get returns the query by name.
+func (l *Library) Get(name string) (*Query, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	q, ok := l.queries[name]
+	return q, ok
+}
+
+// Names returns the sorted names of the queries.
+func (l *Library) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]string, 0, len(l.queries))
+	for name := range l.queries {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// Queries returns the queries sorted by name.
+func (l *Library) Queries() []*Query {
+	names := l.Names()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := make([]*Query, 0, len(names))
+	for _, name := range names {
+		result = append(result, l.queries[name])
+	}
+
+	return result
+}
+
+// Bind returns the text of the named query with the parameters bound, and the options to run it
+// with, see [Query.Bind] and [Query.ExecOptions].
+func (l *Library) Bind(name string, params Params, options *rg.ExecOptions) (string, *rg.ExecOptions, error) {
+	q, ok := l.Get(name)
+	if !ok {
+		return "", nil, fmt.Errorf("query '%s' not found", name)
+	}
+
+	text, err := q.Bind(params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return text, q.ExecOptions(options), nil
+}
+
+// ExecRows executes the named query and returns its rows with the columns in the projected order.
+func (l *Library) ExecRows(ctx context.Context, name string, params Params, options *rg.ExecOptions) ([]rg.Row, error) {
+	text, execOptions, err := l.Bind(name, params, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return rg.ExecRows(ctx, text, execOptions)
+}
+
+// LoadDir loads the .kql files in the directory into the default library.
+func LoadDir(dir string) error {
+	return Default.LoadDir(dir)
+}
+
+// LoadFS loads the .kql files in the directory of the file system into the default library.
+func LoadFS(fsys fs.FS, dir string) error {
+	return Default.LoadFS(fsys, dir)
+}
+
+// RegisterFS loads the .kql files in the directory of the file system into the default library,
+// and panics on errors. It is meant for the query files embedded with go:embed, which are
+// registered when the program starts and checked by [Validate] in the tests of the program:
+//
+//	//go:embed queries
+//	var queries embed.FS
+//
+//	func init() {
+//		lib.RegisterFS(queries, "queries")
+//	}
+func RegisterFS(fsys fs.FS, dir string) {
+	if err := Default.LoadFS(fsys, dir); err != nil {
+		panic(fmt.Sprintf("lib: registering queries: %s", err))
+	}
+}
+
+// Get returns the query from the default library by name.
+func Get(name string) (*Query, bool) {
+	return Default.Get(name)
+}
+
+// Exec executes the named query from the default library and returns the rows unmarshalled into T.
+func Exec[T any](ctx context.Context, name string, params Params, options *rg.ExecOptions) ([]T, error) {
+	return ExecWith[T](ctx, Default, name, params, options)
+}
+
+// ExecWith executes the named query from the library and returns the rows unmarshalled into T.
+func ExecWith[T any](ctx context.Context, l *Library, name string, params Params, options *rg.ExecOptions) ([]T, error) {
+	text, execOptions, err := l.Bind(name, params, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return rg.Exec[T](ctx, text, execOptions)
+}
+
+// Stream executes the named query from the default library and calls fn for each row unmarshalled into T.
+func Stream[T any](ctx context.Context, name string, params Params, options *rg.ExecOptions, fn func(row T) error) error {
+	text, execOptions, err := Default.Bind(name, params, options)
+	if err != nil {
+		return err
+	}
+
+	return rg.Stream[T](ctx, text, execOptions, fn)
+}
+
+// ExecRows executes the named query from the default library and returns its rows.
+func ExecRows(ctx context.Context, name string, params Params, options *rg.ExecOptions) ([]rg.Row, error) {
+	return Default.ExecRows(ctx, name, params, options)
+}
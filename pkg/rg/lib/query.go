@@ -0,0 +1,279 @@
+//go:build go1.18
+// +build go1.18
+
+package lib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/kql"
+	"gopkg.in/yaml.v3"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Query is a saved query with its metadata.
+type Query struct {
+	// Name is the query name, the default is the file name without the .kql extension.
+	Name string `yaml:"name"`
+
+	// Description is what the query returns.
+	Description string `yaml:"description"`
+
+	// Version is the version of the query, to tell the consumers when the results change.
+	Version string `yaml:"version"`
+
+	// Parameters are the query parameters, which the query refers to by name.
+	Parameters []Parameter `yaml:"parameters"`
+
+	// Subscriptions and ManagementGroups are the default query scope, used when the options
+	// of the execution don't have one.
+	Subscriptions    []string `yaml:"subscriptions"`
+	ManagementGroups []string `yaml:"managementGroups"`
+
+	// Result is the name of the Go type the rows are expected to unmarshal into, for documentation and checks.
+	Result string `yaml:"result"`
+
+	// Query is the KQL query text.
+	Query string `yaml:"query"`
+
+	// Path is the path of the file the query is loaded from, empty for queries which are not from files.
+	Path string `yaml:"-"`
+
+	// line is the number of the file lines before the query text, to report the positions in the file.
+	line int
+}
+
+// Parameter is a query parameter.
+type Parameter struct {
+	// Name is the parameter name, which must be a KQL identifier and should differ from the
+	// column names the query uses.
+	Name string `yaml:"name"`
+
+	// Type is the KQL type of the parameter, the default is string.
+	Type kql.Type `yaml:"type"`
+
+	// Description is what the parameter means.
+	Description string `yaml:"description"`
+
+	// Required makes the parameter mandatory.
+	Required bool `yaml:"required"`
+
+	// Default is the value text used when the parameter is not given. Without it, such a parameter
+	// is null of its type, e.g. long(null), which the query can check with isnull or isempty.
+	Default string `yaml:"default"`
+}
+
+// Params are the values of the query parameters by name. String values are parsed according
+// to the parameter type, e.g. from URL queries or command line flags, see [kql.Type.Parse].
+// Other values are used as they are, see [kql.Literal].
+type Params map[string]any
+
+// nameRegex matches valid query names.
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Validate checks the query and fills in the defaults.
+func (q *Query) Validate() error {
+	if !nameRegex.MatchString(q.Name) {
+		return fmt.Errorf("invalid query name '%s'", q.Name)
+	}
+
+	if strings.TrimSpace(q.Query) == "" {
+		return fmt.Errorf("query '%s': the query is empty", q.Name)
+	}
+
+	names := map[string]bool{}
+	for i := range q.Parameters {
+		p := &q.Parameters[i]
+		if !kql.IsIdentifier(p.Name) {
+			return fmt.Errorf("query '%s': invalid parameter name '%s'", q.Name, p.Name)
+		}
+
+		if names[p.Name] {
+			return fmt.Errorf("query '%s': duplicate parameter '%s'", q.Name, p.Name)
+		}
+		names[p.Name] = true
+
+		if p.Type == "" {
+			p.Type = kql.TypeString
+		}
+
+		t, err := kql.ParseType(string(p.Type))
+		if err != nil {
+			return fmt.Errorf("query '%s', parameter '%s': %w", q.Name, p.Name, err)
+		}
+		p.Type = t
+
+		if p.Default != "" {
+			if _, err := p.Type.Parse(p.Default); err != nil {
+				return fmt.Errorf("query '%s', parameter '%s': invalid default: %w", q.Name, p.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Bind returns the query text with the parameters bound as let statements, e.g. "let region = 'westeurope';".
+// Missing parameters take their defaults, or are null of their types without them, see [kql.Type.Null].
+// It is an error when a required parameter is missing or there are parameters the query doesn't have.
+func (q *Query) Bind(params Params) (string, error) {
+	for name := range params {
+		if q.parameter(name) == nil {
+			return "", fmt.Errorf("query '%s' has no parameter '%s'", q.Name, name)
+		}
+	}
+
+	var sb strings.Builder
+	for _, p := range q.Parameters {
+		value, ok := params[p.Name]
+		switch {
+		case !ok && p.Required:
+			return "", fmt.Errorf("query '%s': parameter '%s' is required", q.Name, p.Name)
+		case !ok && p.Default == "", ok && value == nil:
+			sb.WriteString("let " + p.Name + " = " + p.Type.Null() + ";\n")
+			continue
+		case !ok:
+			value = p.Default
+		}
+
+		if text, ok := value.(string); ok {
+			v, err := p.Type.Parse(text)
+			if err != nil {
+				return "", fmt.Errorf("query '%s': parameter '%s' must be %s: %w", q.Name, p.Name, p.Type, err)
+			}
+			value = v
+		}
+
+		let, err := kql.Let(p.Name, value)
+		if err != nil {
+			return "", fmt.Errorf("query '%s': %w", q.Name, err)
+		}
+
+		sb.WriteString(let)
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(q.Query)
+	return sb.String(), nil
+}
+
+// ExecOptions returns the options to run the query with: a copy of options with the default
+// scope of the query when options don't have one.
+func (q *Query) ExecOptions(options *rg.ExecOptions) *rg.ExecOptions {
+	var result rg.ExecOptions
+	if options != nil {
+		result = *options
+	}
+
+	if len(result.Subscriptions) == 0 && len(result.ManagementGroups) == 0 {
+		result.Subscriptions = q.Subscriptions
+		result.ManagementGroups = q.ManagementGroups
+	}
+
+	return &result
+}
+
+func (q *Query) parameter(name string) *Parameter {
+	for i := range q.Parameters {
+		if q.Parameters[i].Name == name {
+			return &q.Parameters[i]
+		}
+	}
+
+	return nil
+}
+
+// frontMatterDelimiter is the line which starts and ends the front-matter.
+const frontMatterDelimiter = "---"
+
+// Parse parses the query file. The metadata is in YAML front-matter in "//" comments at the
+// start of the file, so that the file is still valid KQL:
+//
+//	// ---
+//	// name: public-ips
+//	// description: Public IP addresses in a region.
+//	// version: 2
+//	// parameters:
+//	//   - name: region
+//	//     required: true
+//	// ---
+//	resources
+//	| where type =~ 'microsoft.network/publicipaddresses' and location =~ region
+//
+// The front-matter is optional, and the default name is the file name without the .kql extension.
+func Parse(filePath string, data []byte) (*Query, error) {
+	q := &Query{Path: filePath}
+
+	text := string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+
+	if start < len(lines) && commentText(lines[start]) == frontMatterDelimiter {
+		end := start + 1
+		var yamlLines []string
+		for ; end < len(lines); end++ {
+			line := strings.TrimSpace(lines[end])
+			if !strings.HasPrefix(line, "//") {
+				return nil, fmt.Errorf("query file '%s': line %d: the front-matter must be in // comments", filePath, end+1)
+			}
+
+			if commentText(line) == frontMatterDelimiter {
+				break
+			}
+
+			yamlLines = append(yamlLines, strings.TrimPrefix(strings.TrimPrefix(line, "//"), " "))
+		}
+
+		if end == len(lines) {
+			return nil, fmt.Errorf("query file '%s': the front-matter has no closing '// %s'", filePath, frontMatterDelimiter)
+		}
+
+		dec := yaml.NewDecoder(strings.NewReader(strings.Join(yamlLines, "\n")))
+		dec.KnownFields(true)
+		if err := dec.Decode(q); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("query file '%s': front-matter: %w", filePath, err)
+		}
+
+		start = end + 1
+	}
+
+	if strings.TrimSpace(q.Query) != "" {
+		return nil, fmt.Errorf("query file '%s': the query must follow the front-matter, not be in it", filePath)
+	}
+
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+
+	q.line = start
+	q.Query = strings.TrimSpace(strings.Join(lines[start:], "\n"))
+	q.Path = filePath
+	if q.Name == "" {
+		q.Name = strings.TrimSuffix(path.Base(strings.ReplaceAll(filePath, "\\", "/")), path.Ext(filePath))
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("query file '%s': %w", filePath, err)
+	}
+
+	return q, nil
+}
+
+// commentText returns the text of the "//" comment line, or the line itself when it is not a comment.
+func commentText(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "//") {
+		return strings.TrimSpace(line[2:])
+	}
+
+	return line
+}
@@ -0,0 +1,110 @@
+//go:build go1.18
+// +build go1.18
+
+package lib_test
+
+import (
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/lib"
+	"strings"
+	"testing"
+)
+
+func TestBind(t *testing.T) {
+	q := &lib.Query{
+		Name:  "resources",
+		Query: "resources",
+		Parameters: []lib.Parameter{
+			{Name: "region", Required: true},
+			{Name: "top", Type: "long", Default: "100"},
+			{Name: "minSize", Type: "long"},
+			{Name: "since", Type: "datetime"},
+			{Name: "tag", Type: "string"},
+			{Name: "names", Type: "dynamic"},
+		},
+	}
+	if err := q.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		params lib.Params
+		want   []string
+	}{
+		{
+			lib.Params{"region": "westeurope"},
+			[]string{"let region = 'westeurope';", "let top = long(100);", "let minSize = long(null);", "let since = datetime(null);", "let tag = '';", "let names = dynamic(null);"},
+		},
+		{
+			lib.Params{"region": "westeurope", "top": "5", "minSize": 10, "since": "2024-01-02", "tag": "prod", "names": `["a"]`},
+			[]string{"let region = 'westeurope';", "let top = long(5);", "let minSize = long(10);", "let since = datetime(2024-01-02T00:00:00Z);", "let tag = 'prod';", `let names = dynamic(["a"]);`},
+		},
+		{
+			lib.Params{"region": "westeurope", "top": nil},
+			[]string{"let region = 'westeurope';", "let top = long(null);", "let minSize = long(null);", "let since = datetime(null);", "let tag = '';", "let names = dynamic(null);"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := q.Bind(tt.params)
+		if err != nil {
+			t.Errorf("Bind(%v) failed: %v", tt.params, err)
+			continue
+		}
+
+		if want := strings.Join(append(tt.want, "resources"), "\n"); got != want {
+			t.Errorf("Bind(%v) = %q, want %q", tt.params, got, want)
+		}
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	q := &lib.Query{
+		Name:  "resources",
+		Query: "resources",
+		Parameters: []lib.Parameter{
+			{Name: "region", Required: true},
+			{Name: "top", Type: "long"},
+		},
+	}
+	if err := q.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, params := range []lib.Params{
+		{},
+		{"region": "westeurope", "top": "many"},
+		{"region": "westeurope", "other": "x"},
+	} {
+		if _, err := q.Bind(params); err == nil {
+			t.Errorf("Bind(%v) succeeded, want an error", params)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	text := "\n// ---\n// description: Public IP addresses.\n// parameters:\n//   - name: region\n//     required: true\n// ---\n\nresources\n| where location =~ region\n"
+
+	q, err := lib.Parse("queries/public-ips.kql", []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Name != "public-ips" || q.Description != "Public IP addresses." || len(q.Parameters) != 1 || q.Parameters[0].Type != "string" {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	if q.Query != "resources\n| where location =~ region" {
+		t.Errorf("got query text %q", q.Query)
+	}
+
+	for _, text := range []string{
+		"// ---\n// name: x\nresources",
+		"// ---\nname: x\n// ---\nresources",
+		"// ---\n// unknown: x\n// ---\nresources",
+		"// ---\n// parameters:\n//   - name: top\n//     type: long\n//     default: many\n// ---\nresources",
+	} {
+		if _, err := lib.Parse("q.kql", []byte(text)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", text)
+		}
+	}
+}
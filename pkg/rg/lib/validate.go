@@ -0,0 +1,173 @@
+//go:build go1.18
+// +build go1.18
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/kql"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Types are the Go types of the query results by the names in the result field of the front-matter,
+// given as values of the types, e.g. lib.Types{"PublicIP": PublicIP{}}.
+type Types map[string]any
+
+// ValidationError lists the problems of the queries found by [Validate].
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid queries:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate checks the queries of the library, or of the default library when nil, without running them:
+// the syntax of each query with [kql.Check], and that the projected columns of each query with a result
+// type match the fields of the Go type. It is meant to be called from the tests of the program, so that
+// a broken query or a schema mismatch fails the build:
+//
+//	func TestQueries(t *testing.T) {
+//		if err := lib.Validate(nil, lib.Types{"PublicIP": PublicIP{}}); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+//
+// The error is a [*ValidationError] with all problems found.
+func Validate(l *Library, types Types) error {
+	if l == nil {
+		l = Default
+	}
+
+	var problems []string
+	for _, q := range l.Queries() {
+		where := "query '" + q.Name + "'"
+		if q.Path != "" {
+			where += " (" + q.Path + ")"
+		}
+
+		if err := kql.Check(q.Query); err != nil {
+			var se *kql.SyntaxError
+			if errors.As(err, &se) {
+				se.Pos.Line += q.line
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s", where, err))
+			continue
+		}
+
+		if q.Result == "" {
+			continue
+		}
+
+		v, ok := types[q.Result]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: the result type '%s' is not in the types", where, q.Result))
+			continue
+		}
+
+		if err := CheckResult(q, v); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", where, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// CheckResult checks that the projected columns of the query match the fields of the type of v: each
+// column has a field and each field has a column, unless the field is omitempty. The columns and the
+// fields are matched by JSON names case-insensitively, like they are when the rows are unmarshalled.
+// The fields with the "rg" tag are not checked, see [rg.ExecOptions.Enrich].
+//
+// The check is skipped when v is not a struct, or when the columns can't be told from the query text,
+// see [kql.ProjectedColumns].
+func CheckResult(q *Query, v any) error {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	columns, ok := kql.ProjectedColumns(q.Query)
+	if !ok {
+		return nil
+	}
+
+	fields := map[string]jsonField{}
+	collectFields(t, fields)
+
+	var problems []string
+	seen := map[string]bool{}
+	for _, c := range columns {
+		key := strings.ToLower(c)
+		seen[key] = true
+		if _, ok := fields[key]; !ok {
+			problems = append(problems, fmt.Sprintf("column '%s' has no field in %s", c, t))
+		}
+	}
+
+	for key, f := range fields {
+		if !seen[key] && !f.omitEmpty {
+			problems = append(problems, fmt.Sprintf("field '%s' of %s has no column", f.name, t))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// jsonField is a struct field by its JSON name.
+type jsonField struct {
+	name      string
+	omitEmpty bool
+}
+
+// collectFields adds the exported fields of the struct type by their lower case JSON names,
+// including the fields of the embedded structs. The fields with the "rg" tag are left out, as
+// [rg.ExecOptions.Enrich] fills them instead of the query.
+func collectFields(t reflect.Type, fields map[string]jsonField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if _, ok := f.Tag.Lookup("rg"); ok || tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			collectFields(ft, fields)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields[strings.ToLower(name)] = jsonField{
+			name:      f.Name,
+			omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+		}
+	}
+}
@@ -0,0 +1,103 @@
+//go:build go1.18
+// +build go1.18
+
+package lib_test
+
+import (
+	"errors"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/lib"
+	"strings"
+	"testing"
+)
+
+type publicIP struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Location string `json:"location,omitempty"`
+	Ignored  string `json:"-"`
+
+	// The fields filled by rg.ExecOptions.Enrich have no columns.
+	SubscriptionName string            `rg:"subscriptionName"`
+	GroupTags        map[string]string `json:"groupTags" rg:"resourceGroupTags"`
+}
+
+func TestCheckResult(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"resources | project id, name, ip = tostring(properties.ipAddress)", ""},
+		{"resources | project id, name, ip = tostring(properties.ipAddress), location", ""},
+		{"resources | project id, Name, IP = tostring(properties.ipAddress)", ""},
+		{"resources | where type =~ 'microsoft.network/publicipaddresses'", ""},
+		{"resources | project id, name", "field 'IP' of lib_test.publicIP has no column"},
+		{"resources | project id, name, ip = tostring(properties.ipAddress), sku", "column 'sku' has no field in lib_test.publicIP"},
+		{"resources | project id, name, ip = tostring(properties.ipAddress), subscriptionName", "column 'subscriptionName' has no field in lib_test.publicIP"},
+	}
+
+	for _, tt := range tests {
+		err := lib.CheckResult(&lib.Query{Name: "ips", Query: tt.query}, publicIP{})
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("CheckResult(%q) failed: %v", tt.query, err)
+		case tt.want != "" && (err == nil || err.Error() != tt.want):
+			t.Errorf("CheckResult(%q) error %v, want %q", tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestCheckResultEmbedded(t *testing.T) {
+	type base struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	type record struct {
+		base
+		Type string `json:"type"`
+	}
+
+	if err := lib.CheckResult(&lib.Query{Query: "resources | project id, name, type"}, &record{}); err != nil {
+		t.Error(err)
+	}
+
+	if err := lib.CheckResult(&lib.Query{Query: "resources | project id, type"}, record{}); err == nil {
+		t.Error("CheckResult succeeded, want an error for the name field")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	l := lib.New()
+	for _, text := range []string{
+		"// ---\n// name: ips\n// result: PublicIP\n// ---\nresources | project id, name, ip = tostring(properties.ipAddress)",
+		"// ---\n// name: broken\n// ---\nresources | where (",
+		"// ---\n// name: mismatch\n// result: PublicIP\n// ---\nresources | project id",
+		"// ---\n// name: unknown\n// result: Other\n// ---\nresources",
+	} {
+		q, err := lib.Parse("", []byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Add(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := lib.Validate(l, lib.Types{"PublicIP": publicIP{}})
+
+	var validationError *lib.ValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("got error %v, want *lib.ValidationError", err)
+	}
+
+	if len(validationError.Problems) != 3 {
+		t.Fatalf("got problems %q, want 3", validationError.Problems)
+	}
+
+	for i, want := range []string{"query 'broken': ", "query 'mismatch': field ", "query 'unknown': the result type 'Other'"} {
+		if !strings.HasPrefix(validationError.Problems[i], want) {
+			t.Errorf("got problem %q, want %q", validationError.Problems[i], want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MultiTenantClient holds a [Client] per Entra tenant to run the same query against all tenants,
+// see [ExecTenants].
+//
+// Example:
+//
+//	contoso, err := azidentity.NewClientSecretCredential(contosoTenantID, clientID, contosoSecret, nil)
+//	...
+//	fabrikam, err := azidentity.NewClientSecretCredential(fabrikamTenantID, clientID, fabrikamSecret, nil)
+//	...
+//
+//	client, err := rg.NewMultiTenantClient(map[string]azcore.TokenCredential{
+//		contosoTenantID:  contoso,
+//		fabrikamTenantID: fabrikam,
+//	}, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	rows, err := rg.ExecTenants[record](ctx, client, "resources | project id, name | order by id asc", nil)
+//	if err != nil {
+//		// The tenants which failed, the rows of the others are still returned.
+//		log.Print(err)
+//	}
+//
+//	for _, row := range rows {
+//		fmt.Printf("%s %s\n", row.TenantID, row.Row.Name)
+//	}
+type MultiTenantClient struct {
+	// tenants are the tenant IDs in order.
+	tenants []string
+	clients map[string]*Client
+}
+
+// NewMultiTenantClient creates the clients for the tenants with their credentials by tenant ID. The options
+// are the same for all clients and can be nil, see [NewClient].
+func NewMultiTenantClient(creds map[string]azcore.TokenCredential, options *ClientOptions) (*MultiTenantClient, error) {
+	if len(creds) == 0 {
+		return nil, errors.New("no tenants given")
+	}
+
+	result := &MultiTenantClient{clients: make(map[string]*Client, len(creds))}
+	for tenantID, cred := range creds {
+		if cred == nil {
+			return nil, fmt.Errorf("the credential of tenant '%s' is nil", tenantID)
+		}
+
+		client, err := NewClient(cred, options)
+		if err != nil {
+			return nil, fmt.Errorf("creating the client of tenant '%s': %w", tenantID, err)
+		}
+
+		result.tenants = append(result.tenants, tenantID)
+		result.clients[tenantID] = client
+	}
+
+	sort.Strings(result.tenants)
+	return result, nil
+}
+
+// Tenants returns the tenant IDs in order.
+func (c *MultiTenantClient) Tenants() []string {
+	return append([]string(nil), c.tenants...)
+}
+
+// Client returns the client of the tenant, or nil when there is no such tenant.
+func (c *MultiTenantClient) Client(tenantID string) *Client {
+	return c.clients[tenantID]
+}
+
+// TenantRow is a row of the results of [ExecTenants] with the tenant it comes from.
+type TenantRow[T any] struct {
+	TenantID string
+	Row      T
+}
+
+// TenantError is the error running the query against a tenant.
+type TenantError struct {
+	TenantID string
+	Err      error
+}
+
+func (e TenantError) Error() string {
+	return fmt.Sprintf("tenant '%s': %s", e.TenantID, e.Err)
+}
+
+func (e TenantError) Unwrap() error {
+	return e.Err
+}
+
+// MultiTenantError is the error of [ExecTenants] when the query fails for some tenants.
+type MultiTenantError struct {
+	// Errors are the errors of the tenants which failed, in the order of the tenants.
+	Errors []TenantError
+}
+
+func (e *MultiTenantError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("the query failed for %d tenant(s): %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// ExecTenants executes the query against all tenants of the client concurrently, and returns the rows
+// unmarshalled as T with their tenants, ordered by tenant and then as each tenant returned them.
+// The options apply to each tenant, except for [ExecOptions.Client], and can be nil. Note that
+// the subscriptions and management groups of the options must then exist in every tenant.
+//
+// When the query fails for some tenants the error is a [*MultiTenantError], and the rows of the
+// other tenants are still returned.
+func ExecTenants[T any](ctx context.Context, client *MultiTenantClient, query string, options *ExecOptions) ([]TenantRow[T], error) {
+	results := make([][]T, len(client.tenants))
+	errs := make([]error, len(client.tenants))
+
+	var wg sync.WaitGroup
+	for i, tenantID := range client.tenants {
+		var tenantOptions ExecOptions
+		if options != nil {
+			tenantOptions = *options
+		}
+		tenantOptions.Client = client.clients[tenantID]
+
+		wg.Add(1)
+		go func(i int, tenantOptions *ExecOptions) {
+			defer wg.Done()
+			results[i], errs[i] = Exec[T](ctx, query, tenantOptions)
+		}(i, &tenantOptions)
+	}
+	wg.Wait()
+
+	var rows []TenantRow[T]
+	var failed []TenantError
+	for i, tenantID := range client.tenants {
+		if errs[i] != nil {
+			failed = append(failed, TenantError{TenantID: tenantID, Err: errs[i]})
+			continue
+		}
+
+		for _, row := range results[i] {
+			rows = append(rows, TenantRow[T]{TenantID: tenantID, Row: row})
+		}
+	}
+
+	if len(failed) > 0 {
+		return rows, &MultiTenantError{Errors: failed}
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,122 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tenantRouter sends the requests to the server of the tenant, which is the token of its credential.
+type tenantRouter map[string]*rgtest.Server
+
+func (r tenantRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	server, ok := r[strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")]
+	if !ok {
+		http.Error(w, "unknown tenant", http.StatusUnauthorized)
+		return
+	}
+
+	server.ServeHTTP(w, req)
+}
+
+func newMultiTenantClient(t *testing.T, servers tenantRouter) *rg.MultiTenantClient {
+	t.Helper()
+
+	creds := map[string]azcore.TokenCredential{}
+	for tenantID := range servers {
+		creds[tenantID] = rgtest.Credential{Token: tenantID}
+	}
+
+	client, err := rg.NewMultiTenantClient(creds, rgtest.ClientOptions(t, servers, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func TestExecTenants(t *testing.T) {
+	servers := tenantRouter{
+		"tenant-b": {Pages: []string{`[{"name": "b1"}]`, `[{"name": "b2"}]`}},
+		"tenant-a": {Pages: []string{`[{"name": "a1"}, {"name": "a2"}]`}},
+	}
+	client := newMultiTenantClient(t, servers)
+
+	if !reflect.DeepEqual(client.Tenants(), []string{"tenant-a", "tenant-b"}) || client.Client("tenant-a") == nil || client.Client("tenant-c") != nil {
+		t.Errorf("unexpected tenants %v", client.Tenants())
+	}
+
+	rows, err := rg.ExecTenants[record](context.Background(), client, "resources | project name", &rg.ExecOptions{Subscriptions: []string{"sub1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows are ordered by tenant.
+	want := []rg.TenantRow[record]{
+		{TenantID: "tenant-a", Row: record{Name: "a1"}},
+		{TenantID: "tenant-a", Row: record{Name: "a2"}},
+		{TenantID: "tenant-b", Row: record{Name: "b1"}},
+		{TenantID: "tenant-b", Row: record{Name: "b2"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+
+	// The options apply to each tenant.
+	for tenantID, server := range servers {
+		if request := server.Requests()[0]; !reflect.DeepEqual(request.Subscriptions, []string{"sub1"}) {
+			t.Errorf("tenant %s got the request %+v", tenantID, request)
+		}
+	}
+}
+
+func TestExecTenantsPartialFailure(t *testing.T) {
+	servers := tenantRouter{
+		"tenant-a": {Pages: []string{`[{"name": "a1"}]`}},
+		"tenant-b": {Status: http.StatusForbidden, Body: `{"error": {"code": "AuthorizationFailed", "message": "denied"}}`},
+		"tenant-c": {Pages: []string{`[{"name": "c1"}]`}},
+	}
+	client := newMultiTenantClient(t, servers)
+
+	rows, err := rg.ExecTenants[record](context.Background(), client, "resources | project name", nil)
+
+	var multiTenantError *rg.MultiTenantError
+	if !errors.As(err, &multiTenantError) {
+		t.Fatalf("got error %v, want *rg.MultiTenantError", err)
+	}
+
+	if len(multiTenantError.Errors) != 1 || multiTenantError.Errors[0].TenantID != "tenant-b" || !strings.HasPrefix(err.Error(), "the query failed for 1 tenant(s): tenant 'tenant-b': ") {
+		t.Errorf("got error %v, want the error of tenant-b", err)
+	}
+
+	var responseError *azcore.ResponseError
+	if !errors.As(multiTenantError.Errors[0], &responseError) || responseError.StatusCode != http.StatusForbidden {
+		t.Errorf("got tenant error %v, want the response error", multiTenantError.Errors[0])
+	}
+
+	// The rows of the other tenants are still returned.
+	want := []rg.TenantRow[record]{{TenantID: "tenant-a", Row: record{Name: "a1"}}, {TenantID: "tenant-c", Row: record{Name: "c1"}}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+}
+
+func TestNewMultiTenantClientErrors(t *testing.T) {
+	if _, err := rg.NewMultiTenantClient(nil, nil); err == nil {
+		t.Error("NewMultiTenantClient succeeded without tenants, want an error")
+	}
+
+	_, err := rg.NewMultiTenantClient(map[string]azcore.TokenCredential{"tenant-a": nil}, nil)
+	if err == nil || err.Error() != "the credential of tenant 'tenant-a' is nil" {
+		t.Errorf("got error %v, want the nil credential error", err)
+	}
+}
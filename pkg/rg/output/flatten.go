@@ -0,0 +1,153 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"strconv"
+)
+
+// Field is a single column of a flattened row.
+type Field struct {
+	// Name is the dot-separated path of the value in the row, e.g. "properties.provisioningState".
+	Name string
+
+	// Value is one of: nil, bool, string, [json.Number], or [json.RawMessage] with compact JSON
+	// for arrays and empty objects.
+	Value any
+}
+
+// Flatten converts the row into a flat list of fields in the order the row marshals them.
+//
+// The row is marshalled to JSON first. Nested objects are flattened into fields named by
+// dot-separated paths, so {"sku": {"name": "S1"}} becomes the field "sku.name". Arrays and
+// empty objects are kept whole as compact JSON, so the set of columns does not depend on the
+// number of elements. A row which is not a JSON object becomes a single field named "value".
+func Flatten(row any) ([]Field, error) {
+	data, err := marshalRow(row)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []Field
+	if err := flatten(data, "", &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// FormatValue returns the text of a flattened value as it appears in tabular formats.
+// Null values are written as empty text.
+func FormatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case json.RawMessage:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// marshalRow returns the row as JSON.
+func marshalRow(row any) ([]byte, error) {
+	switch row := row.(type) {
+	case json.RawMessage:
+		return row, nil
+	case jsoniter.RawMessage:
+		return row, nil
+	default:
+		// Standard library compatible config sorts map keys, so the order of the fields is stable.
+		data, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(row)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling row of type %T: %w", row, err)
+		}
+		return data, nil
+	}
+}
+
+func flatten(data []byte, prefix string, fields *[]Field) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		if prefix == "" {
+			prefix = "value"
+		}
+
+		value, err := scalar(data)
+		if err != nil {
+			return err
+		}
+
+		*fields = append(*fields, Field{Name: prefix, Value: value})
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if !dec.More() && prefix != "" {
+		*fields = append(*fields, Field{Name: prefix, Value: json.RawMessage("{}")})
+		return nil
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		name := token.(string)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+
+		if err := flatten(value, name, fields); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scalar converts JSON which is not an object into a field value.
+func scalar(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	switch data[0] {
+	case 'n':
+		return nil, nil
+	case 't', 'f':
+		var b bool
+		err := json.Unmarshal(data, &b)
+		return b, err
+	case '"':
+		var s string
+		err := json.Unmarshal(data, &s)
+		return s, err
+	case '[':
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data); err != nil {
+			return nil, err
+		}
+		return json.RawMessage(buf.Bytes()), nil
+	default:
+		return json.Number(data), nil
+	}
+}
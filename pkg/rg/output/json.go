@@ -0,0 +1,85 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// jsonEncoder writes rows as a JSON array, one row per line.
+type jsonEncoder struct {
+	w     *bufio.Writer
+	buf   bytes.Buffer
+	count int
+}
+
+func newJSONEncoder(w io.Writer) *jsonEncoder {
+	return &jsonEncoder{w: bufio.NewWriter(w)}
+}
+
+func (e *jsonEncoder) Encode(row any) error {
+	if err := compactRow(&e.buf, row); err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if e.count == 0 {
+		sep = "[\n"
+	}
+	e.count++
+
+	if _, err := e.w.WriteString(sep); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	end := "\n]\n"
+	if e.count == 0 {
+		end = "[]\n"
+	}
+
+	if _, err := e.w.WriteString(end); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// jsonlEncoder writes rows as newline-delimited JSON.
+type jsonlEncoder struct {
+	w   *bufio.Writer
+	buf bytes.Buffer
+}
+
+func newJSONLEncoder(w io.Writer) *jsonlEncoder {
+	return &jsonlEncoder{w: bufio.NewWriter(w)}
+}
+
+func (e *jsonlEncoder) Encode(row any) error {
+	if err := compactRow(&e.buf, row); err != nil {
+		return err
+	}
+
+	if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *jsonlEncoder) Close() error {
+	return e.w.Flush()
+}
+
+// compactRow marshals the row into buf as compact JSON, so that it never spans lines.
+func compactRow(buf *bytes.Buffer, row any) error {
+	data, err := marshalRow(row)
+	if err != nil {
+		return err
+	}
+
+	buf.Reset()
+	return json.Compact(buf, data)
+}
@@ -0,0 +1,133 @@
+// Package output encodes streams of query result rows in various formats:
+// JSON array, newline-delimited JSON, CSV, TSV, GitHub Markdown tables and
+// terminal-aligned tables.
+//
+// Rows can be of any type which can be marshalled to a JSON object: structs,
+// maps like map[string]any, or raw JSON like [json.RawMessage] as returned by
+// rg.Exec[json.RawMessage].
+//
+// JSON formats keep rows as they are. Tabular formats flatten nested objects
+// into columns named by dot-separated paths, see [Flatten].
+//
+// Example:
+//
+//	enc, err := output.NewEncoder(os.Stdout, output.FormatCSV, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	err = rg.Stream(ctx, query, nil, func(row json.RawMessage) error {
+//		return enc.Encode(row)
+//	})
+//	if closeErr := enc.Close(); err == nil {
+//		err = closeErr
+//	}
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the name of the output format.
+type Format string
+
+const (
+	// FormatJSON writes rows as a JSON array, one row per line.
+	FormatJSON Format = "json"
+
+	// FormatJSONL writes rows as newline-delimited JSON, one row per line.
+	FormatJSONL Format = "jsonl"
+
+	// FormatCSV writes rows as comma-separated values with a header.
+	FormatCSV Format = "csv"
+
+	// FormatTSV writes rows as tab-separated values with a header.
+	FormatTSV Format = "tsv"
+
+	// FormatMarkdown writes rows as a GitHub Markdown table.
+	FormatMarkdown Format = "markdown"
+
+	// FormatTable writes rows as a table aligned with spaces for terminals.
+	FormatTable Format = "table"
+)
+
+// PossibleFormatValues returns all supported formats.
+func PossibleFormatValues() []Format {
+	return []Format{
+		FormatJSON,
+		FormatJSONL,
+		FormatCSV,
+		FormatTSV,
+		FormatMarkdown,
+		FormatTable,
+	}
+}
+
+// ParseFormat returns the format with the given name. The name is case-insensitive,
+// and "ndjson" and "md" are accepted as aliases for [FormatJSONL] and [FormatMarkdown].
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case "ndjson":
+		return FormatJSONL, nil
+	case "md":
+		return FormatMarkdown, nil
+	}
+
+	for _, f := range PossibleFormatValues() {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown output format %q", name)
+}
+
+// Options are the optional parameters for [NewEncoder].
+type Options struct {
+	// Columns are the columns of tabular formats in the order they are written.
+	// When empty, CSV and TSV take the columns from the first row, and Markdown and
+	// table formats use all columns from all rows in the order they first appear.
+	// Ignored by JSON formats.
+	Columns []string
+
+	// NoHeader omits the header line of CSV, TSV and table formats.
+	NoHeader bool
+}
+
+// Encoder writes rows to the output.
+type Encoder interface {
+	// Encode writes a single row.
+	Encode(row any) error
+
+	// Close writes anything buffered and completes the output. It does not close the
+	// underlying writer.
+	Close() error
+}
+
+// NewEncoder returns an [Encoder] which writes rows in the given format to w.
+// The options can be nil.
+func NewEncoder(w io.Writer, format Format, options *Options) (Encoder, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	switch format {
+	case FormatJSON:
+		return newJSONEncoder(w), nil
+	case FormatJSONL:
+		return newJSONLEncoder(w), nil
+	case FormatCSV:
+		return newCSVEncoder(w, ',', options), nil
+	case FormatTSV:
+		return newCSVEncoder(w, '\t', options), nil
+	case FormatMarkdown:
+		return newMarkdownEncoder(w, options), nil
+	case FormatTable:
+		return newTableEncoder(w, options), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
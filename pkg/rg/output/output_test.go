@@ -0,0 +1,184 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/output"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encode writes the rows in the format and returns the output.
+func encode(t *testing.T, format output.Format, options *output.Options, rows ...any) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc, err := output.NewEncoder(&buf, format, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+var rows = []any{
+	json.RawMessage(`{"name": "a", "sku": {"name": "S1"}}`),
+	json.RawMessage(`{"name": "b", "zones": ["1", "2"], "sku": {"name": "S2", "tier": "Basic"}}`),
+}
+
+func TestCSV(t *testing.T) {
+	// The columns are those of the first row.
+	got := encode(t, output.FormatCSV, nil, rows...)
+	want := "name,sku.name\na,S1\nb,S2\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCSVStream(t *testing.T) {
+	var buf bytes.Buffer
+	enc, err := output.NewEncoder(&buf, output.FormatCSV, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows are written as they come, not once all are known.
+	row := map[string]any{"name": strings.Repeat("a", 1000)}
+	for i := 0; i < 10; i++ {
+		if err := enc.Encode(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if buf.Len() == 0 {
+		t.Error("got no output before Close, want the rows written")
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 11 {
+		t.Errorf("got %d lines, want the header and 10 rows", lines)
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	got := encode(t, output.FormatTSV, &output.Options{Columns: []string{"sku.tier", "name"}}, rows...)
+	want := "sku.tier\tname\n\ta\nBasic\tb\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got = encode(t, output.FormatCSV, &output.Options{Columns: []string{"name"}, NoHeader: true}, rows...)
+	if want := "a\nb\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The header is written even without rows.
+	got = encode(t, output.FormatCSV, &output.Options{Columns: []string{"name", "type"}})
+	if want := "name,type\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := encode(t, output.FormatCSV, nil); got != "" {
+		t.Errorf("got %q, want no output without rows and columns", got)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	got := encode(t, output.FormatMarkdown, nil, map[string]any{"name": "a|b", "note": "line 1\nline 2"})
+	want := "| name | note |\n| --- | --- |\n| a\\|b | line 1<br>line 2 |\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A backslash before a pipe must not escape the backslash of the pipe escape.
+	got = encode(t, output.FormatMarkdown, nil, map[string]any{"path": `C:\dir\|x`})
+	want = "| path |\n| --- |\n| C:\\\\dir\\\\\\|x |\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTable(t *testing.T) {
+	got := encode(t, output.FormatTable, nil, rows...)
+	want := "" +
+		"name  sku.name  zones      sku.tier\n" +
+		"----  --------  -----      --------\n" +
+		"a     S1                   \n" +
+		"b     S2        [\"1\",\"2\"]  Basic\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type record struct {
+		Name string `json:"name"`
+	}
+
+	got := encode(t, output.FormatJSON, nil, record{"a"}, json.RawMessage(`{"name": "b"}`))
+	var decoded []record
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", got, err)
+	}
+	if want := []record{{"a"}, {"b"}}; !reflect.DeepEqual(decoded, want) {
+		t.Errorf("got %v, want %v", decoded, want)
+	}
+
+	if got := encode(t, output.FormatJSON, nil); got != "[]\n" {
+		t.Errorf("got %q, want an empty array", got)
+	}
+
+	got = encode(t, output.FormatJSONL, nil, record{"a"}, record{"b"})
+	if want := "{\"name\":\"a\"}\n{\"name\":\"b\"}\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	fields, err := output.Flatten(json.RawMessage(`{"a": {"b": 1, "c": {}}, "d": null, "e": true, "f": [1, 2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []output.Field{
+		{Name: "a.b", Value: json.Number("1")},
+		{Name: "a.c", Value: json.RawMessage("{}")},
+		{Name: "d", Value: nil},
+		{Name: "e", Value: true},
+		{Name: "f", Value: json.RawMessage("[1,2]")},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got %v, want %v", fields, want)
+	}
+
+	fields, err = output.Flatten(42)
+	if err != nil || len(fields) != 1 || fields[0].Name != "value" {
+		t.Errorf("got %v, %v, want a single value field", fields, err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]output.Format{"CSV": output.FormatCSV, "ndjson": output.FormatJSONL, "md": output.FormatMarkdown} {
+		if got, err := output.ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %s, %v, want %s", name, got, err, want)
+		}
+	}
+
+	if _, err := output.ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml) succeeded, want an error")
+	}
+}
@@ -0,0 +1,258 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// csvEncoder writes rows as CSV or TSV as they come. The columns are fixed by the options or else by the
+// first row, the fields of later rows which are not among the columns are not written.
+type csvEncoder struct {
+	w        *csv.Writer
+	columns  *columnSet
+	noHeader bool
+	started  bool
+}
+
+func newCSVEncoder(w io.Writer, comma rune, options *Options) *csvEncoder {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvEncoder{
+		w:        cw,
+		columns:  newColumnSet(options.Columns),
+		noHeader: options.NoHeader,
+	}
+}
+
+func (e *csvEncoder) Encode(row any) error {
+	fields, err := Flatten(row)
+	if err != nil {
+		return err
+	}
+
+	if !e.started && len(e.columns.names) == 0 {
+		for _, f := range fields {
+			e.columns.add(f.Name)
+		}
+	}
+
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	return e.w.Write(e.columns.record(fields))
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.started {
+		return nil
+	}
+
+	e.started = true
+	if e.noHeader || len(e.columns.names) == 0 {
+		return nil
+	}
+
+	return e.w.Write(e.columns.names)
+}
+
+func (e *csvEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// columnSet are the columns of a table in the order they are written, with the index of each name.
+type columnSet struct {
+	names []string
+	index map[string]int
+}
+
+func newColumnSet(names []string) *columnSet {
+	c := &columnSet{index: map[string]int{}}
+	for _, name := range names {
+		c.add(name)
+	}
+	return c
+}
+
+func (c *columnSet) add(name string) {
+	if _, ok := c.index[name]; !ok {
+		c.index[name] = len(c.names)
+		c.names = append(c.names, name)
+	}
+}
+
+// record returns the values of the fields as text in the order of the columns.
+func (c *columnSet) record(fields []Field) []string {
+	result := make([]string, len(c.names))
+	for _, f := range fields {
+		if i, ok := c.index[f.Name]; ok {
+			result[i] = FormatValue(f.Value)
+		}
+	}
+	return result
+}
+
+// bufferedTable accumulates the rows for formats which can only be written once all rows are known.
+// Without the columns of the options, the columns are those of all rows in the order they first appear.
+type bufferedTable struct {
+	columns *columnSet
+	fixed   bool
+	rows    [][]Field
+}
+
+func newBufferedTable(options *Options) *bufferedTable {
+	return &bufferedTable{
+		columns: newColumnSet(options.Columns),
+		fixed:   len(options.Columns) > 0,
+	}
+}
+
+func (t *bufferedTable) add(row any) error {
+	fields, err := Flatten(row)
+	if err != nil {
+		return err
+	}
+
+	if !t.fixed {
+		for _, f := range fields {
+			t.columns.add(f.Name)
+		}
+	}
+
+	t.rows = append(t.rows, fields)
+	return nil
+}
+
+// records returns the rows as text in the order of the columns.
+func (t *bufferedTable) records() [][]string {
+	result := make([][]string, 0, len(t.rows))
+	for _, fields := range t.rows {
+		result = append(result, t.columns.record(fields))
+	}
+	return result
+}
+
+// markdownEncoder writes rows as a GitHub Markdown table.
+type markdownEncoder struct {
+	w     io.Writer
+	table *bufferedTable
+}
+
+func newMarkdownEncoder(w io.Writer, options *Options) *markdownEncoder {
+	return &markdownEncoder{
+		w:     w,
+		table: newBufferedTable(options),
+	}
+}
+
+func (e *markdownEncoder) Encode(row any) error {
+	return e.table.add(row)
+}
+
+func (e *markdownEncoder) Close() error {
+	if len(e.table.columns.names) == 0 {
+		return nil
+	}
+
+	bw := bufio.NewWriter(e.w)
+	writeLine := func(cells []string) {
+		_, _ = bw.WriteString("|")
+		for _, c := range cells {
+			_, _ = bw.WriteString(" ")
+			_, _ = bw.WriteString(markdownEscaper.Replace(c))
+			_, _ = bw.WriteString(" |")
+		}
+		_, _ = bw.WriteString("\n")
+	}
+
+	separator := make([]string, len(e.table.columns.names))
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	writeLine(e.table.columns.names)
+	writeLine(separator)
+	for _, r := range e.table.records() {
+		writeLine(r)
+	}
+
+	return bw.Flush()
+}
+
+// markdownEscaper escapes the text which would break the table cells.
+// Backslashes are escaped too, otherwise a backslash before a pipe would escape the backslash of its escape.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+)
+
+// tableEncoder writes rows as a table aligned with spaces.
+type tableEncoder struct {
+	w        io.Writer
+	table    *bufferedTable
+	noHeader bool
+}
+
+func newTableEncoder(w io.Writer, options *Options) *tableEncoder {
+	return &tableEncoder{
+		w:        w,
+		table:    newBufferedTable(options),
+		noHeader: options.NoHeader,
+	}
+}
+
+func (e *tableEncoder) Encode(row any) error {
+	return e.table.add(row)
+}
+
+func (e *tableEncoder) Close() error {
+	if len(e.table.columns.names) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(e.w, 0, 0, 2, ' ', 0)
+	writeLine := func(cells []string) {
+		for i, c := range cells {
+			if i > 0 {
+				_, _ = io.WriteString(tw, "\t")
+			}
+			_, _ = io.WriteString(tw, tableEscaper.Replace(c))
+		}
+		_, _ = io.WriteString(tw, "\n")
+	}
+
+	if !e.noHeader {
+		underline := make([]string, len(e.table.columns.names))
+		for i, c := range e.table.columns.names {
+			underline[i] = strings.Repeat("-", len([]rune(c)))
+		}
+
+		writeLine(e.table.columns.names)
+		writeLine(underline)
+	}
+
+	for _, r := range e.table.records() {
+		writeLine(r)
+	}
+
+	return tw.Flush()
+}
+
+// tableEscaper replaces the characters which would break the alignment.
+var tableEscaper = strings.NewReplacer(
+	"\t", " ",
+	"\r\n", " ",
+	"\n", " ",
+	"\r", " ",
+)
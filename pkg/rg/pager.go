@@ -0,0 +1,391 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/armresourcegraph2"
+	"log"
+	"reflect"
+	"time"
+)
+
+// Page is a single page of query results as returned by Azure Resource Graph.
+type Page struct {
+	// Index is the zero-based index of the page.
+	Index int
+
+	// Data is the raw JSON of the rows: an array of objects, or an object with columns and rows
+	// for table-format results.
+	Data json.RawMessage
+
+	// Count is the number of rows in the page.
+	Count int64
+
+	// TotalRecords is the number of rows matching the query across all pages.
+	TotalRecords int64
+
+	// Truncated tells if the results are truncated, e.g. because the query has no order and
+	// the results are larger than the page.
+	Truncated bool
+
+	// SkipToken is the token of the next page, empty for the last page.
+	SkipToken string
+
+	// Quota is the throttling quota of the caller after this page, nil when Azure Resource Graph doesn't report it.
+	Quota *Quota
+}
+
+// Quota is the throttling quota of the caller, see
+// https://learn.microsoft.com/en-us/azure/governance/resource-graph/concepts/guidance-for-throttled-requests.
+type Quota struct {
+	// Remaining is the number of queries the caller can send before being throttled.
+	Remaining int
+
+	// ResetsAfter is the time until the quota resets to its full value.
+	ResetsAfter time.Duration
+}
+
+// Pager iterates over the pages of query results. This is the paging core of [Exec], [Stream],
+// [ExecInto] and [StreamTable] which can be used to handle the raw pages directly.
+//
+// Example:
+//
+//	pager, err := rg.NewPager(ctx, query, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	for pager.HasNext() {
+//		page, err := pager.Next()
+//		if err != nil {
+//			panic(err)
+//		}
+//
+//		var rows []record
+//		if err := pager.Decode(page, &rows); err != nil {
+//			panic(err)
+//		}
+//	}
+type Pager struct {
+	pager   *armresourcegraph2.QueryPager
+	decoder Decoder
+
+	// onRowError is not nil when the rows are decoded one by one.
+	onRowError func(err RowError) error
+
+	// orderKey is the column to drop the duplicate rows by, see [ExecOptions.EnsureStableOrder].
+	orderKey string
+
+	// seen are the keys of the rows of the previous pages.
+	seen map[string]bool
+}
+
+// NewPager creates the pager over the results of the query. No request is sent until [Pager.Next] is called.
+// The decoding of the rows by [Pager.Decode] follows the client decoder and [ExecOptions.Lenient] and
+// [ExecOptions.OnRowError]. Note that the pager doesn't stop at [ExecOptions.First] rows, the caller does.
+func NewPager(ctx context.Context, query string, options *ExecOptions) (*Pager, error) {
+	queryRequest, err := newQueryRequest(query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPager(ctx, queryRequest, options)
+}
+
+func newPager(ctx context.Context, queryRequest armresourcegraph2.QueryRequest, options *ExecOptions) (*Pager, error) {
+	client, err := getClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Pager{
+		pager:   armresourcegraph2.Pages(client.armClient, ctx, queryRequest),
+		decoder: client.decoder,
+	}
+
+	if options != nil {
+		if options.Lenient {
+			result.decoder = LenientDecoder
+		}
+		result.onRowError = options.OnRowError
+		if result.orderKey = options.orderKey(); result.orderKey != "" {
+			result.seen = map[string]bool{}
+		}
+	}
+
+	return result, nil
+}
+
+// HasNext tells if there is next page.
+func (p *Pager) HasNext() bool {
+	return p.pager.HasNext()
+}
+
+// Next returns the current page and advances to the next page.
+func (p *Pager) Next() (*Page, error) {
+	page, err := p.pager.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Data) == 0 {
+		// An empty response body, or one without data, would otherwise fail decoding with a cryptic error.
+		return nil, fmt.Errorf("page %d: the response of Azure Resource Graph has no data", page.Index)
+	}
+
+	result := &Page{
+		Index: page.Index,
+		Data:  json.RawMessage(page.Data),
+	}
+	if page.Count != nil {
+		result.Count = *page.Count
+	}
+	if page.TotalRecords != nil {
+		result.TotalRecords = *page.TotalRecords
+	}
+	if page.ResultTruncated != nil {
+		result.Truncated = *page.ResultTruncated == armresourcegraph2.ResultTruncatedTrue
+	}
+	if page.SkipToken != nil {
+		result.SkipToken = *page.SkipToken
+	}
+	if page.QuotaRemaining != nil {
+		result.Quota = &Quota{Remaining: *page.QuotaRemaining}
+		if page.QuotaResetsAfter != nil {
+			result.Quota.ResetsAfter = *page.QuotaResetsAfter
+		}
+	}
+
+	if p.seen != nil {
+		if err := p.dropDuplicates(result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// dropDuplicates removes the rows of the page with the same key as a row seen before, which
+// Azure Resource Graph returns when the results change while paging.
+func (p *Pager) dropDuplicates(page *Page) error {
+	if len(page.Data) == 0 {
+		return nil
+	}
+
+	// The keys of this page are only remembered after it, as rows of one page can share the key,
+	// e.g. after mv-expand.
+	pageKeys := map[string]bool{}
+	defer func() {
+		for key := range pageKeys {
+			p.seen[key] = true
+		}
+	}()
+
+	var dropped int
+	if page.Data[0] == '[' {
+		var rows []jsoniter.RawMessage
+		if err := jsoniter.Unmarshal(page.Data, &rows); err != nil {
+			return fmt.Errorf("unmarshalling type %T: %w", rows, err)
+		}
+
+		var kept []jsoniter.RawMessage
+		for _, row := range rows {
+			if p.isDuplicate(jsoniter.Get(row, p.orderKey), pageKeys) {
+				dropped++
+				continue
+			}
+			kept = append(kept, row)
+		}
+
+		if dropped > 0 {
+			data, err := jsoniter.Marshal(kept)
+			if err != nil {
+				return err
+			}
+			page.Data = data
+		}
+	} else {
+		var table tablePage
+		if err := jsoniter.Unmarshal(page.Data, &table); err != nil {
+			return fmt.Errorf("unmarshalling type %T: %w", table, err)
+		}
+
+		column := -1
+		for i, c := range table.Columns {
+			if c.Name != nil && *c.Name == p.orderKey {
+				column = i
+			}
+		}
+
+		if column < 0 {
+			return nil
+		}
+
+		var kept [][]jsoniter.RawMessage
+		for _, row := range table.Rows {
+			if column < len(row) && p.isDuplicate(jsoniter.Get(row[column]), pageKeys) {
+				dropped++
+				continue
+			}
+			kept = append(kept, row)
+		}
+
+		if dropped > 0 {
+			table.Rows = kept
+			data, err := jsoniter.Marshal(table)
+			if err != nil {
+				return err
+			}
+			page.Data = data
+		}
+	}
+
+	if dropped > 0 {
+		page.Count -= int64(dropped)
+		log.Printf("rg: dropped %d row(s) of page %d with the same '%s' as rows of the previous pages", dropped, page.Index, p.orderKey)
+	}
+
+	return nil
+}
+
+// isDuplicate tells if the key was seen in the previous pages, and adds it to the keys of the page.
+// Rows without the key are never duplicates.
+func (p *Pager) isDuplicate(key jsoniter.Any, pageKeys map[string]bool) bool {
+	if key.ValueType() == jsoniter.InvalidValue || key.ValueType() == jsoniter.NilValue {
+		return false
+	}
+
+	text := key.ToString()
+	if p.seen[text] {
+		return true
+	}
+
+	pageKeys[text] = true
+	return false
+}
+
+// eachRow runs the pager and handles the rows of the pages until there are no more pages, or first rows are
+// handled when first is not zero. For each page, decode returns the number of rows and the function handling
+// the row at an index. This is the paging loop of the functions running queries, like [Stream] and [Watch].
+func (p *Pager) eachRow(first int, decode func(page *Page) (int, func(i int) error, error)) error {
+	count := 0
+	for p.HasNext() && (first == 0 || count < first) {
+		page, err := p.Next()
+		if err != nil {
+			return err
+		}
+
+		n, handle, err := decode(page)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < n && (first == 0 || count < first); i++ {
+			if err := handle(i); err != nil {
+				return err
+			}
+			count++
+		}
+	}
+
+	return nil
+}
+
+// Decode unmarshals the rows of the page into out, which must be a pointer to a slice.
+// The rows are appended to the slice. The rows of table-format results are the arrays of
+// the values in the order of the columns, e.g. out can be a *[][]any.
+func (p *Pager) Decode(page *Page, out any) error {
+	slice, err := slicePointer(out)
+	if err != nil {
+		return err
+	}
+
+	data, err := rowsData(page.Data)
+	if err != nil {
+		return err
+	}
+
+	if p.onRowError == nil {
+		// Decoding into a new slice keeps the rows already in out.
+		rows := reflect.New(slice.Type())
+		if err := p.decoder.Unmarshal(data, rows.Interface()); err != nil {
+			return fmt.Errorf("unmarshalling type %v: %w", slice.Type(), err)
+		}
+
+		slice.Set(reflect.AppendSlice(slice, rows.Elem()))
+		return nil
+	}
+
+	// The rows are split with jsoniter whatever the decoder is, as the decoder may not know jsoniter.RawMessage.
+	var raw []json.RawMessage
+	if err := jsoniter.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("unmarshalling type %T: %w", raw, err)
+	}
+
+	elemType := slice.Type().Elem()
+	for i, row := range raw {
+		item := reflect.New(elemType)
+		if err := p.decoder.Unmarshal(row, item.Interface()); err != nil {
+			err = fmt.Errorf("unmarshalling type %v: %w", elemType, err)
+			if err := p.onRowError(RowError{Page: page.Index, Index: i, Raw: row, Err: err}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		slice.Set(reflect.Append(slice, item.Elem()))
+	}
+
+	return nil
+}
+
+// rowsData returns the JSON array of the rows of the page data: the data itself for the results as
+// objects, or the rows of the table for table-format results, which are arrays of the values in the
+// order of the columns.
+func rowsData(data json.RawMessage) (json.RawMessage, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return data, nil
+	}
+
+	var table struct {
+		Rows json.RawMessage `json:"rows"`
+	}
+	if err := jsoniter.Unmarshal(trimmed, &table); err != nil {
+		return nil, fmt.Errorf("unmarshalling type %T: %w", table, err)
+	}
+
+	if len(table.Rows) == 0 {
+		return json.RawMessage("[]"), nil
+	}
+
+	return table.Rows, nil
+}
+
+// slicePointer returns the slice which out points to.
+func slicePointer(out any) (reflect.Value, error) {
+	if out == nil {
+		return reflect.Value{}, fmt.Errorf("the out parameter must not be nil")
+	}
+
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer {
+		return reflect.Value{}, fmt.Errorf("the out parameter of type '%v' must be a pointer", rv.Type())
+	}
+
+	if rv.IsNil() {
+		return reflect.Value{}, fmt.Errorf("the out parameter of type '%v' must not be nil", rv.Type())
+	}
+
+	if rv.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("the type of out parameter '%v' must be a pointer to a slice", rv.Type())
+	}
+
+	return rv.Elem(), nil
+}
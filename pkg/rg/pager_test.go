@@ -0,0 +1,165 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"name": "a"}, {"name": "b"}]`,
+		`[{"name": "c"}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	pager, err := rg.NewPager(context.Background(), "resources", &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []record
+	var pages []*rg.Page
+	for pager.HasNext() {
+		page, err := pager.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, page)
+
+		if err := pager.Decode(page, &items); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(pages) != 2 || pages[0].Index != 0 || pages[0].Count != 2 || pages[0].SkipToken != "1" || pages[1].Index != 1 || pages[1].SkipToken != "" {
+		t.Errorf("unexpected pages %+v %+v", pages[0], pages[1])
+	}
+
+	if len(items) != 3 || items[2].Name != "c" {
+		t.Errorf("got %v, want the rows of both pages", items)
+	}
+}
+
+func TestPagerFirstSkip(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`[{"name": "a"}]`, `[{"name": "b"}]`, `[{"name": "c"}]`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	items, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client, First: 2500, Skip: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 3 || len(server.Requests()) != 3 {
+		t.Fatalf("got %d rows in %d requests, want 3 in 3", len(items), len(server.Requests()))
+	}
+
+	first := server.Requests()[0].Options
+	if first.Top == nil || *first.Top != 1000 || first.Skip == nil || *first.Skip != 5 || first.SkipToken != "" {
+		t.Errorf("the first request has $top %v, $skip %v and $skipToken '%s', want 1000, 5 and none", first.Top, first.Skip, first.SkipToken)
+	}
+
+	for i, req := range server.Requests()[1:] {
+		if req.Options.Top != nil || req.Options.Skip != nil || req.Options.SkipToken == "" {
+			t.Errorf("request %d has $top %v and $skip %v, want only $skipToken", i+1, req.Options.Top, req.Options.Skip)
+		}
+	}
+}
+
+func TestPagerFirstBelowPageSize(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`[{"name": "a"}]`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	if _, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client, First: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	options := server.Requests()[0].Options
+	if options.Top == nil || *options.Top != 10 || options.Skip != nil {
+		t.Errorf("got $top %v and $skip %v, want 10 and none", options.Top, options.Skip)
+	}
+}
+
+func TestPagerEnsureStableOrder(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"id": "a", "zone": "1"}, {"id": "a", "zone": "2"}, {"id": "b", "zone": "1"}]`,
+		`[{"id": "b", "zone": "1"}, {"id": "c"}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	type zoneRecord struct {
+		ID   string `json:"id"`
+		Zone string `json:"zone"`
+	}
+
+	items, err := rg.Exec[zoneRecord](context.Background(), "resources | mv-expand zone = zones | project id, zone", &rg.ExecOptions{Client: client, EnsureStableOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows of a page with the same key are kept, only the row repeating a previous page is dropped.
+	want := []zoneRecord{{"a", "1"}, {"a", "2"}, {"b", "1"}, {"c", ""}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got %v, want %v", items, want)
+	}
+
+	if query := server.Requests()[0].Query; !strings.HasSuffix(query, "\n| order by id asc") {
+		t.Errorf("got query %q, want it ordered by id", query)
+	}
+
+	if _, err := rg.Exec[zoneRecord](context.Background(), "resources | distinct type", &rg.ExecOptions{Client: client, EnsureStableOrder: true}); err == nil {
+		t.Error("Exec succeeded, want an error as the results have no id column")
+	}
+}
+
+func TestPagerDecodeTable(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`{"columns": [{"name": "zone", "type": "integer"}, {"name": "count", "type": "integer"}], "rows": [[1, 2], ["x", 3], [4, 5]]}`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	for _, onRowError := range []bool{false, true} {
+		var rowErrors rg.RowErrors
+		options := &rg.ExecOptions{Client: client}
+		if onRowError {
+			options.OnRowError = rowErrors.Collect
+		}
+
+		pager, err := rg.NewPager(context.Background(), "resources | summarize count() by zone", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page, err := pager.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rows [][]any
+		if err := pager.Decode(page, &rows); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 3 || rows[1][0] != "x" {
+			t.Errorf("got rows %v, want the 3 rows of the table", rows)
+		}
+
+		// Only the row with the string doesn't fit the integers.
+		var ints [][]int
+		err = pager.Decode(page, &ints)
+		switch {
+		case !onRowError && err == nil:
+			t.Error("Decode succeeded, want an error")
+		case onRowError && err != nil:
+			t.Fatal(err)
+		case onRowError && (len(ints) != 2 || len(rowErrors) != 1 || rowErrors[0].Index != 1 || string(rowErrors[0].Raw) != `["x", 3]`):
+			t.Errorf("got rows %v and errors %+v, want rows 0 and 2 and the error of row 1", ints, rowErrors)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"strings"
+)
+
+// ResourceID is a parsed Azure resource ID, like
+// "/subscriptions/{id}/resourceGroups/{name}/providers/Microsoft.Compute/virtualMachines/{name}".
+//
+// It can be used as a struct field in query results instead of a string, it unmarshals from
+// JSON strings and an empty string or null unmarshal as the zero ResourceID. Resource IDs
+// compare case-insensitively the way Azure Resource Manager does, use [ResourceID.Equal]
+// to compare them and [ResourceID.Key] as a map key to join results by ID.
+//
+// Example:
+//
+//	type record struct {
+//		ID        rg.ResourceID `json:"id"`
+//		ManagedBy rg.ResourceID `json:"managedBy"`
+//	}
+//
+//	disks, err := rg.Exec[record](ctx, "resources | where type =~ 'microsoft.compute/disks' | project id, managedBy", nil)
+type ResourceID struct {
+	// SubscriptionID is the subscription of the resource, empty for tenant and management group level resources.
+	SubscriptionID string
+
+	// ResourceGroup is the resource group of the resource, empty for subscription and higher level resources.
+	ResourceGroup string
+
+	// Provider is the resource provider namespace, e.g. "Microsoft.Compute". It is empty for
+	// subscriptions and resource groups.
+	Provider string
+
+	// Types is the chain of resource types under the provider, e.g. ["virtualMachines", "extensions"]
+	// for a VM extension.
+	Types []string
+
+	// Names are the names of the resources in the Types chain, e.g. ["vm1", "ext1"] for a VM extension.
+	Names []string
+
+	// Scope is the resource which an extension resource belongs to, e.g. the virtual machine of
+	// a diagnostic setting in ".../virtualMachines/vm1/providers/Microsoft.Insights/diagnosticSettings/ds1".
+	// It is nil for other resources.
+	Scope *ResourceID
+}
+
+// ParseResourceID parses the resource ID. The keywords "subscriptions", "resourceGroups" and
+// "providers" are matched case-insensitively.
+func ParseResourceID(id string) (ResourceID, error) {
+	trimmed := strings.Trim(strings.TrimSpace(id), "/")
+	if trimmed == "" {
+		return ResourceID{}, fmt.Errorf("invalid resource ID '%s': the ID is empty", id)
+	}
+
+	segments := strings.Split(trimmed, "/")
+	for _, s := range segments {
+		if s == "" {
+			return ResourceID{}, fmt.Errorf("invalid resource ID '%s': the ID has an empty segment", id)
+		}
+	}
+
+	var result ResourceID
+	i := 0
+	if strings.EqualFold(segments[i], "subscriptions") {
+		if len(segments) < 2 {
+			return ResourceID{}, fmt.Errorf("invalid resource ID '%s': the subscription ID is missing", id)
+		}
+		result.SubscriptionID = segments[1]
+		i = 2
+
+		if i < len(segments) && strings.EqualFold(segments[i], "resourceGroups") {
+			if len(segments) < i+2 {
+				return ResourceID{}, fmt.Errorf("invalid resource ID '%s': the resource group name is missing", id)
+			}
+			result.ResourceGroup = segments[i+1]
+			i += 2
+		}
+	}
+
+	for i < len(segments) {
+		if !strings.EqualFold(segments[i], "providers") {
+			return ResourceID{}, fmt.Errorf("invalid resource ID '%s': expected 'providers' at segment %d", id, i+1)
+		}
+
+		if result.Provider != "" {
+			// This is an extension resource, what has been parsed so far is its scope.
+			scope := result
+			result = ResourceID{
+				SubscriptionID: scope.SubscriptionID,
+				ResourceGroup:  scope.ResourceGroup,
+				Scope:          &scope,
+			}
+		}
+
+		// Types and names come in pairs up to the next "providers".
+		end := i + 2
+		for end < len(segments) && !strings.EqualFold(segments[end], "providers") {
+			end++
+		}
+
+		if end > len(segments) || end-i-2 == 0 || (end-i-2)%2 != 0 {
+			return ResourceID{}, fmt.Errorf("invalid resource ID '%s': expected the provider namespace followed by type and name pairs", id)
+		}
+		pairs := segments[i+2 : end]
+
+		result.Provider = segments[i+1]
+		for j := 0; j < len(pairs); j += 2 {
+			result.Types = append(result.Types, pairs[j])
+			result.Names = append(result.Names, pairs[j+1])
+		}
+
+		i = end
+	}
+
+	if result.SubscriptionID == "" && result.Provider == "" {
+		return ResourceID{}, fmt.Errorf("invalid resource ID '%s': expected 'subscriptions' or 'providers' at segment 1", id)
+	}
+
+	return result, nil
+}
+
+// IsZero tells if the resource ID is empty.
+func (id ResourceID) IsZero() bool {
+	return id.SubscriptionID == "" && id.Provider == ""
+}
+
+// String returns the resource ID text with the keywords in their canonical case.
+func (id ResourceID) String() string {
+	if id.IsZero() {
+		return ""
+	}
+
+	var sb strings.Builder
+	if id.Scope != nil {
+		sb.WriteString(id.Scope.String())
+	} else if id.SubscriptionID != "" {
+		sb.WriteString("/subscriptions/")
+		sb.WriteString(id.SubscriptionID)
+		if id.ResourceGroup != "" {
+			sb.WriteString("/resourceGroups/")
+			sb.WriteString(id.ResourceGroup)
+		}
+	}
+
+	if id.Provider != "" {
+		sb.WriteString("/providers/")
+		sb.WriteString(id.Provider)
+		for i := range id.Types {
+			sb.WriteString("/")
+			sb.WriteString(id.Types[i])
+			sb.WriteString("/")
+			sb.WriteString(id.Names[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// Key returns the resource ID text in lower case, so that IDs which are equal have the same key.
+func (id ResourceID) Key() string {
+	return strings.ToLower(id.String())
+}
+
+// Equal tells if the resource IDs are the same, ignoring case.
+func (id ResourceID) Equal(other ResourceID) bool {
+	return strings.EqualFold(id.String(), other.String())
+}
+
+// Name returns the name of the resource: the last name in the type chain, the resource
+// group name for resource groups, or the subscription ID for subscriptions.
+func (id ResourceID) Name() string {
+	switch {
+	case len(id.Names) > 0:
+		return id.Names[len(id.Names)-1]
+	case id.ResourceGroup != "":
+		return id.ResourceGroup
+	default:
+		return id.SubscriptionID
+	}
+}
+
+// ResourceType returns the full resource type, e.g. "Microsoft.Compute/virtualMachines/extensions".
+// Resource groups and subscriptions have "Microsoft.Resources/resourceGroups" and
+// "Microsoft.Resources/subscriptions" types.
+func (id ResourceID) ResourceType() string {
+	switch {
+	case id.Provider != "":
+		return id.Provider + "/" + strings.Join(id.Types, "/")
+	case id.ResourceGroup != "":
+		return "Microsoft.Resources/resourceGroups"
+	case id.SubscriptionID != "":
+		return "Microsoft.Resources/subscriptions"
+	default:
+		return ""
+	}
+}
+
+// Parent returns the resource which contains this one: the parent resource for child resources,
+// the scope for extension resources, the resource group for top-level resources in resource groups,
+// and the subscription for resource groups and subscription level resources. It returns the zero
+// ResourceID for subscriptions and tenant level resources.
+func (id ResourceID) Parent() ResourceID {
+	switch {
+	case len(id.Types) > 1:
+		result := id
+		result.Types = append([]string(nil), id.Types[:len(id.Types)-1]...)
+		result.Names = append([]string(nil), id.Names[:len(id.Names)-1]...)
+		return result
+	case id.Scope != nil:
+		return *id.Scope
+	case id.Provider != "":
+		return ResourceID{SubscriptionID: id.SubscriptionID, ResourceGroup: id.ResourceGroup}
+	case id.ResourceGroup != "":
+		return ResourceID{SubscriptionID: id.SubscriptionID}
+	default:
+		return ResourceID{}
+	}
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (id ResourceID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler]. Empty text unmarshals as the zero ResourceID.
+func (id *ResourceID) UnmarshalText(text []byte) error {
+	if len(strings.TrimSpace(string(text))) == 0 {
+		*id = ResourceID{}
+		return nil
+	}
+
+	parsed, err := ParseResourceID(string(text))
+	if err != nil {
+		return err
+	}
+
+	*id = parsed
+	return nil
+}
+
+// MarshalJSON implements [json.Marshaler], the resource ID is marshalled as a JSON string.
+func (id ResourceID) MarshalJSON() ([]byte, error) {
+	return jsoniter.Marshal(id.String())
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. Null and empty strings unmarshal as the zero ResourceID.
+func (id *ResourceID) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := jsoniter.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("resource ID must be a string: %w", err)
+	}
+
+	if s == nil {
+		*id = ResourceID{}
+		return nil
+	}
+
+	return id.UnmarshalText([]byte(*s))
+}
@@ -0,0 +1,188 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseResourceID(t *testing.T) {
+	tests := []struct {
+		id           string
+		want         rg.ResourceID
+		name         string
+		resourceType string
+		parent       string
+	}{
+		{
+			id:           "/subscriptions/sub1",
+			want:         rg.ResourceID{SubscriptionID: "sub1"},
+			name:         "sub1",
+			resourceType: "Microsoft.Resources/subscriptions",
+		},
+		{
+			id:           "/subscriptions/sub1/resourceGroups/rg1",
+			want:         rg.ResourceID{SubscriptionID: "sub1", ResourceGroup: "rg1"},
+			name:         "rg1",
+			resourceType: "Microsoft.Resources/resourceGroups",
+			parent:       "/subscriptions/sub1",
+		},
+		{
+			id:           "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1",
+			want:         rg.ResourceID{SubscriptionID: "sub1", ResourceGroup: "rg1", Provider: "Microsoft.Compute", Types: []string{"virtualMachines"}, Names: []string{"vm1"}},
+			name:         "vm1",
+			resourceType: "Microsoft.Compute/virtualMachines",
+			parent:       "/subscriptions/sub1/resourceGroups/rg1",
+		},
+		{
+			id:           "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Sql/servers/srv1/databases/db1/backupShortTermRetentionPolicies/default",
+			want:         rg.ResourceID{SubscriptionID: "sub1", ResourceGroup: "rg1", Provider: "Microsoft.Sql", Types: []string{"servers", "databases", "backupShortTermRetentionPolicies"}, Names: []string{"srv1", "db1", "default"}},
+			name:         "default",
+			resourceType: "Microsoft.Sql/servers/databases/backupShortTermRetentionPolicies",
+			parent:       "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Sql/servers/srv1/databases/db1",
+		},
+		{
+			id: "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1/providers/Microsoft.Insights/diagnosticSettings/ds1",
+			want: rg.ResourceID{SubscriptionID: "sub1", ResourceGroup: "rg1", Provider: "Microsoft.Insights", Types: []string{"diagnosticSettings"}, Names: []string{"ds1"},
+				Scope: &rg.ResourceID{SubscriptionID: "sub1", ResourceGroup: "rg1", Provider: "Microsoft.Compute", Types: []string{"virtualMachines"}, Names: []string{"vm1"}}},
+			name:         "ds1",
+			resourceType: "Microsoft.Insights/diagnosticSettings",
+			parent:       "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1",
+		},
+		{
+			id:           "/subscriptions/sub1/providers/Microsoft.Authorization/roleAssignments/ra1",
+			want:         rg.ResourceID{SubscriptionID: "sub1", Provider: "Microsoft.Authorization", Types: []string{"roleAssignments"}, Names: []string{"ra1"}},
+			name:         "ra1",
+			resourceType: "Microsoft.Authorization/roleAssignments",
+			parent:       "/subscriptions/sub1",
+		},
+		{
+			id:           "/providers/Microsoft.Management/managementGroups/mg1",
+			want:         rg.ResourceID{Provider: "Microsoft.Management", Types: []string{"managementGroups"}, Names: []string{"mg1"}},
+			name:         "mg1",
+			resourceType: "Microsoft.Management/managementGroups",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := rg.ParseResourceID(tt.id)
+		if err != nil {
+			t.Errorf("ParseResourceID(%q) failed: %v", tt.id, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseResourceID(%q) = %+v, want %+v", tt.id, got, tt.want)
+		}
+
+		if got.String() != tt.id || got.Name() != tt.name || got.ResourceType() != tt.resourceType || got.Parent().String() != tt.parent {
+			t.Errorf("ParseResourceID(%q) has string %q, name %q, type %q and parent %q", tt.id, got.String(), got.Name(), got.ResourceType(), got.Parent().String())
+		}
+	}
+}
+
+func TestParseResourceIDCase(t *testing.T) {
+	id, err := rg.ParseResourceID("/SUBSCRIPTIONS/Sub1/resourcegroups/RG1/PROVIDERS/microsoft.compute/VIRTUALMACHINES/VM1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keywords get their canonical case, the other segments keep theirs.
+	if id.String() != "/subscriptions/Sub1/resourceGroups/RG1/providers/microsoft.compute/VIRTUALMACHINES/VM1" {
+		t.Errorf("got %q", id.String())
+	}
+
+	other, err := rg.ParseResourceID("subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !id.Equal(other) || id.Key() != other.Key() || id.Key() != strings.ToLower(other.String()) {
+		t.Errorf("%q and %q are not equal, the keys are %q and %q", id, other, id.Key(), other.Key())
+	}
+}
+
+func TestParseResourceIDErrors(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"", "the ID is empty"},
+		{" / ", "the ID is empty"},
+		{"/subscriptions//resourceGroups/rg1", "the ID has an empty segment"},
+		{"/subscriptions", "the subscription ID is missing"},
+		{"/subscriptions/sub1/resourceGroups", "the resource group name is missing"},
+		{"/subscriptions/sub1/resourceGroups/rg1/virtualMachines/vm1", "expected 'providers' at segment 5"},
+		{"/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute", "expected the provider namespace followed by type and name pairs"},
+		{"/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines", "expected the provider namespace followed by type and name pairs"},
+		{"/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1/extensions", "expected the provider namespace followed by type and name pairs"},
+		{"/resourceGroups/rg1", "expected 'providers' at segment 1"},
+	}
+
+	for _, tt := range tests {
+		_, err := rg.ParseResourceID(tt.id)
+		if err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("ParseResourceID(%q) error %v, want %q", tt.id, err, tt.want)
+		}
+	}
+}
+
+func TestResourceIDJSON(t *testing.T) {
+	type record struct {
+		ID        rg.ResourceID `json:"id"`
+		ManagedBy rg.ResourceID `json:"managedBy"`
+	}
+
+	data := `{"id":"/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/disks/disk1","managedBy":""}`
+
+	var r record
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.ID.Name() != "disk1" || !r.ManagedBy.IsZero() {
+		t.Errorf("got %+v", r)
+	}
+
+	out, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != data {
+		t.Errorf("got %s, want %s", out, data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"id": null}`), &r); err != nil || !r.ID.IsZero() {
+		t.Errorf("got %+v, %v, want the zero ID for null", r.ID, err)
+	}
+
+	for _, invalid := range []string{`{"id": 42}`, `{"id": "/resourceGroups/rg1"}`} {
+		if err := json.Unmarshal([]byte(invalid), &r); err == nil {
+			t.Errorf("unmarshalling %s succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestResourceIDText(t *testing.T) {
+	want := "/subscriptions/sub1/resourceGroups/rg1"
+
+	var id rg.ResourceID
+	if err := id.UnmarshalText([]byte(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := id.MarshalText()
+	if err != nil || string(text) != want {
+		t.Errorf("got %q, %v, want %q", text, err, want)
+	}
+
+	if err := id.UnmarshalText([]byte(" ")); err != nil || !id.IsZero() {
+		t.Errorf("got %+v, %v, want the zero ID for empty text", id, err)
+	}
+}
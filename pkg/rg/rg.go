@@ -8,9 +8,13 @@ package rg
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/armresourcegraph2"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/kql"
+	"reflect"
 	"sync"
 )
 
@@ -52,87 +56,295 @@ func getDefaultArmClient() (*armresourcegraph2.Client, error) {
 	return defaultArmClient.armClient, defaultArmClient.err
 }
 
-// ExecOptions is reserved for future expandability, e.g. providing subscription list.
+// ExecOptions are the optional parameters for [Exec], [Stream] and other functions running queries.
 type ExecOptions struct {
+	// Subscriptions against which to execute the query. When both Subscriptions and
+	// ManagementGroups are empty, the query runs against all subscriptions accessible
+	// to the credential.
+	Subscriptions []string
+
+	// ManagementGroups against which to execute the query.
+	ManagementGroups []string
+
+	// First is the maximum number of rows to return. Zero means all rows.
+	First int
+
+	// Skip is the number of rows to skip from the beginning of the results.
+	Skip int
+
+	// Client is the client which runs the query. When nil, the shared default client is used,
+	// see [NewDefaultClient].
+	Client *Client
+
+	// Lenient enables tolerant decoding of rows: time.Time fields accept datetime strings in
+	// several formats, time.Duration fields accept KQL timespans like "1.02:03:04", and numeric
+	// and bool fields accept strings like "42". Without it, such a mismatch fails the whole page.
+	// It takes precedence over the decoder of the client, see [LenientDecoder].
+	Lenient bool
+
+	// OnRowError enables decoding of rows one by one, so that a row which fails to decode doesn't
+	// fail the whole page. Such rows are left out of the results and OnRowError is called for each
+	// of them. If it returns an error, the execution stops with that error. See [RowErrors] to collect
+	// the errors and decide what to do once the execution completes.
+	OnRowError func(err RowError) error
+
+	// EnsureStableOrder appends "| order by OrderKey asc" to the query when its results are not
+	// sorted at the end, as Azure Resource Graph can return duplicate or missing rows across pages
+	// of unsorted results. The rows with the same key as a row of a previous page are dropped with
+	// a logged warning. The query fails when its results don't have the OrderKey column, or when it
+	// can't be told, see [kql.EnsureOrder].
+	EnsureStableOrder bool
+
+	// Enrich fills the struct fields of the rows tagged with "rg" from the resourcecontainers table,
+	// which saves joining it in the query:
+	//
+	//   - rg:"subscriptionName" string: the name of the subscription
+	//   - rg:"managementGroupPath" string or []string: the display names of the management groups of
+	//     the subscription from the root down, joined with "/" for strings
+	//   - rg:"resourceGroupTags" map[string]string: the tags of the resource group
+	//
+	// The subscription and the resource group of the row are taken from the fields with JSON names
+	// subscriptionId and resourceGroup, or else from the resource ID in the id field, which can be a
+	// string or a [ResourceID]. The lookups are cached by the client, see [ClientOptions.LookupTTL].
+	// Only [Exec], [Stream] and [ExecInto] enrich the rows.
+	//
+	// Example:
+	//
+	//	type record struct {
+	//		ID               string            `json:"id"`
+	//		SubscriptionName string            `rg:"subscriptionName"`
+	//		ManagementGroups []string          `rg:"managementGroupPath"`
+	//		GroupTags        map[string]string `rg:"resourceGroupTags"`
+	//	}
+	//
+	//	items, err := rg.Exec[record](ctx, "resources | project id", &rg.ExecOptions{Enrich: true})
+	Enrich bool
+
+	// OrderKey is the column of [ExecOptions.EnsureStableOrder], "id" when empty. It must be a column
+	// of the results, e.g. a summarize by type needs "type".
+	OrderKey string
 }
 
-// Exec executes Azure Resource Graph query and returns rows from the result unmarshalled as an array of T.
-//
-// This function uses shared cached Azure Token Credential obtained by calling official Azure SDK for Go
-// function [azidentity.NewDefaultAzureCredential].
+// defaultOrderKey is the default [ExecOptions.OrderKey].
+const defaultOrderKey = "id"
+
+// orderKey returns the column of [ExecOptions.EnsureStableOrder], or empty when it is not enabled.
+func (o *ExecOptions) orderKey() string {
+	switch {
+	case o == nil || !o.EnsureStableOrder:
+		return ""
+	case o.OrderKey != "":
+		return o.OrderKey
+	default:
+		return defaultOrderKey
+	}
+}
+
+// first returns [ExecOptions.First], zero when the options are nil.
+func (o *ExecOptions) first() int {
+	if o == nil {
+		return 0
+	}
+	return o.First
+}
+
+// RowError is the error decoding a single row of the results.
+type RowError struct {
+	// Page is the zero-based index of the page with the row.
+	Page int
+
+	// Index is the zero-based index of the row in the page.
+	Index int
+
+	// Raw is the row JSON as returned by Azure Resource Graph.
+	Raw json.RawMessage
+
+	// Err is the decoding error.
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("page %d, row %d: %s", e.Page, e.Index, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// RowErrors collects the errors of the rows which fail to decode.
 //
 // Example:
 //
-//	type record struct {
-//		Name string
-//		Type string
-//	}
-//
-//	items, err := rg.Exec(context.Background, "resources | project name, type | order by name, type", nil)
+//	var rowErrors rg.RowErrors
+//	items, err := rg.Exec[record](ctx, query, &rg.ExecOptions{OnRowError: rowErrors.Collect})
 //	if err != nil {
 //		panic(err)
 //	}
 //
-//	for _, item := range items {
-//		fmt.printf("%s, %s\n", item.Name, item.Type)
-//  }
-func Exec[T any](ctx context.Context, query string, options *ExecOptions) ([]T, error) {
+//	for _, e := range rowErrors {
+//		log.Printf("skipped %s: %s", e.Raw, e)
+//	}
+type RowErrors []RowError
+
+// Collect adds the error to the collection and returns nil, so that the execution continues.
+// It can be used as [ExecOptions.OnRowError].
+func (e *RowErrors) Collect(err RowError) error {
+	*e = append(*e, err)
+	return nil
+}
+
+// maxPageSize is the maximum number of rows Azure Resource Graph returns in a single page.
+const maxPageSize = 1000
+
+// newQueryRequest builds the query request for the given query text and options.
+func newQueryRequest(query string, options *ExecOptions) (armresourcegraph2.QueryRequest, error) {
+	if key := options.orderKey(); key != "" {
+		ordered, err := kql.EnsureOrder(query, key)
+		if err != nil {
+			return armresourcegraph2.QueryRequest{}, fmt.Errorf("ensuring stable order: %w", err)
+		}
+		query = ordered
+	}
+
 	queryRequest := armresourcegraph2.QueryRequest{
 		Query: &query,
 	}
 
-	client, err := getDefaultArmClient()
-	if err != nil {
-		return nil, err
+	if options == nil {
+		return queryRequest, nil
+	}
+
+	for i := range options.Subscriptions {
+		queryRequest.Subscriptions = append(queryRequest.Subscriptions, &options.Subscriptions[i])
 	}
 
-	return armresourcegraph2.ResourcesAll2[T](client, ctx, queryRequest)
+	for i := range options.ManagementGroups {
+		queryRequest.ManagementGroups = append(queryRequest.ManagementGroups, &options.ManagementGroups[i])
+	}
+
+	if options.First > 0 || options.Skip > 0 {
+		queryRequest.Options = &armresourcegraph2.QueryRequestOptions{}
+	}
+
+	if options.First > 0 {
+		top := int32(maxPageSize)
+		if options.First < maxPageSize {
+			top = int32(options.First)
+		}
+		queryRequest.Options.Top = &top
+	}
+
+	if options.Skip > 0 {
+		skip := int32(options.Skip)
+		queryRequest.Options.Skip = &skip
+	}
+
+	return queryRequest, nil
 }
 
-// TODO: some work in progress, keeping to keep the code as a sample
+// Exec executes Azure Resource Graph query and returns rows from the result unmarshalled as an array of T.
+//
+// Unless [ExecOptions.Client] is given, this function uses shared cached Azure Token Credential obtained
+// by calling official Azure SDK for Go function [azidentity.NewDefaultAzureCredential].
 //
-//type Client struct {
-//	c *armresourcegraph2.Client
-//	// err stores the errors related to various initializations, e.g. getting [azcore.TokenCredential].
-//	err error
-//}
+// Example:
 //
-//// NewDefaultClient creates new Azure Resource Graph query armClient with default shared Azure token credential.
-//func NewDefaultClient() *Client {
-//	result := Client{}
-//	result.c, result.err = getDefaultArmClient()
-//	return &result
-//}
+//	type record struct {
+//		Name string
+//		Type string
+//	}
 //
-//// NewClient creates new Azure Resource Graph query armClient with specified Azure token credential.
-//// Both [cred] and [options] can be nil, in which case default [azcore.TokenCredential] and
-//// [arm.ClientOptions] provided by this package will be used.
-//func NewClient(cred azcore.TokenCredential, options *arm.ClientOptions) *Client {
-//	var result Client
-//	if cred == nil && options == nil {
-//		result.c, result.err = getDefaultArmClient()
-//		return &result
+//	items, err := rg.Exec[record](context.Background(), "resources | project name, type | order by name, type", nil)
+//	if err != nil {
+//		panic(err)
 //	}
 //
-//	if cred == nil {
-//		token, err := getDefaultCredentialToken()
-//		if err != nil {
-//			result.err = err
-//			return &result
-//		}
-//		cred = token
+//	for _, item := range items {
+//		fmt.Printf("%s, %s\n", item.Name, item.Type)
 //	}
+func Exec[T any](ctx context.Context, query string, options *ExecOptions) ([]T, error) {
+	var result []T
+	err := Stream(ctx, query, options, func(row T) error {
+		result = append(result, row)
+		return nil
+	})
+
+	return result, err
+}
+
+// Stream executes Azure Resource Graph query and calls fn for each row from the result unmarshalled as T.
+// Rows are delivered page by page as they arrive, so the whole result is never held in memory.
+// If fn returns an error, the iteration stops and the error is returned.
 //
-//	result.c, result.err = armresourcegraph2.NewClient(cred, options)
-//	return &result
-//}
+// Example:
 //
-//// Exec executes specified query and unmarshalls results into [out] interface like [json.Unmarshal].
-//// The [out] parameter must be a pointer to a slice.
-//func (client *Client) Exec(ctx context.Context, query string, options *ExecOptions, out interface{}) error {
-//	queryRequest := armresourcegraph2.QueryRequest{
-//		Query: &query,
-//	}
+//	err := rg.Stream(context.Background(), "resources | project name, type", nil, func(row record) error {
+//		fmt.Printf("%s, %s\n", row.Name, row.Type)
+//		return nil
+//	})
+func Stream[T any](ctx context.Context, query string, options *ExecOptions, fn func(row T) error) error {
+	pager, err := NewPager(ctx, query, options)
+	if err != nil {
+		return err
+	}
+
+	return pager.eachRow(options.first(), func(page *Page) (int, func(i int) error, error) {
+		var rows []T
+		if err := pager.Decode(page, &rows); err != nil {
+			return 0, nil, err
+		}
+
+		if options != nil && options.Enrich {
+			if err := enrich(ctx, options, reflect.ValueOf(rows)); err != nil {
+				return 0, nil, err
+			}
+		}
+
+		return len(rows), func(i int) error {
+			return fn(rows[i])
+		}, nil
+	})
+}
+
+// ExecInto is like [Exec] for the cases when the row type is only known at runtime. It executes Azure Resource
+// Graph query and unmarshals the rows into out, which must be a pointer to a slice. The slice is replaced
+// with the rows, and on error it has the rows received so far.
 //
-//	return armresourcegraph2.ResourcesAll3(client.c, ctx, queryRequest, out)
-//}
+// Example:
+//
+//	var items []record
+//	err := rg.ExecInto(context.Background(), "resources | project name, type", nil, &items)
+func ExecInto(ctx context.Context, query string, options *ExecOptions, out any) error {
+	slice, err := slicePointer(out)
+	if err != nil {
+		return err
+	}
+
+	pager, err := NewPager(ctx, query, options)
+	if err != nil {
+		return err
+	}
+
+	rows := reflect.New(slice.Type()).Elem()
+	defer func() {
+		slice.Set(rows)
+	}()
+
+	return pager.eachRow(options.first(), func(page *Page) (int, func(i int) error, error) {
+		pageRows := reflect.New(slice.Type())
+		if err := pager.Decode(page, pageRows.Interface()); err != nil {
+			return 0, nil, err
+		}
+
+		if options != nil && options.Enrich {
+			if err := enrich(ctx, options, pageRows.Elem()); err != nil {
+				return 0, nil, err
+			}
+		}
+
+		return pageRows.Elem().Len(), func(i int) error {
+			rows = reflect.Append(rows, pageRows.Elem().Index(i))
+			return nil
+		}, nil
+	})
+}
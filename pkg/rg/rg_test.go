@@ -0,0 +1,195 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"errors"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// The pagers log every page they fetch.
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+type record struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+func TestExec(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"name": "a", "size": 1}, {"name": "b", "size": 2}]`,
+		`[{"name": "c", "size": 3}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	items, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []record{{"a", 1}, {"b", 2}, {"c", 3}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got %v, want %v", items, want)
+	}
+
+	if len(server.Requests()) != 2 || server.Requests()[0].Options.SkipToken != "" || server.Requests()[1].Options.SkipToken != "1" {
+		t.Errorf("unexpected requests %+v", server.Requests())
+	}
+}
+
+func TestExecFirst(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"name": "a"}, {"name": "b"}]`,
+		`[{"name": "c"}, {"name": "d"}]`,
+		`[{"name": "e"}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	items, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client, First: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 3 || items[2].Name != "c" {
+		t.Errorf("got %v, want the first 3 rows", items)
+	}
+
+	if len(server.Requests()) != 2 {
+		t.Errorf("got %d requests, want 2 as the rows are enough after the second page", len(server.Requests()))
+	}
+}
+
+func TestExecInto(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"name": "a", "size": 1}]`,
+		`[{"name": "b", "size": 2}, {"name": "c", "size": 3}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	items := []record{{"stale", 0}}
+	if err := rg.ExecInto(context.Background(), "resources", &rg.ExecOptions{Client: client, First: 2}, &items); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []record{{"a", 1}, {"b", 2}}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("got %v, want %v", items, want)
+	}
+}
+
+func TestExecIntoErrors(t *testing.T) {
+	var items []record
+	for _, out := range []any{nil, items, &struct{}{}, (*[]record)(nil)} {
+		if err := rg.ExecInto(context.Background(), "resources", nil, out); err == nil {
+			t.Errorf("ExecInto(%T) succeeded, want an error", out)
+		}
+	}
+}
+
+func TestExecOnRowError(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"name": "a", "size": 1}, {"name": "b", "size": "large"}]`,
+		`[{"name": "c", "size": 3}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	// Without OnRowError the whole page fails.
+	if _, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client}); err == nil {
+		t.Error("Exec succeeded, want a decoding error")
+	}
+
+	var rowErrors rg.RowErrors
+	items, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client, OnRowError: rowErrors.Collect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 || items[0].Name != "a" || items[1].Name != "c" {
+		t.Errorf("got %v, want rows a and c", items)
+	}
+
+	if len(rowErrors) != 1 || rowErrors[0].Page != 0 || rowErrors[0].Index != 1 || string(rowErrors[0].Raw) != `{"name": "b", "size": "large"}` {
+		t.Errorf("unexpected row errors %+v", rowErrors)
+	}
+
+	// The error of OnRowError stops the execution.
+	stop := errors.New("stop")
+	_, err = rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client, OnRowError: func(rg.RowError) error { return stop }})
+	if !errors.Is(err, stop) {
+		t.Errorf("got error %v, want %v", err, stop)
+	}
+}
+
+func TestExecLenient(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"created": "2024-01-02 03:04:05", "timeout": "1.02:03:04", "count": "42"}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	type lenientRecord struct {
+		Created time.Time     `json:"created"`
+		Timeout time.Duration `json:"timeout"`
+		Count   int           `json:"count"`
+	}
+
+	if _, err := rg.Exec[lenientRecord](context.Background(), "resources", &rg.ExecOptions{Client: client}); err == nil {
+		t.Error("Exec succeeded without Lenient, want an error")
+	}
+
+	items, err := rg.Exec[lenientRecord](context.Background(), "resources", &rg.ExecOptions{Client: client, Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := lenientRecord{
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout: 26*time.Hour + 3*time.Minute + 4*time.Second,
+		Count:   42,
+	}
+	if len(items) != 1 || !items[0].Created.Equal(want.Created) || items[0].Timeout != want.Timeout || items[0].Count != want.Count {
+		t.Errorf("got %+v, want %+v", items, want)
+	}
+}
+
+func TestExecErrorResponse(t *testing.T) {
+	server := &rgtest.Server{
+		Status: http.StatusBadRequest,
+		Body:   `{"error": {"code": "BadRequest", "message": "Query is invalid"}}`,
+	}
+	client := rgtest.NewClient(t, server, nil)
+
+	_, err := rg.Exec[record](context.Background(), "resources | where", &rg.ExecOptions{Client: client})
+
+	var responseError *azcore.ResponseError
+	if !errors.As(err, &responseError) {
+		t.Fatalf("got error %v, want *azcore.ResponseError", err)
+	}
+
+	if responseError.StatusCode != http.StatusBadRequest || responseError.ErrorCode != "BadRequest" {
+		t.Errorf("got status %d and code %s", responseError.StatusCode, responseError.ErrorCode)
+	}
+}
+
+func TestExecEmptyResponse(t *testing.T) {
+	server := &rgtest.Server{Status: http.StatusOK}
+	client := rgtest.NewClient(t, server, nil)
+
+	_, err := rg.Exec[record](context.Background(), "resources", &rg.ExecOptions{Client: client})
+	if err == nil || err.Error() != "page 0: the response of Azure Resource Graph has no data" {
+		t.Errorf("got error %v, want the empty response error", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package rgparquet writes Azure Resource Graph query results as Apache Parquet files.
+//
+// The schema is derived from the result columns. Table-format results from
+// [rg.StreamTable] use the column data types, and rows from [rg.Stream] have the
+// columns and types inferred from the values of the first row group. Rows are
+// written in row groups as they arrive, so the whole result is never held in memory.
+//
+// Example:
+//
+//	f, err := os.Create("resources.parquet")
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer f.Close()
+//
+//	err = rgparquet.Export(ctx, f, "resources | project id, name, type, location, tags", nil, nil)
+package rgparquet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/xitongsys/parquet-go/writer"
+	"io"
+)
+
+// ObjectEncoding selects how object (dynamic) values are written.
+type ObjectEncoding int
+
+const (
+	// ObjectAsJSON writes object values as UTF-8 strings with compact JSON.
+	ObjectAsJSON ObjectEncoding = iota
+
+	// ObjectAsGroup writes object values as nested groups, lists and maps, with the schema inferred
+	// from the values in the first row group. Objects with keys which are not identifiers, like tags,
+	// are written as maps. Values which don't fit the inferred schema are written as null.
+	ObjectAsGroup
+)
+
+// defaultRowGroupSize is the default number of rows in a row group.
+const defaultRowGroupSize = 10000
+
+// Options are the optional parameters for writing Parquet files.
+type Options struct {
+	// ObjectEncoding selects how object values are written, the default is [ObjectAsJSON].
+	ObjectEncoding ObjectEncoding
+
+	// RowGroupSize is the number of rows in each row group, the default is 10000. Rows are
+	// buffered until the row group is written.
+	RowGroupSize int
+}
+
+// Export executes the query in table format and writes the results to w as Parquet.
+// If the query returns no rows, the file has the columns and no rows. Both execOptions and options can be nil.
+func Export(ctx context.Context, w io.Writer, query string, execOptions *rg.ExecOptions, options *Options) error {
+	var pw *Writer
+	err := rg.StreamTableColumns(ctx, query, execOptions, func(columns []rg.Column) error {
+		pw = NewWriter(w, columns, options)
+		return nil
+	}, func(_ []rg.Column, row []any) error {
+		return pw.Write(row)
+	})
+
+	if pw == nil {
+		// The query failed before the first page, there is nothing to write.
+		return err
+	}
+
+	if closeErr := pw.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Writer writes table-format rows as Parquet with the schema derived from the columns.
+type Writer struct {
+	f       *file
+	columns []rg.Column
+}
+
+// NewWriter returns a [Writer] which writes rows with the given columns to w.
+// The options can be nil.
+func NewWriter(w io.Writer, columns []rg.Column, options *Options) *Writer {
+	f := newFile(w, options)
+	f.root = &node{kind: kindGroup}
+	for _, c := range columns {
+		f.root.fields = append(f.root.fields, columnNode(c, f.options.ObjectEncoding))
+	}
+
+	return &Writer{f: f, columns: columns}
+}
+
+// Write writes a single row with values as returned by [rg.StreamTable].
+func (w *Writer) Write(row []any) error {
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row has %d values, expected %d columns", len(row), len(w.columns))
+	}
+
+	values := make(map[string]any, len(row))
+	for i, c := range w.columns {
+		v := row[i]
+		if raw, ok := v.(json.RawMessage); ok {
+			var err error
+			if v, err = decodeJSON(raw); err != nil {
+				return fmt.Errorf("column '%s': %w", c.Name, err)
+			}
+		}
+		values[c.Name] = v
+	}
+
+	return w.f.write(values)
+}
+
+// Close writes the buffered rows and the file footer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.f.close()
+}
+
+// Encoder writes rows of any type which marshals to a JSON object as Parquet, with the
+// columns and their types inferred from the first row group. Its Encode method fits
+// the callback of [rg.Stream].
+type Encoder struct {
+	f *file
+}
+
+// NewEncoder returns an [Encoder] which writes rows to w. The options can be nil.
+func NewEncoder(w io.Writer, options *Options) *Encoder {
+	return &Encoder{f: newFile(w, options)}
+}
+
+// Encode writes a single row.
+func (e *Encoder) Encode(row any) error {
+	var data []byte
+	switch row := row.(type) {
+	case json.RawMessage:
+		data = row
+	default:
+		var err error
+		if data, err = json.Marshal(row); err != nil {
+			return fmt.Errorf("marshalling row of type %T: %w", row, err)
+		}
+	}
+
+	v, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+
+	values, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("row of type %T is not a JSON object", row)
+	}
+
+	return e.f.write(values)
+}
+
+// Close writes the buffered rows and the file footer. It does not close the underlying writer.
+// Without rows, the file has no columns and no rows.
+func (e *Encoder) Close() error {
+	return e.f.close()
+}
+
+// file is the Parquet file being written. Rows are buffered for a row group, and the
+// Parquet writer is created with the first row group once the schema is known.
+type file struct {
+	w       io.Writer
+	options Options
+
+	// root is the schema, with the fields to infer from the first row group as kindNull.
+	root    *node
+	pending []map[string]any
+	pw      *writer.JSONWriter
+}
+
+func newFile(w io.Writer, options *Options) *file {
+	f := &file{w: w}
+	if options != nil {
+		f.options = *options
+	}
+
+	if f.options.RowGroupSize <= 0 {
+		f.options.RowGroupSize = defaultRowGroupSize
+	}
+
+	return f
+}
+
+func (f *file) write(values map[string]any) error {
+	f.pending = append(f.pending, values)
+	if len(f.pending) < f.options.RowGroupSize {
+		return nil
+	}
+
+	return f.flush()
+}
+
+// flush writes the pending rows as a row group.
+func (f *file) flush() error {
+	if f.pw == nil {
+		if err := f.start(); err != nil {
+			return err
+		}
+	}
+
+	for _, values := range f.pending {
+		data, err := json.Marshal(conform(f.root, values))
+		if err != nil {
+			return err
+		}
+
+		if err := f.pw.Write(string(data)); err != nil {
+			return err
+		}
+	}
+
+	f.pending = f.pending[:0]
+	return f.pw.Flush(true)
+}
+
+// start infers the remaining schema from the pending rows and creates the Parquet writer.
+func (f *file) start() error {
+	nested := f.options.ObjectEncoding == ObjectAsGroup
+	if f.root == nil {
+		f.root = &node{kind: kindGroup}
+		for _, values := range f.pending {
+			row := &node{kind: kindGroup}
+			for k, v := range values {
+				row.fields = append(row.fields, infer(k, v, nested))
+			}
+			f.root = mergeGroups(f.root, row)
+		}
+
+		// Without rows the file has no columns, as there is nothing to infer them from.
+		if f.root.kind != kindGroup || len(f.root.fields) == 0 && len(f.pending) > 0 {
+			return errors.New("the rows have no columns which can be written as Parquet")
+		}
+	} else {
+		for _, field := range f.root.fields {
+			if field.kind != kindNull {
+				continue
+			}
+
+			for _, values := range f.pending {
+				*field = *merge(field, infer(field.name, values[field.name], nested))
+			}
+		}
+	}
+
+	for _, field := range f.root.fields {
+		if !identifierRegex.MatchString(field.name) {
+			return fmt.Errorf("column '%s' can't be a Parquet column name", field.name)
+		}
+	}
+
+	resolve(f.root)
+	schema, err := jsonSchema(f.root)
+	if err != nil {
+		return err
+	}
+
+	f.pw, err = writer.NewJSONWriterFromWriter(schema, f.w, 4)
+	return err
+}
+
+func (f *file) close() error {
+	if len(f.pending) > 0 || f.pw == nil {
+		if err := f.flush(); err != nil {
+			return err
+		}
+	}
+
+	return f.pw.WriteStop()
+}
+
+// decodeJSON decodes the JSON keeping numbers as [json.Number], so that integers keep their precision.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
@@ -0,0 +1,207 @@
+package rgparquet_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/rgparquet"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// read returns the rows of the Parquet file as JSON, with the column names as the reader capitalises them.
+func read(t *testing.T, data []byte) []map[string]any {
+	t.Helper()
+
+	file, err := buffer.NewBufferFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := reader.NewParquetReader(file, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.ReadStop()
+
+	if r.GetNumRows() == 0 {
+		// The reader can't read the rows of a file without columns.
+		return nil
+	}
+
+	rows, err := r.ReadByNumber(int(r.GetNumRows()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result []map[string]any
+	if err := json.Unmarshal(text, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestWriter(t *testing.T) {
+	columns := []rg.Column{
+		{Name: "name", Type: rg.ColumnDataTypeString},
+		{Name: "size", Type: rg.ColumnDataTypeInteger},
+		{Name: "enabled", Type: rg.ColumnDataTypeBoolean},
+		{Name: "created", Type: rg.ColumnDataTypeDatetime},
+		{Name: "tags", Type: rg.ColumnDataTypeObject},
+	}
+
+	var buf bytes.Buffer
+	w := rgparquet.NewWriter(&buf, columns, &rgparquet.Options{RowGroupSize: 1})
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, row := range [][]any{
+		{"a", int64(1), true, created, json.RawMessage(`{"env": "prod"}`)},
+		{"b", nil, false, nil, nil},
+	} {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Write([]any{"c"}); err == nil {
+		t.Error("Write succeeded with too few values, want an error")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := read(t, buf.Bytes())
+	if len(rows) != 2 {
+		t.Fatalf("got rows %v, want 2", rows)
+	}
+
+	if rows[0]["Name"] != "a" || rows[0]["Size"] != float64(1) || rows[0]["Enabled"] != true || rows[0]["Tags"] != `{"env":"prod"}` {
+		t.Errorf("unexpected row %v", rows[0])
+	}
+
+	if rows[0]["Created"] != float64(created.UnixMicro()) {
+		t.Errorf("got created %v, want %d microseconds", rows[0]["Created"], created.UnixMicro())
+	}
+
+	if rows[1]["Size"] != nil || rows[1]["Created"] != nil || rows[1]["Tags"] != nil {
+		t.Errorf("got row %v, want nulls", rows[1])
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := rgparquet.NewEncoder(&buf, nil)
+	for _, row := range []any{
+		json.RawMessage(`{"name": "a", "size": 1}`),
+		map[string]any{"name": "b", "size": 2.5},
+	} {
+		if err := enc.Encode(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := enc.Encode(json.RawMessage(`[1]`)); err == nil {
+		t.Error("Encode succeeded with an array, want an error")
+	}
+
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := read(t, buf.Bytes())
+	if len(rows) != 2 || rows[0]["Name"] != "a" || rows[1]["Size"] != 2.5 {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestEncoderNoRows(t *testing.T) {
+	var buf bytes.Buffer
+	enc := rgparquet.NewEncoder(&buf, nil)
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatalf("got %q, want a Parquet file", data)
+	}
+
+	if rows := read(t, data); len(rows) != 0 {
+		t.Errorf("got rows %v, want none", rows)
+	}
+}
+
+func TestExport(t *testing.T) {
+	// Azure Resource Graph sometimes omits the time zone of UTC datetimes.
+	server := &rgtest.Server{Pages: []string{`{
+		"columns": [{"name": "name", "type": "string"}, {"name": "created", "type": "datetime"}],
+		"rows": [["a", "2024-01-02T03:04:05.5"], ["b", "2024-01-02T03:04:05Z"], ["c", ""]]
+	}`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	var buf bytes.Buffer
+	if err := rgparquet.Export(context.Background(), &buf, "resources", &rg.ExecOptions{Client: client}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := read(t, buf.Bytes())
+	if len(rows) != 3 || rows[0]["Created"] != float64(created.Add(500*time.Millisecond).UnixMicro()) || rows[1]["Created"] != float64(created.UnixMicro()) || rows[2]["Created"] != nil {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestExportNoRows(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`{
+		"columns": [{"name": "name", "type": "string"}, {"name": "size", "type": "integer"}, {"name": "tags", "type": "object"}],
+		"rows": []
+	}`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	for _, encoding := range []rgparquet.ObjectEncoding{rgparquet.ObjectAsJSON, rgparquet.ObjectAsGroup} {
+		var buf bytes.Buffer
+		options := &rgparquet.Options{ObjectEncoding: encoding}
+		if err := rgparquet.Export(context.Background(), &buf, "resources", &rg.ExecOptions{Client: client}, options); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := buffer.NewBufferFile(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := reader.NewParquetReader(file, nil, 1)
+		if err != nil {
+			t.Fatalf("encoding %d: %s", encoding, err)
+		}
+
+		var names []string
+		for _, e := range r.Footer.GetSchema()[1:] {
+			names = append(names, e.GetName())
+		}
+		r.ReadStop()
+
+		if r.GetNumRows() != 0 || fmt.Sprint(names) != "[Name Size Tags]" {
+			t.Errorf("encoding %d: got %d rows and the columns %v, want the columns of the results", encoding, r.GetNumRows(), names)
+		}
+	}
+}
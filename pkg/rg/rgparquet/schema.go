@@ -0,0 +1,405 @@
+package rgparquet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kind is the kind of the schema node.
+type kind int
+
+const (
+	// kindNull is a value which has only been seen as null so far.
+	kindNull kind = iota
+	kindString
+	kindInt64
+	kindDouble
+	kindBool
+	kindTimestamp
+	// kindJSON is a value written as compact JSON text.
+	kindJSON
+	kindGroup
+	kindList
+	kindMap
+)
+
+// node is the schema of a single value.
+type node struct {
+	name   string
+	kind   kind
+	fields []*node // kindGroup
+	elem   *node   // kindList and kindMap values
+}
+
+// columnNode returns the schema node for the table column. Object columns are
+// returned as kindNull when they are to be inferred from the values.
+func columnNode(c rg.Column, encoding ObjectEncoding) *node {
+	n := &node{name: c.Name}
+	switch c.Type {
+	case rg.ColumnDataTypeString:
+		n.kind = kindString
+	case rg.ColumnDataTypeInteger:
+		n.kind = kindInt64
+	case rg.ColumnDataTypeNumber:
+		n.kind = kindDouble
+	case rg.ColumnDataTypeBoolean:
+		n.kind = kindBool
+	case rg.ColumnDataTypeDatetime:
+		n.kind = kindTimestamp
+	default:
+		if encoding == ObjectAsGroup {
+			n.kind = kindNull
+		} else {
+			n.kind = kindJSON
+		}
+	}
+	return n
+}
+
+// identifierRegex matches the object keys which can be written as group fields.
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// infer returns the schema of the value. Nested objects are inferred as groups when
+// nested is set, otherwise they are JSON text.
+func infer(name string, v any, nested bool) *node {
+	n := &node{name: name}
+	switch v := v.(type) {
+	case nil:
+		n.kind = kindNull
+	case string:
+		n.kind = kindString
+	case bool:
+		n.kind = kindBool
+	case int64:
+		n.kind = kindInt64
+	case float64:
+		n.kind = kindDouble
+	case time.Time:
+		n.kind = kindTimestamp
+	case json.Number:
+		n.kind = kindDouble
+		if _, err := v.Int64(); err == nil {
+			n.kind = kindInt64
+		}
+	case []any:
+		if !nested {
+			n.kind = kindJSON
+			break
+		}
+
+		n.kind = kindList
+		n.elem = &node{name: "element", kind: kindNull}
+		for _, e := range v {
+			n.elem = merge(n.elem, infer("element", e, nested))
+		}
+	case map[string]any:
+		if !nested {
+			n.kind = kindJSON
+			break
+		}
+
+		if !groupKeys(v) {
+			n.kind = kindMap
+			n.elem = &node{name: "value", kind: kindNull}
+			for _, e := range v {
+				n.elem = merge(n.elem, infer("value", e, nested))
+			}
+			break
+		}
+
+		n.kind = kindGroup
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			n.fields = append(n.fields, infer(k, v[k], nested))
+		}
+	default:
+		n.kind = kindJSON
+	}
+
+	return n
+}
+
+// groupKeys tells if the object keys can be group fields: they must be identifiers
+// which do not collide when Parquet writer capitalises them.
+func groupKeys(m map[string]any) bool {
+	if len(m) == 0 {
+		return false
+	}
+
+	seen := make(map[string]bool, len(m))
+	for k := range m {
+		if !identifierRegex.MatchString(k) {
+			return false
+		}
+
+		lower := strings.ToLower(k)
+		if seen[lower] {
+			return false
+		}
+		seen[lower] = true
+	}
+
+	return true
+}
+
+// merge returns the schema which can hold values of both a and b.
+func merge(a, b *node) *node {
+	switch {
+	case a.kind == kindNull:
+		return rename(b, a.name)
+	case b.kind == kindNull:
+		return a
+	case a.kind == b.kind:
+		switch a.kind {
+		case kindList, kindMap:
+			return &node{name: a.name, kind: a.kind, elem: merge(a.elem, b.elem)}
+		case kindGroup:
+			return mergeGroups(a, b)
+		default:
+			return a
+		}
+	case (a.kind == kindInt64 && b.kind == kindDouble) || (a.kind == kindDouble && b.kind == kindInt64):
+		return &node{name: a.name, kind: kindDouble}
+	case a.kind == kindGroup && b.kind == kindMap, a.kind == kindMap && b.kind == kindGroup:
+		// Keep them as maps, the group fields become map values.
+		m, g := a, b
+		if a.kind == kindGroup {
+			m, g = b, a
+		}
+		elem := m.elem
+		for _, f := range g.fields {
+			elem = merge(elem, rename(f, "value"))
+		}
+		return &node{name: a.name, kind: kindMap, elem: elem}
+	default:
+		return &node{name: a.name, kind: kindJSON}
+	}
+}
+
+func mergeGroups(a, b *node) *node {
+	result := &node{name: a.name, kind: kindGroup}
+	index := map[string]int{}
+	for _, f := range a.fields {
+		index[strings.ToLower(f.name)] = len(result.fields)
+		result.fields = append(result.fields, f)
+	}
+
+	for _, f := range b.fields {
+		i, ok := index[strings.ToLower(f.name)]
+		switch {
+		case !ok:
+			index[strings.ToLower(f.name)] = len(result.fields)
+			result.fields = append(result.fields, f)
+		case result.fields[i].name != f.name:
+			// Same field with a different case, the writer can't tell them apart.
+			return &node{name: a.name, kind: kindJSON}
+		default:
+			result.fields[i] = merge(result.fields[i], f)
+		}
+	}
+
+	sort.Slice(result.fields, func(i, j int) bool {
+		return result.fields[i].name < result.fields[j].name
+	})
+	return result
+}
+
+func rename(n *node, name string) *node {
+	result := *n
+	result.name = name
+	return &result
+}
+
+// resolve replaces the nodes which have only been seen as null with strings.
+func resolve(n *node) {
+	switch n.kind {
+	case kindNull:
+		n.kind = kindString
+	case kindGroup:
+		for _, f := range n.fields {
+			resolve(f)
+		}
+	case kindList, kindMap:
+		resolve(n.elem)
+	}
+}
+
+// schemaItem is the JSON schema item of the Parquet writer.
+type schemaItem struct {
+	Tag    string        `json:"Tag"`
+	Fields []*schemaItem `json:"Fields,omitempty"`
+}
+
+// jsonSchema returns the schema of the Parquet writer with the root group fields.
+func jsonSchema(root *node) (string, error) {
+	item := &schemaItem{Tag: "name=parquet_go_root, repetitiontype=REQUIRED"}
+	for _, f := range root.fields {
+		item.Fields = append(item.Fields, schema(f, "OPTIONAL"))
+	}
+
+	data, err := json.Marshal(item)
+	return string(data), err
+}
+
+func schema(n *node, repetition string) *schemaItem {
+	tag := func(attrs string) string {
+		if attrs != "" {
+			attrs += ", "
+		}
+		return fmt.Sprintf("name=%s, %srepetitiontype=%s", n.name, attrs, repetition)
+	}
+
+	switch n.kind {
+	case kindInt64:
+		return &schemaItem{Tag: tag("type=INT64")}
+	case kindDouble:
+		return &schemaItem{Tag: tag("type=DOUBLE")}
+	case kindBool:
+		return &schemaItem{Tag: tag("type=BOOLEAN")}
+	case kindTimestamp:
+		return &schemaItem{Tag: tag("type=INT64, convertedtype=TIMESTAMP_MICROS")}
+	case kindGroup:
+		item := &schemaItem{Tag: tag("")}
+		for _, f := range n.fields {
+			item.Fields = append(item.Fields, schema(f, "OPTIONAL"))
+		}
+		return item
+	case kindList:
+		return &schemaItem{
+			Tag:    tag("type=LIST"),
+			Fields: []*schemaItem{schema(n.elem, "OPTIONAL")},
+		}
+	case kindMap:
+		return &schemaItem{
+			Tag: tag("type=MAP"),
+			Fields: []*schemaItem{
+				{Tag: "name=key, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED"},
+				schema(n.elem, "OPTIONAL"),
+			},
+		}
+	default:
+		return &schemaItem{Tag: tag("type=BYTE_ARRAY, convertedtype=UTF8")}
+	}
+}
+
+// conform converts the value into what the Parquet writer expects for the schema node.
+// Values which don't fit the schema are written as null, or as JSON text for string columns.
+func conform(n *node, v any) any {
+	if v == nil {
+		return nil
+	}
+
+	switch n.kind {
+	case kindString:
+		switch v := v.(type) {
+		case string:
+			return v
+		case time.Time:
+			return v.Format(time.RFC3339Nano)
+		default:
+			return jsonText(v)
+		}
+	case kindJSON:
+		return jsonText(v)
+	case kindInt64:
+		switch v := v.(type) {
+		case int64:
+			return v
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+		return nil
+	case kindDouble:
+		switch v := v.(type) {
+		case float64:
+			return v
+		case int64:
+			return float64(v)
+		case json.Number:
+			if f, err := v.Float64(); err == nil {
+				return f
+			}
+		}
+		return nil
+	case kindBool:
+		if b, ok := v.(bool); ok {
+			return b
+		}
+		return nil
+	case kindTimestamp:
+		switch v := v.(type) {
+		case time.Time:
+			return v.UnixNano() / int64(time.Microsecond)
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t.UnixNano() / int64(time.Microsecond)
+			}
+		}
+		return nil
+	case kindGroup:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		result := make(map[string]any, len(n.fields))
+		for _, f := range n.fields {
+			if fv := conform(f, m[f.name]); fv != nil {
+				result[f.name] = fv
+			}
+		}
+		return result
+	case kindList:
+		l, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		result := make([]any, 0, len(l))
+		for _, e := range l {
+			result = append(result, conform(n.elem, e))
+		}
+		return result
+	case kindMap:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		result := make(map[string]any, len(m))
+		for k, e := range m {
+			result[k] = conform(n.elem, e)
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// jsonText returns the value as compact JSON text.
+func jsonText(v any) string {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
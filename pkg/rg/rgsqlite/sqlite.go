@@ -0,0 +1,261 @@
+// Package rgsqlite writes Azure Resource Graph query results into SQLite tables,
+// so that inventory snapshots can be joined with other data using SQL offline.
+//
+// The table schema comes from the result columns in table format:
+//
+//   - string: TEXT
+//   - integer: INTEGER
+//   - number: REAL
+//   - boolean: INTEGER with 0 or 1
+//   - datetime: TEXT in RFC 3339 format in UTC with nanoseconds, e.g. "2024-01-02T03:04:05.000000000Z",
+//     so that it sorts as text and works with SQLite date functions
+//   - object: TEXT with compact JSON, so that it works with SQLite JSON functions
+//
+// Example:
+//
+//	err := rgsqlite.ExportFile(ctx, "inventory.db", []rgsqlite.Query{
+//		{Table: "resources", Query: "resources | project id, name, type, subscriptionId, tags"},
+//		{Table: "subscriptions", Query: "resourcecontainers | where type =~ 'microsoft.resources/subscriptions'"},
+//	}, nil)
+package rgsqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"strings"
+	"time"
+
+	// Registers the "sqlite" database driver.
+	_ "modernc.org/sqlite"
+)
+
+// Mode selects what happens to the existing table.
+type Mode int
+
+const (
+	// ModeReplace replaces the table with the query results. The table is recreated with
+	// the result columns.
+	ModeReplace Mode = iota
+
+	// ModeAppend appends the query results to the table. Columns which the table does not have
+	// yet are added.
+	ModeAppend
+)
+
+// Query is the query whose results are written into the table.
+type Query struct {
+	// Table is the name of the SQLite table.
+	Table string
+
+	// Query is the Azure Resource Graph query text.
+	Query string
+
+	// Options are the optional parameters for the query, can be nil.
+	Options *rg.ExecOptions
+}
+
+// Options are the optional parameters for [Export] and [ExportFile].
+type Options struct {
+	// Mode selects what happens to the existing tables, the default is [ModeReplace].
+	Mode Mode
+}
+
+// ExportFile opens or creates the SQLite database file and writes the results of each query into
+// its table. The options can be nil.
+func ExportFile(ctx context.Context, path string, queries []Query, options *Options) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+
+	err = Export(ctx, db, queries, options)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Export runs the queries one by one and writes the results of each into its table. Each table
+// is written in a single transaction, so it either has all the results of its query or is left
+// as it was. The options can be nil.
+func Export(ctx context.Context, db *sql.DB, queries []Query, options *Options) error {
+	if options == nil {
+		options = &Options{}
+	}
+
+	for _, q := range queries {
+		if err := exportQuery(ctx, db, q, options.Mode); err != nil {
+			return fmt.Errorf("table '%s': %w", q.Table, err)
+		}
+	}
+
+	return nil
+}
+
+func exportQuery(ctx context.Context, db *sql.DB, q Query, mode Mode) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var insert *sql.Stmt
+	err = rg.StreamTable(ctx, q.Query, q.Options, func(columns []rg.Column, row []any) error {
+		if insert == nil {
+			var err error
+			if insert, err = prepareTable(ctx, tx, q.Table, columns, mode); err != nil {
+				return err
+			}
+		}
+
+		args := make([]any, len(row))
+		for i, v := range row {
+			args[i] = sqlValue(v)
+		}
+
+		_, err := insert.ExecContext(ctx, args...)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if insert != nil {
+		if err := insert.Close(); err != nil {
+			return err
+		}
+	} else if mode == ModeReplace {
+		// No rows and so no columns to create the table from, keep the table but empty it.
+		exists, err := tableExists(ctx, tx, q.Table)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+quote(q.Table)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// prepareTable creates or updates the table for the columns and returns the statement inserting a row.
+func prepareTable(ctx context.Context, tx *sql.Tx, table string, columns []rg.Column, mode Mode) (*sql.Stmt, error) {
+	existing := map[string]bool{}
+	if mode == ModeReplace {
+		if _, err := tx.ExecContext(ctx, "DROP TABLE IF EXISTS "+quote(table)); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if existing, err = tableColumns(ctx, tx, table); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, len(columns))
+	definitions := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = quote(c.Name)
+		definitions[i] = quote(c.Name) + " " + sqlType(c.Type)
+		placeholders[i] = "?"
+	}
+
+	if len(existing) == 0 {
+		create := fmt.Sprintf("CREATE TABLE %s (%s)", quote(table), strings.Join(definitions, ", "))
+		if _, err := tx.ExecContext(ctx, create); err != nil {
+			return nil, err
+		}
+	} else {
+		for i, c := range columns {
+			if existing[strings.ToLower(c.Name)] {
+				continue
+			}
+
+			alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quote(table), definitions[i])
+			if _, err := tx.ExecContext(ctx, alter); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quote(table), strings.Join(names, ", "), strings.Join(placeholders, ", "))
+	return tx.PrepareContext(ctx, insert)
+}
+
+// tableColumns returns the lower-case names of the table columns, or no names if there is no such table.
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		result[strings.ToLower(name)] = true
+	}
+
+	return result, rows.Err()
+}
+
+func tableExists(ctx context.Context, tx *sql.Tx, table string) (bool, error) {
+	columns, err := tableColumns(ctx, tx, table)
+	return len(columns) > 0, err
+}
+
+func sqlType(t rg.ColumnDataType) string {
+	switch t {
+	case rg.ColumnDataTypeInteger, rg.ColumnDataTypeBoolean:
+		return "INTEGER"
+	case rg.ColumnDataTypeNumber:
+		return "REAL"
+	default:
+		return "TEXT"
+	}
+}
+
+// timeLayout is RFC 3339 with the fixed width, as time.RFC3339Nano drops the trailing zeros of
+// the fraction and then the text doesn't sort by time.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// sqlValue converts the table-format value into the value stored in SQLite.
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return int64(1)
+		}
+		return int64(0)
+	case time.Time:
+		return v.UTC().Format(timeLayout)
+	case json.RawMessage:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err != nil {
+			return string(v)
+		}
+		return buf.String()
+	default:
+		return v
+	}
+}
+
+// quote returns the SQLite identifier in double quotes.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,143 @@
+package rgsqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/rgsqlite"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+const resourcesPage = `{
+	"columns": [
+		{"name": "name", "type": "string"},
+		{"name": "size", "type": "integer"},
+		{"name": "ratio", "type": "number"},
+		{"name": "enabled", "type": "boolean"},
+		{"name": "created", "type": "datetime"},
+		{"name": "tags", "type": "object"}
+	],
+	"rows": [
+		["a", 1, 0.5, true, "2024-01-02T03:04:05.5Z", {"env": "prod", "team": "x"}],
+		["b", null, null, false, "2024-01-02T03:04:05Z", null],
+		["c", 3, 1.5, true, "2024-01-02T04:04:05+02:00", []]
+	]
+}`
+
+// query returns the rows of the SQL query as text, nulls as "NULL".
+func query(t *testing.T, path string, text string) [][]string {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result [][]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			t.Fatal(err)
+		}
+
+		row := make([]string, len(columns))
+		for i, v := range values {
+			row[i] = "NULL"
+			if v.Valid {
+				row[i] = v.String
+			}
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestExportFile(t *testing.T) {
+	client := rgtest.NewClient(t, &rgtest.Server{Pages: []string{resourcesPage}}, nil)
+	path := filepath.Join(t.TempDir(), "inventory.db")
+
+	err := rgsqlite.ExportFile(context.Background(), path, []rgsqlite.Query{
+		{Table: "resources", Query: "resources", Options: &rg.ExecOptions{Client: client}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := query(t, path, "SELECT name, size, ratio, enabled, created, tags, typeof(size), typeof(enabled) FROM resources ORDER BY created")
+	// The datetimes sort by time as text.
+	want := [][]string{
+		{"c", "3", "1.5", "1", "2024-01-02T02:04:05.000000000Z", "[]", "integer", "integer"},
+		{"b", "NULL", "NULL", "0", "2024-01-02T03:04:05.000000000Z", "NULL", "null", "integer"},
+		{"a", "1", "0.5", "1", "2024-01-02T03:04:05.500000000Z", `{"env":"prod","team":"x"}`, "integer", "integer"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The SQLite functions understand the JSON and the datetimes.
+	if got := query(t, path, "SELECT json_extract(tags, '$.env'), datetime(created) FROM resources WHERE name = 'a'"); !reflect.DeepEqual(got, [][]string{{"prod", "2024-01-02 03:04:05"}}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestExportModes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.db")
+	export := func(page string, mode rgsqlite.Mode) {
+		t.Helper()
+
+		client := rgtest.NewClient(t, &rgtest.Server{Pages: []string{page}}, nil)
+		err := rgsqlite.ExportFile(context.Background(), path, []rgsqlite.Query{
+			{Table: "resources", Query: "resources", Options: &rg.ExecOptions{Client: client}},
+		}, &rgsqlite.Options{Mode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export(`{"columns": [{"name": "name", "type": "string"}], "rows": [["a"]]}`, rgsqlite.ModeReplace)
+	export(`{"columns": [{"name": "name", "type": "string"}, {"name": "size", "type": "integer"}], "rows": [["b", 2]]}`, rgsqlite.ModeAppend)
+
+	got := query(t, path, "SELECT name, size FROM resources ORDER BY name")
+	if want := [][]string{{"a", "NULL"}, {"b", "2"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Without rows the table is kept but emptied.
+	export(`{"columns": [], "rows": []}`, rgsqlite.ModeReplace)
+	if got := query(t, path, "SELECT count(*) FROM resources"); !reflect.DeepEqual(got, [][]string{{"0"}}) {
+		t.Errorf("got %v, want an empty table", got)
+	}
+}
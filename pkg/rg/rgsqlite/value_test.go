@@ -0,0 +1,48 @@
+package rgsqlite
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSQLValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  any
+	}{
+		{true, int64(1)},
+		{false, int64(0)},
+		{json.RawMessage(`{"env": "prod", "tier": 1}`), `{"env":"prod","tier":1}`},
+		{json.RawMessage(`{`), `{`},
+		{int64(42), int64(42)},
+		{nil, nil},
+	}
+
+	for _, tt := range tests {
+		if got := sqlValue(tt.value); got != tt.want {
+			t.Errorf("sqlValue(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSQLValueTime(t *testing.T) {
+	// The times are in UTC with all the digits of the fraction, so that they sort as text.
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	times := []time.Time{base.Add(time.Second), base, base.Add(500 * time.Millisecond)}
+
+	var texts []string
+	for _, v := range times {
+		texts = append(texts, sqlValue(v).(string))
+	}
+	sort.Strings(texts)
+
+	want := []string{"2024-01-02T02:04:05.000000000Z", "2024-01-02T02:04:05.500000000Z", "2024-01-02T02:04:06.000000000Z"}
+	for i := range want {
+		if texts[i] != want[i] {
+			t.Errorf("got %q, want %q", texts, want)
+			break
+		}
+	}
+}
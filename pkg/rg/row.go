@@ -0,0 +1,197 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Row is a row of table-format query results which keeps the columns in the order the query
+// projected them. This is useful for generic tooling like CLIs and CSV writers, as the order of
+// the keys in map[string]any is lost. See [Table] for the types of the values.
+//
+// Example:
+//
+//	err := rg.StreamRows(ctx, "resources | project name, type, location", nil, func(row rg.Row) error {
+//		for i := 0; i < row.Len(); i++ {
+//			fmt.Printf("%s=%v ", row.Column(i).Name, row.Index(i))
+//		}
+//		fmt.Println()
+//		return nil
+//	})
+type Row struct {
+	columns *rowColumns
+	values  []any
+}
+
+// rowColumns are the columns shared by all rows of the results.
+type rowColumns struct {
+	columns []Column
+	index   map[string]int
+}
+
+func newRowColumns(columns []Column) *rowColumns {
+	result := &rowColumns{
+		columns: columns,
+		index:   make(map[string]int, len(columns)),
+	}
+
+	for i, c := range columns {
+		if _, ok := result.index[c.Name]; !ok {
+			result.index[c.Name] = i
+		}
+	}
+
+	return result
+}
+
+// NewRow creates the row with the given columns and values, which must have the same length.
+func NewRow(columns []Column, values []any) Row {
+	if len(columns) != len(values) {
+		panic("rg.NewRow: the number of columns and values differ")
+	}
+
+	return Row{columns: newRowColumns(columns), values: values}
+}
+
+// Len returns the number of columns in the row.
+func (r Row) Len() int {
+	return len(r.values)
+}
+
+// Columns returns the columns of the row in the projection order. The slice must not be modified.
+func (r Row) Columns() []Column {
+	if r.columns == nil {
+		return nil
+	}
+
+	return r.columns.columns
+}
+
+// Values returns the values of the row in the projection order. The slice must not be modified.
+func (r Row) Values() []any {
+	return r.values
+}
+
+// Column returns the i-th column. It panics if i is out of range.
+func (r Row) Column(i int) Column {
+	return r.columns.columns[i]
+}
+
+// Index returns the value of the i-th column. It panics if i is out of range.
+func (r Row) Index(i int) any {
+	return r.values[i]
+}
+
+// Get returns the value of the column with the given name, and false if there is no such column.
+// The names are matched case-sensitively like in KQL.
+func (r Row) Get(name string) (any, bool) {
+	if r.columns == nil {
+		return nil, false
+	}
+
+	i, ok := r.columns.index[name]
+	if !ok {
+		return nil, false
+	}
+
+	return r.values[i], true
+}
+
+// RowValue returns the value of the column with the given name as T, which must be the Go type of
+// the column data type, see [Table]. Null values are returned as the zero T. It fails when there is
+// no such column or the value has another type.
+//
+// Example:
+//
+//	created, err := rg.RowValue[time.Time](row, "createdTime")
+func RowValue[T any](r Row, name string) (T, error) {
+	var zero T
+	value, ok := r.Get(name)
+	if !ok {
+		return zero, fmt.Errorf("the row has no column '%s'", name)
+	}
+
+	if value == nil {
+		return zero, nil
+	}
+
+	result, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("the value of column '%s' is %T, not %T", name, value, zero)
+	}
+
+	return result, nil
+}
+
+// Range calls fn for each column and value in the projection order until fn returns false.
+func (r Row) Range(fn func(column Column, value any) bool) {
+	for i, v := range r.values {
+		if !fn(r.columns.columns[i], v) {
+			return
+		}
+	}
+}
+
+// Map returns the row as a map from the column names to the values.
+func (r Row) Map() map[string]any {
+	result := make(map[string]any, len(r.values))
+	r.Range(func(column Column, value any) bool {
+		result[column.Name] = value
+		return true
+	})
+
+	return result
+}
+
+// MarshalJSON implements [json.Marshaler], the row is marshalled as a JSON object with
+// the keys in the projection order.
+func (r Row) MarshalJSON() ([]byte, error) {
+	stream := jsoniter.ConfigCompatibleWithStandardLibrary.BorrowStream(nil)
+	defer jsoniter.ConfigCompatibleWithStandardLibrary.ReturnStream(stream)
+
+	stream.WriteObjectStart()
+	for i, v := range r.values {
+		if i > 0 {
+			stream.WriteMore()
+		}
+		stream.WriteObjectField(r.columns.columns[i].Name)
+		stream.WriteVal(v)
+	}
+	stream.WriteObjectEnd()
+
+	if stream.Error != nil {
+		return nil, stream.Error
+	}
+
+	return append([]byte(nil), stream.Buffer()...), nil
+}
+
+// ExecRows executes Azure Resource Graph query and returns the rows with the columns in the
+// order they are projected by the query.
+func ExecRows(ctx context.Context, query string, options *ExecOptions) ([]Row, error) {
+	var result []Row
+	err := StreamRows(ctx, query, options, func(row Row) error {
+		result = append(result, row)
+		return nil
+	})
+
+	return result, err
+}
+
+// StreamRows executes Azure Resource Graph query and calls fn for each row as pages arrive,
+// with the columns in the order they are projected by the query.
+// If fn returns an error, the iteration stops and the error is returned.
+func StreamRows(ctx context.Context, query string, options *ExecOptions, fn func(row Row) error) error {
+	var columns *rowColumns
+	return StreamTable(ctx, query, options, func(c []Column, values []any) error {
+		if columns == nil {
+			columns = newRowColumns(c)
+		}
+
+		return fn(Row{columns: columns, values: values})
+	})
+}
@@ -0,0 +1,136 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var rowColumns = []rg.Column{
+	{Name: "name", Type: rg.ColumnDataTypeString},
+	{Name: "size", Type: rg.ColumnDataTypeInteger},
+	{Name: "created", Type: rg.ColumnDataTypeDatetime},
+	{Name: "tags", Type: rg.ColumnDataTypeObject},
+}
+
+func TestRow(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := rg.NewRow(rowColumns, []any{"vm1", int64(42), created, nil})
+
+	if row.Len() != 4 || row.Column(1).Name != "size" || row.Index(0) != "vm1" || !reflect.DeepEqual(row.Columns(), rowColumns) {
+		t.Errorf("unexpected row %v", row.Values())
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		ok    bool
+	}{
+		{"name", "vm1", true},
+		{"size", int64(42), true},
+		{"tags", nil, true},
+		{"Name", nil, false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		value, ok := row.Get(tt.name)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("Get(%q) = %v, %v, want %v, %v", tt.name, value, ok, tt.value, tt.ok)
+		}
+	}
+
+	var names []string
+	row.Range(func(column rg.Column, value any) bool {
+		names = append(names, column.Name)
+		return column.Name != "size"
+	})
+	if !reflect.DeepEqual(names, []string{"name", "size"}) {
+		t.Errorf("Range visited %v, want it to stop after size", names)
+	}
+
+	var empty rg.Row
+	if _, ok := empty.Get("name"); ok || empty.Len() != 0 || empty.Columns() != nil {
+		t.Error("the zero row has columns")
+	}
+}
+
+func TestRowValue(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	row := rg.NewRow(rowColumns, []any{"vm1", int64(42), created, nil})
+
+	if name, err := rg.RowValue[string](row, "name"); err != nil || name != "vm1" {
+		t.Errorf("got %q, %v", name, err)
+	}
+
+	if got, err := rg.RowValue[time.Time](row, "created"); err != nil || !got.Equal(created) {
+		t.Errorf("got %v, %v", got, err)
+	}
+
+	// Nulls are zero values.
+	if tags, err := rg.RowValue[json.RawMessage](row, "tags"); err != nil || tags != nil {
+		t.Errorf("got %s, %v, want nil", tags, err)
+	}
+
+	if _, err := rg.RowValue[int](row, "size"); err == nil || err.Error() != "the value of column 'size' is int64, not int" {
+		t.Errorf("got error %v, want the wrong type error", err)
+	}
+
+	if _, err := rg.RowValue[string](row, "missing"); err == nil || err.Error() != "the row has no column 'missing'" {
+		t.Errorf("got error %v, want the missing column error", err)
+	}
+}
+
+func TestRowObject(t *testing.T) {
+	row := rg.NewRow(rowColumns, []any{"vm1", int64(42), nil, json.RawMessage(`{"env":"prod"}`)})
+
+	want := map[string]any{"name": "vm1", "size": int64(42), "created": nil, "tags": json.RawMessage(`{"env":"prod"}`)}
+	if got := row.Map(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The keys keep the column order, unlike the keys of maps which are sorted.
+	data, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"name":"vm1","size":42,"created":null,"tags":{"env":"prod"}}` {
+		t.Errorf("got %s", data)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewRow succeeded with more values than columns, want a panic")
+		}
+	}()
+	rg.NewRow(rowColumns, []any{"vm1", int64(42), nil, nil, nil})
+}
+
+func TestExecRows(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`{"columns": [{"name": "type", "type": "string"}, {"name": "count_", "type": "integer"}], "rows": [["a", 2]]}`,
+		`{"columns": [{"name": "type", "type": "string"}, {"name": "count_", "type": "integer"}], "rows": [["b", null]]}`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	rows, err := rg.ExecRows(context.Background(), "resources | summarize count() by type", &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 || rows[1].Column(0).Name != "type" || rows[1].Index(0) != "b" || rows[1].Index(1) != nil {
+		t.Errorf("unexpected rows %v", rows)
+	}
+
+	if count, err := rg.RowValue[int64](rows[0], "count_"); err != nil || count != 2 {
+		t.Errorf("got %d, %v", count, err)
+	}
+}
@@ -0,0 +1,267 @@
+//go:build go1.18
+// +build go1.18
+
+// Package snapshot saves the results of Azure Resource Graph queries keyed by an ID column and
+// compares snapshots from two runs to find the rows which were added, removed or modified,
+// down to the JSON paths of the changed properties.
+//
+// Example:
+//
+//	old, err := snapshot.Load("inventory-yesterday.json")
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	now, err := snapshot.Take(ctx, "resources | project id, name, type, sku, tags", "id", nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	diff, err := snapshot.Compare(old, now)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	_ = diff.WriteText(os.Stdout)
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/jsondiff"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultKey is the key column used when none is given.
+const DefaultKey = "id"
+
+// Snapshot is the result of a query at a point in time, with the rows keyed by the value of the key column.
+type Snapshot struct {
+	// Query is the query text.
+	Query string `json:"query"`
+
+	// Key is the name of the key column.
+	Key string `json:"key"`
+
+	// TakenAt is the time the snapshot was taken.
+	TakenAt time.Time `json:"takenAt"`
+
+	// Rows are the rows of the results by key. The keys are the values of the key column in
+	// lower case, as Azure resource IDs compare case-insensitively.
+	Rows map[string]json.RawMessage `json:"rows"`
+}
+
+// Take executes the query and returns the snapshot of its results keyed by the key column,
+// which defaults to [DefaultKey]. Each row must have a non-empty key. When several rows have
+// the same key, the last one is kept and a warning is logged.
+func Take(ctx context.Context, query string, key string, options *rg.ExecOptions) (*Snapshot, error) {
+	if key == "" {
+		key = DefaultKey
+	}
+
+	result := &Snapshot{
+		Query:   query,
+		Key:     key,
+		TakenAt: time.Now().UTC(),
+		Rows:    map[string]json.RawMessage{},
+	}
+
+	err := rg.Stream(ctx, query, options, func(row json.RawMessage) error {
+		k, err := jsondiff.Key(row, key)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := result.Rows[k]; ok {
+			log.Printf("snapshot: duplicate key '%s', keeping the last row", k)
+		}
+
+		result.Rows[k] = row
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Load reads the snapshot from the file.
+func Load(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot '%s': %w", path, err)
+	}
+
+	return result, nil
+}
+
+// Read reads the snapshot in JSON format.
+func Read(r io.Reader) (*Snapshot, error) {
+	var result Snapshot
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	if result.Rows == nil {
+		result.Rows = map[string]json.RawMessage{}
+	}
+
+	return &result, nil
+}
+
+// Save writes the snapshot to the file, replacing it if it exists.
+func (s *Snapshot) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Write(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing snapshot '%s': %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// Write writes the snapshot in JSON format. The rows are ordered by key and indented,
+// so that snapshots can also be compared with text diff tools.
+func (s *Snapshot) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Kind is the kind of change.
+type Kind = jsondiff.Kind
+
+const (
+	Added    = jsondiff.Added
+	Removed  = jsondiff.Removed
+	Modified = jsondiff.Modified
+)
+
+// Change is a change of a single property of a row, see [Kind].
+type Change = jsondiff.Change
+
+// Row is a row which was added or removed.
+type Row struct {
+	// Key is the key of the row.
+	Key string `json:"key"`
+
+	// Row is the row JSON.
+	Row json.RawMessage `json:"row"`
+}
+
+// Modification is a row which was modified.
+type Modification struct {
+	// Key is the key of the row.
+	Key string `json:"key"`
+
+	// Changes are the changes of the row properties, ordered by path.
+	Changes []Change `json:"changes"`
+}
+
+// Diff is the difference between two snapshots. The rows are ordered by key.
+type Diff struct {
+	// Added are the rows which are only in the new snapshot.
+	Added []Row `json:"added"`
+
+	// Removed are the rows which are only in the old snapshot.
+	Removed []Row `json:"removed"`
+
+	// Modified are the rows which are in both snapshots and differ.
+	Modified []Modification `json:"modified"`
+}
+
+// Compare returns the difference from the old to the new snapshot. The snapshots should be
+// taken with the same query and key, otherwise most rows show up as added and removed.
+func Compare(old, current *Snapshot) (*Diff, error) {
+	if old.Key != current.Key {
+		return nil, fmt.Errorf("the snapshots have different keys '%s' and '%s'", old.Key, current.Key)
+	}
+
+	rows, err := jsondiff.CompareRows(old.Rows, current.Rows)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Diff{
+		Added:    make([]Row, 0, len(rows.Added)),
+		Removed:  make([]Row, 0, len(rows.Removed)),
+		Modified: make([]Modification, 0, len(rows.Modified)),
+	}
+
+	for _, key := range rows.Added {
+		result.Added = append(result.Added, Row{Key: key, Row: current.Rows[key]})
+	}
+
+	for _, key := range rows.Removed {
+		result.Removed = append(result.Removed, Row{Key: key, Row: old.Rows[key]})
+	}
+
+	for _, key := range rows.Modified {
+		result.Modified = append(result.Modified, Modification{Key: key, Changes: rows.Changes[key]})
+	}
+
+	return result, nil
+}
+
+// IsEmpty tells if the snapshots have the same rows.
+func (d *Diff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// WriteText writes the diff in human-readable form, one line per row with "+" for added,
+// "-" for removed and "~" for modified rows, followed by the changed properties:
+//
+//	$ rg diff old.json new.json
+//	+ /subscriptions/.../virtualmachines/vm2
+//	- /subscriptions/.../virtualmachines/vm1
+//	~ /subscriptions/.../storageaccounts/sa1
+//	    $.sku.name: "Standard_LRS" -> "Standard_GRS"
+//	    $.tags.owner: added "ops"
+func (d *Diff) WriteText(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, row := range d.Added {
+		fmt.Fprintf(&buf, "+ %s\n", row.Key)
+	}
+
+	for _, row := range d.Removed {
+		fmt.Fprintf(&buf, "- %s\n", row.Key)
+	}
+
+	for _, row := range d.Modified {
+		fmt.Fprintf(&buf, "~ %s\n", row.Key)
+		for _, c := range row.Changes {
+			switch c.Kind {
+			case Added:
+				fmt.Fprintf(&buf, "    %s: added %s\n", c.Path, c.New)
+			case Removed:
+				fmt.Fprintf(&buf, "    %s: removed %s\n", c.Path, c.Old)
+			default:
+				fmt.Fprintf(&buf, "    %s: %s -> %s\n", c.Path, c.Old, c.New)
+			}
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
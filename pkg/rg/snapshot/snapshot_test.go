@@ -0,0 +1,122 @@
+//go:build go1.18
+// +build go1.18
+
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/snapshot"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestTake(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"id": "/VM1", "sku": "a"}, {"id": "/vm2", "sku": "b"}]`,
+		`[{"id": "/vm1", "sku": "c"}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	s, err := snapshot.Take(context.Background(), "resources", "", &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The keys are in lower case, and the last row of a duplicate key is kept.
+	want := map[string]json.RawMessage{
+		"/vm1": json.RawMessage(`{"id": "/vm1", "sku": "c"}`),
+		"/vm2": json.RawMessage(`{"id": "/vm2", "sku": "b"}`),
+	}
+	if s.Key != snapshot.DefaultKey || s.Query != "resources" || !reflect.DeepEqual(s.Rows, want) {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+
+	if _, err := snapshot.Take(context.Background(), "resources", "name", &rg.ExecOptions{Client: client}); err == nil {
+		t.Error("Take succeeded with rows without the key, want an error")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	s := &snapshot.Snapshot{
+		Query: "resources",
+		Key:   "id",
+		Rows:  map[string]json.RawMessage{"/vm1": json.RawMessage(`{"id":"/vm1"}`)},
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := snapshot.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows are indented in the file.
+	var row bytes.Buffer
+	if err := json.Compact(&row, loaded.Rows["/vm1"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Query != s.Query || loaded.Key != s.Key || row.String() != `{"id":"/vm1"}` {
+		t.Errorf("got %+v, want %+v", loaded, s)
+	}
+
+	if _, err := snapshot.Read(bytes.NewReader([]byte("{"))); err == nil {
+		t.Error("Read succeeded with invalid JSON, want an error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old := &snapshot.Snapshot{Key: "id", Rows: map[string]json.RawMessage{
+		"/vm1": json.RawMessage(`{"id": "/vm1"}`),
+		"/sa1": json.RawMessage(`{"id": "/sa1", "sku": {"name": "Standard_LRS"}, "tags": {"env": "prod"}, "zones": ["1"]}`),
+		"/sa2": json.RawMessage(`{"id": "/sa2", "tags": {}}`),
+	}}
+	current := &snapshot.Snapshot{Key: "id", Rows: map[string]json.RawMessage{
+		"/vm2": json.RawMessage(`{"id": "/vm2"}`),
+		"/sa1": json.RawMessage(`{"id": "/sa1", "sku": {"name": "Standard_GRS"}, "tags": {"env": "prod", "cost center": "42"}, "zones": ["1"]}`),
+		"/sa2": json.RawMessage(`{"tags": {}, "id": "/sa2"}`),
+	}}
+
+	diff, err := snapshot.Compare(old, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Added) != 1 || diff.Added[0].Key != "/vm2" || len(diff.Removed) != 1 || diff.Removed[0].Key != "/vm1" || len(diff.Modified) != 1 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+
+	var text bytes.Buffer
+	if err := diff.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "+ /vm2\n- /vm1\n~ /sa1\n    $.sku.name: \"Standard_LRS\" -> \"Standard_GRS\"\n    $.tags['cost center']: added \"42\"\n"
+	if text.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", text.String(), want)
+	}
+
+	if same, err := snapshot.Compare(old, old); err != nil || !same.IsEmpty() {
+		t.Errorf("got %+v, %v, want no difference", same, err)
+	}
+
+	if _, err := snapshot.Compare(old, &snapshot.Snapshot{Key: "name"}); err == nil {
+		t.Error("Compare succeeded with different keys, want an error")
+	}
+}
@@ -0,0 +1,217 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The states of the subscriptions, see [Subscription.State].
+const (
+	SubscriptionStateEnabled  = "Enabled"
+	SubscriptionStateDisabled = "Disabled"
+	SubscriptionStateWarned   = "Warned"
+	SubscriptionStatePastDue  = "PastDue"
+	SubscriptionStateDeleted  = "Deleted"
+)
+
+// ErrNoSubscriptions is returned by [SubscriptionFilter.Scope] when no subscription matches the filter,
+// as options without subscriptions would run the query against all of them.
+var ErrNoSubscriptions = errors.New("no subscriptions match the filter")
+
+// Subscription is a subscription as recorded in the resourcecontainers table.
+type Subscription struct {
+	ID       string            `json:"subscriptionId"`
+	Name     string            `json:"name"`
+	State    string            `json:"state"`
+	TenantID string            `json:"tenantId"`
+	Tags     map[string]string `json:"tags"`
+
+	// ManagementGroups are the management groups of the subscription from the parent up to the root.
+	ManagementGroups []ManagementGroupRef `json:"managementGroupAncestorsChain"`
+}
+
+// ManagementGroupRef is a management group in the chain of a [Subscription].
+type ManagementGroupRef struct {
+	// Name is the name of the management group, which is its ID.
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// ManagementGroupPath returns the display names of the management groups of the subscription from the root down.
+func (s *Subscription) ManagementGroupPath() []string {
+	result := make([]string, 0, len(s.ManagementGroups))
+	for i := len(s.ManagementGroups) - 1; i >= 0; i-- {
+		mg := s.ManagementGroups[i]
+		if mg.DisplayName != "" {
+			result = append(result, mg.DisplayName)
+		} else {
+			result = append(result, mg.Name)
+		}
+	}
+	return result
+}
+
+// SubscriptionList is a list of subscriptions returned by [Subscriptions].
+type SubscriptionList []Subscription
+
+// IDs returns the IDs of the subscriptions, e.g. for [ExecOptions.Subscriptions].
+func (l SubscriptionList) IDs() []string {
+	result := make([]string, 0, len(l))
+	for _, s := range l {
+		result = append(result, s.ID)
+	}
+	return result
+}
+
+// SubscriptionFilter selects the subscriptions returned by [Subscriptions]. All the conditions must match.
+type SubscriptionFilter struct {
+	// States are the states of the subscriptions like [SubscriptionStateEnabled], compared
+	// case-insensitively. Empty means any state.
+	States []string
+
+	// Tags are the tags the subscriptions must have. The names are compared case-insensitively
+	// and the values exactly, an empty value matches any value.
+	Tags map[string]string
+
+	// ManagementGroup is the name of the management group the subscriptions must be under,
+	// directly or below its child management groups. Empty means any management group.
+	ManagementGroup string
+}
+
+// matches tells if the subscription matches the filter, a nil filter matches all subscriptions.
+func (f *SubscriptionFilter) matches(s *Subscription) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.States) > 0 && !containsFold(f.States, s.State) {
+		return false
+	}
+
+	for name, value := range f.Tags {
+		v, ok := lookupFold(s.Tags, name)
+		if !ok || value != "" && v != value {
+			return false
+		}
+	}
+
+	if f.ManagementGroup != "" {
+		found := false
+		for _, mg := range s.ManagementGroups {
+			found = found || strings.EqualFold(mg.Name, f.ManagementGroup)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Scope returns a copy of the options which runs the query against the subscriptions matching the
+// filter. The options can be nil, their client is also used to find the subscriptions. The error is
+// [ErrNoSubscriptions] when no subscription matches.
+//
+// Example:
+//
+//	filter := &rg.SubscriptionFilter{
+//		States: []string{rg.SubscriptionStateEnabled},
+//		Tags:   map[string]string{"env": "prod"},
+//	}
+//
+//	options, err := filter.Scope(ctx, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	items, err := rg.Exec[record](ctx, query, options)
+func (f *SubscriptionFilter) Scope(ctx context.Context, options *ExecOptions) (*ExecOptions, error) {
+	subscriptions, err := Subscriptions(ctx, f, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subscriptions) == 0 {
+		return nil, ErrNoSubscriptions
+	}
+
+	var result ExecOptions
+	if options != nil {
+		result = *options
+	}
+
+	result.Subscriptions = subscriptions.IDs()
+	result.ManagementGroups = nil
+	return &result, nil
+}
+
+// subscriptionsQuery returns the subscriptions as [Subscription].
+const subscriptionsQuery = `resourcecontainers
+| where type =~ 'microsoft.resources/subscriptions'
+| project subscriptionId, name, tenantId, tags,
+    state = tostring(properties.state),
+    managementGroupAncestorsChain = properties.managementGroupAncestorsChain
+| order by subscriptionId asc`
+
+// Subscriptions queries resourcecontainers for the subscriptions visible to the caller which match
+// the filter, sorted by ID. The filter can be nil for all subscriptions. Only [ExecOptions.Client]
+// of the options is used.
+//
+// Example:
+//
+//	subscriptions, err := rg.Subscriptions(ctx, &rg.SubscriptionFilter{States: []string{rg.SubscriptionStateEnabled}}, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	for _, s := range subscriptions {
+//		fmt.Printf("%s %s %s\n", s.ID, s.Name, strings.Join(s.ManagementGroupPath(), "/"))
+//	}
+func Subscriptions(ctx context.Context, filter *SubscriptionFilter, options *ExecOptions) (SubscriptionList, error) {
+	execOptions := &ExecOptions{}
+	if options != nil {
+		execOptions.Client = options.Client
+	}
+
+	var result SubscriptionList
+	err := Stream(ctx, subscriptionsQuery, execOptions, func(s Subscription) error {
+		if filter.matches(&s) {
+			result = append(result, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying subscriptions: %w", err)
+	}
+
+	return result, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupFold returns the value of the map by the key compared case-insensitively.
+func lookupFold(m map[string]string, key string) (string, bool) {
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
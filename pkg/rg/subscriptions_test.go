@@ -0,0 +1,108 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const subscriptionsPage = `[
+	{"subscriptionId": "sub1", "name": "Prod", "state": "Enabled", "tenantId": "tenant1", "tags": {"Env": "prod", "team": "a"},
+		"managementGroupAncestorsChain": [{"name": "a-unit", "displayName": "Alpha"}, {"name": "tenant", "displayName": "Tenant Root Group"}]},
+	{"subscriptionId": "sub2", "name": "Dev", "state": "Enabled", "tenantId": "tenant1", "tags": {"env": "dev"},
+		"managementGroupAncestorsChain": [{"name": "b-unit"}, {"name": "tenant", "displayName": "Tenant Root Group"}]},
+	{"subscriptionId": "sub3", "name": "Old", "state": "Disabled", "tenantId": "tenant1", "tags": null, "managementGroupAncestorsChain": null}
+]`
+
+func TestSubscriptions(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{subscriptionsPage}}
+	client := rgtest.NewClient(t, server, nil)
+
+	tests := []struct {
+		filter *rg.SubscriptionFilter
+		want   []string
+	}{
+		{nil, []string{"sub1", "sub2", "sub3"}},
+		{&rg.SubscriptionFilter{States: []string{"enabled"}}, []string{"sub1", "sub2"}},
+		{&rg.SubscriptionFilter{States: []string{rg.SubscriptionStateDisabled, rg.SubscriptionStateWarned}}, []string{"sub3"}},
+		{&rg.SubscriptionFilter{Tags: map[string]string{"env": ""}}, []string{"sub1", "sub2"}},
+		{&rg.SubscriptionFilter{Tags: map[string]string{"env": "prod"}}, []string{"sub1"}},
+		{&rg.SubscriptionFilter{Tags: map[string]string{"env": "PROD"}}, nil},
+		{&rg.SubscriptionFilter{Tags: map[string]string{"env": "prod", "team": "b"}}, nil},
+		{&rg.SubscriptionFilter{ManagementGroup: "TENANT"}, []string{"sub1", "sub2"}},
+		{&rg.SubscriptionFilter{ManagementGroup: "b-unit", States: []string{"Enabled"}}, []string{"sub2"}},
+	}
+
+	for _, tt := range tests {
+		subscriptions, err := rg.Subscriptions(context.Background(), tt.filter, &rg.ExecOptions{Client: client})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// IDs is never nil.
+		if got := subscriptions.IDs(); !reflect.DeepEqual(got, append([]string{}, tt.want...)) {
+			t.Errorf("Subscriptions(%+v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestSubscriptionManagementGroupPath(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{subscriptionsPage}}
+	client := rgtest.NewClient(t, server, nil)
+
+	subscriptions, err := rg.Subscriptions(context.Background(), nil, &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The path goes from the root down, with the names of the groups without display names.
+	want := [][]string{{"Tenant Root Group", "Alpha"}, {"Tenant Root Group", "b-unit"}, {}}
+	for i, s := range subscriptions {
+		if got := s.ManagementGroupPath(); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("subscription %s has path %v, want %v", s.ID, got, want[i])
+		}
+	}
+}
+
+func TestSubscriptionFilterScope(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{subscriptionsPage}}
+	client := rgtest.NewClient(t, server, nil)
+
+	options := &rg.ExecOptions{Client: client, ManagementGroups: []string{"tenant"}, First: 5}
+	scoped, err := (&rg.SubscriptionFilter{States: []string{"Enabled"}}).Scope(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(scoped.Subscriptions, []string{"sub1", "sub2"}) || scoped.ManagementGroups != nil || scoped.First != 5 || options.ManagementGroups == nil {
+		t.Errorf("got scope %+v", scoped)
+	}
+
+	// Only the client of the options is used to list the subscriptions.
+	if request := server.Requests()[0]; request.ManagementGroups != nil || request.Options.Top != nil {
+		t.Errorf("the subscriptions are listed with %+v", request)
+	}
+
+	_, err = (&rg.SubscriptionFilter{States: []string{"Warned"}}).Scope(context.Background(), options)
+	if !errors.Is(err, rg.ErrNoSubscriptions) {
+		t.Errorf("got error %v, want %v", err, rg.ErrNoSubscriptions)
+	}
+}
+
+func TestSubscriptionsError(t *testing.T) {
+	server := &rgtest.Server{Status: http.StatusForbidden, Body: `{"error": {"code": "AuthorizationFailed", "message": "denied"}}`}
+	client := rgtest.NewClient(t, server, nil)
+
+	_, err := rg.Subscriptions(context.Background(), nil, &rg.ExecOptions{Client: client})
+	if err == nil || !strings.HasPrefix(err.Error(), "querying subscriptions: ") {
+		t.Errorf("got error %v, want the query error", err)
+	}
+}
@@ -0,0 +1,190 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/armresourcegraph2"
+	"strconv"
+)
+
+// ColumnDataType is the data type of a column in table-format query results.
+type ColumnDataType = armresourcegraph2.ColumnDataType
+
+const (
+	ColumnDataTypeString   = armresourcegraph2.ColumnDataTypeString
+	ColumnDataTypeInteger  = armresourcegraph2.ColumnDataTypeInteger
+	ColumnDataTypeNumber   = armresourcegraph2.ColumnDataTypeNumber
+	ColumnDataTypeBoolean  = armresourcegraph2.ColumnDataTypeBoolean
+	ColumnDataTypeObject   = armresourcegraph2.ColumnDataTypeObject
+	ColumnDataTypeDatetime = armresourcegraph2.ColumnDataTypeDatetime
+)
+
+// Column describes a column of table-format query results.
+type Column struct {
+	// Name is the column name as projected by the query.
+	Name string
+
+	// Type is the column data type.
+	Type ColumnDataType
+}
+
+// Table is the query result in table format.
+//
+// The values in the rows have Go types according to the column data type:
+//
+//   - string: string
+//   - integer: int64
+//   - number: float64
+//   - boolean: bool
+//   - datetime: [time.Time]
+//   - object: [json.RawMessage], this is used for dynamic values
+//
+// Null values are nil regardless of the column type.
+type Table struct {
+	Columns []Column
+	Rows    [][]any
+}
+
+// ExecTable executes Azure Resource Graph query and returns the result in table format,
+// with the columns in the order they are projected by the query.
+func ExecTable(ctx context.Context, query string, options *ExecOptions) (*Table, error) {
+	result := &Table{}
+	err := StreamTable(ctx, query, options, func(columns []Column, row []any) error {
+		result.Columns = columns
+		result.Rows = append(result.Rows, row)
+		return nil
+	})
+
+	return result, err
+}
+
+// StreamTable executes Azure Resource Graph query requesting the result in table format and
+// calls fn for each row as pages arrive. See [Table] for the types of row values.
+// If fn returns an error, the iteration stops and the error is returned.
+func StreamTable(ctx context.Context, query string, options *ExecOptions, fn func(columns []Column, row []any) error) error {
+	return StreamTableColumns(ctx, query, options, nil, fn)
+}
+
+// StreamTableColumns is [StreamTable] which also calls columnsFn with the columns once before the rows,
+// even when the query returns no rows, e.g. to create a table or a file schema for the results.
+// If columnsFn returns an error, the iteration stops and the error is returned. It can be nil.
+func StreamTableColumns(ctx context.Context, query string, options *ExecOptions, columnsFn func(columns []Column) error, fn func(columns []Column, row []any) error) error {
+	queryRequest, err := newQueryRequest(query, options)
+	if err != nil {
+		return err
+	}
+
+	if queryRequest.Options == nil {
+		queryRequest.Options = &armresourcegraph2.QueryRequestOptions{}
+	}
+	resultFormat := armresourcegraph2.ResultFormatTable
+	queryRequest.Options.ResultFormat = &resultFormat
+
+	pager, err := newPager(ctx, queryRequest, options)
+	if err != nil {
+		return err
+	}
+
+	var columns []Column
+	return pager.eachRow(options.first(), func(data *Page) (int, func(i int) error, error) {
+		var page tablePage
+		if err := jsoniter.Unmarshal(data.Data, &page); err != nil {
+			return 0, nil, fmt.Errorf("unmarshalling type %T: %w", page, err)
+		}
+
+		if columns == nil {
+			columns = make([]Column, 0, len(page.Columns))
+			for _, c := range page.Columns {
+				columns = append(columns, newColumn(c))
+			}
+
+			if columnsFn != nil {
+				if err := columnsFn(columns); err != nil {
+					return 0, nil, err
+				}
+			}
+		}
+
+		return len(page.Rows), func(i int) error {
+			row, err := convertTableRow(columns, page.Rows[i])
+			if err != nil {
+				return err
+			}
+
+			return fn(columns, row)
+		}, nil
+	})
+}
+
+// tablePage is the data of a single page of table-format results. The values are kept
+// raw to be converted according to the column types, so that integers keep their precision.
+type tablePage struct {
+	Columns []*armresourcegraph2.Column `json:"columns"`
+	Rows    [][]jsoniter.RawMessage     `json:"rows"`
+}
+
+func newColumn(c *armresourcegraph2.Column) Column {
+	var result Column
+	if c.Name != nil {
+		result.Name = *c.Name
+	}
+	if c.Type != nil {
+		result.Type = *c.Type
+	}
+	return result
+}
+
+func convertTableRow(columns []Column, raw []jsoniter.RawMessage) ([]any, error) {
+	if len(raw) != len(columns) {
+		return nil, fmt.Errorf("table row has %d values, expected %d columns", len(raw), len(columns))
+	}
+
+	row := make([]any, len(raw))
+	for i, value := range raw {
+		v, err := convertTableValue(columns[i].Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("column '%s' of type '%s': %w", columns[i].Name, columns[i].Type, err)
+		}
+		row[i] = v
+	}
+
+	return row, nil
+}
+
+func convertTableValue(columnType ColumnDataType, raw []byte) (any, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	switch columnType {
+	case ColumnDataTypeString:
+		var s string
+		if err := jsoniter.Unmarshal(raw, &s); err != nil {
+			// Strings can hold any scalar, keep its text.
+			return string(raw), nil
+		}
+		return s, nil
+	case ColumnDataTypeInteger:
+		return strconv.ParseInt(string(raw), 10, 64)
+	case ColumnDataTypeNumber:
+		return strconv.ParseFloat(string(raw), 64)
+	case ColumnDataTypeBoolean:
+		return strconv.ParseBool(string(raw))
+	case ColumnDataTypeDatetime:
+		var s string
+		if err := jsoniter.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		if s == "" {
+			return nil, nil
+		}
+		return armresourcegraph2.ParseTime(s)
+	default:
+		return json.RawMessage(append([]byte(nil), raw...)), nil
+	}
+}
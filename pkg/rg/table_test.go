@@ -0,0 +1,78 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"reflect"
+	"testing"
+)
+
+func TestExecTable(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`{"columns": [{"name": "name", "type": "string"}, {"name": "size", "type": "integer"}], "rows": [["a", 1], ["b", null]]}`,
+		`{"columns": [{"name": "name", "type": "string"}, {"name": "size", "type": "integer"}], "rows": [["c", 3]]}`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	table, err := rg.ExecTable(context.Background(), "resources | project name, size", &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantColumns := []rg.Column{{Name: "name", Type: rg.ColumnDataTypeString}, {Name: "size", Type: rg.ColumnDataTypeInteger}}
+	wantRows := [][]any{{"a", int64(1)}, {"b", nil}, {"c", int64(3)}}
+	if !reflect.DeepEqual(table.Columns, wantColumns) || !reflect.DeepEqual(table.Rows, wantRows) {
+		t.Errorf("got %v %v, want %v %v", table.Columns, table.Rows, wantColumns, wantRows)
+	}
+
+	if format := server.Requests()[0].Options.ResultFormat; format != "table" {
+		t.Errorf("got result format '%s', want table", format)
+	}
+}
+
+func TestExecTableFirst(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`{"columns": [{"name": "name", "type": "string"}], "rows": [["a"], ["b"]]}`,
+		`{"columns": [{"name": "name", "type": "string"}], "rows": [["c"], ["d"]]}`,
+		`{"columns": [{"name": "name", "type": "string"}], "rows": [["e"]]}`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	table, err := rg.ExecTable(context.Background(), "resources | project name", &rg.ExecOptions{Client: client, First: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(table.Rows) != 3 || table.Rows[2][0] != "c" || len(server.Requests()) != 2 {
+		t.Errorf("got rows %v in %d requests, want the first 3 rows in 2", table.Rows, len(server.Requests()))
+	}
+}
+
+func TestStreamTableColumns(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`{"columns": [{"name": "name", "type": "string"}], "rows": []}`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	var got []rg.Column
+	calls := 0
+	err := rg.StreamTableColumns(context.Background(), "resources | project name", &rg.ExecOptions{Client: client}, func(columns []rg.Column) error {
+		got = columns
+		calls++
+		return nil
+	}, func(columns []rg.Column, row []any) error {
+		t.Errorf("got row %v, want none", row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 || !reflect.DeepEqual(got, []rg.Column{{Name: "name", Type: rg.ColumnDataTypeString}}) {
+		t.Errorf("got the columns %v in %d calls, want them once", got, calls)
+	}
+}
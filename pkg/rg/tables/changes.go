@@ -0,0 +1,246 @@
+package tables
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/kql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResourceChanges is the "resourcechanges" table with the changes of resources over the last 14 days.
+var ResourceChanges = Table[ResourceChange]{Name: "resourcechanges"}
+
+// ChangeType is the type of the resource change.
+type ChangeType string
+
+const (
+	ChangeTypeCreate ChangeType = "Create"
+	ChangeTypeUpdate ChangeType = "Update"
+	ChangeTypeDelete ChangeType = "Delete"
+)
+
+// PropertyChangeType is the type of the change of a single property.
+type PropertyChangeType string
+
+const (
+	PropertyChangeTypeInsert PropertyChangeType = "Insert"
+	PropertyChangeTypeUpdate PropertyChangeType = "Update"
+	PropertyChangeTypeRemove PropertyChangeType = "Remove"
+)
+
+// ChangeCategory tells if a property was changed by a user or by the system.
+type ChangeCategory string
+
+const (
+	ChangeCategoryUser   ChangeCategory = "User"
+	ChangeCategorySystem ChangeCategory = "System"
+)
+
+// ResourceChange is a row of the "resourcechanges" table.
+type ResourceChange struct {
+	// ID is the ID of the change, which is an extension resource of the changed resource.
+	ID string `json:"id"`
+
+	// Name is the name of the change.
+	Name string `json:"name"`
+
+	// Type is "microsoft.resources/changes".
+	Type string `json:"type"`
+
+	// TenantID is the ID of the Microsoft Entra tenant of the changed resource.
+	TenantID string `json:"tenantId"`
+
+	// Location is the Azure region of the changed resource.
+	Location string `json:"location"`
+
+	// ResourceGroup is the resource group of the changed resource.
+	ResourceGroup string `json:"resourceGroup"`
+
+	// SubscriptionID is the subscription of the changed resource.
+	SubscriptionID string `json:"subscriptionId"`
+
+	// Properties describe the change.
+	Properties ResourceChangeProperties `json:"properties"`
+}
+
+// ResourceChangeProperties describe the resource change.
+type ResourceChangeProperties struct {
+	// TargetResourceID is the ID of the changed resource, see [ResourceChangeProperties.ParsedTargetResourceID].
+	TargetResourceID string `json:"targetResourceId"`
+
+	// TargetResourceType is the type of the changed resource.
+	TargetResourceType string `json:"targetResourceType"`
+
+	// ChangeType is the type of the change.
+	ChangeType ChangeType `json:"changeType"`
+
+	// ChangeAttributes tell when, how and by whom the resource was changed.
+	ChangeAttributes ChangeAttributes `json:"changeAttributes"`
+
+	// Changes are the changed properties keyed by their paths, e.g. "properties.provisioningState"
+	// or "tags.env". See [ResourceChangeProperties.PropertyChanges] for the ordered list.
+	Changes map[string]PropertyChange `json:"changes"`
+}
+
+// ParsedTargetResourceID parses the ID of the changed resource, see [Resource.ParsedID].
+func (p ResourceChangeProperties) ParsedTargetResourceID() (rg.ResourceID, error) {
+	return rg.ParseResourceID(p.TargetResourceID)
+}
+
+// PropertyChanges returns the changed properties ordered by path, with the paths filled in.
+func (p ResourceChangeProperties) PropertyChanges() []PropertyChange {
+	result := make([]PropertyChange, 0, len(p.Changes))
+	for path, change := range p.Changes {
+		change.Path = path
+		result = append(result, change)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	return result
+}
+
+// ChangeAttributes tell when, how and by whom the resource was changed.
+type ChangeAttributes struct {
+	// Timestamp is the time the change was detected.
+	Timestamp time.Time `json:"timestamp"`
+
+	// CorrelationID is the ID of the operation which made the change, to find it in the activity log.
+	CorrelationID string `json:"correlationId"`
+
+	// ChangedBy is the principal which made the change, e.g. a user name or an application ID.
+	ChangedBy string `json:"changedBy"`
+
+	// ChangedByType is the type of the principal, e.g. "User" or "AppId".
+	ChangedByType string `json:"changedByType"`
+
+	// ClientType is the client which made the change, e.g. "Azure Portal" or "CLI".
+	ClientType string `json:"clientType"`
+
+	// Operation is the operation which made the change, e.g. "microsoft.compute/virtualmachines/write".
+	Operation string `json:"operation"`
+
+	// ChangesCount is the number of changed properties.
+	ChangesCount int `json:"changesCount"`
+
+	// PreviousResourceSnapshotID is the ID of the resource snapshot before the change.
+	PreviousResourceSnapshotID string `json:"previousResourceSnapshotId"`
+
+	// NewResourceSnapshotID is the ID of the resource snapshot after the change.
+	NewResourceSnapshotID string `json:"newResourceSnapshotId"`
+}
+
+// PropertyChange is the change of a single property with its values before and after.
+type PropertyChange struct {
+	// Path is the path of the property, it is only filled in by [ResourceChangeProperties.PropertyChanges]
+	// as the changes are keyed by their paths.
+	Path string `json:"-"`
+
+	// PropertyChangeType is the type of the change of the property.
+	PropertyChangeType PropertyChangeType `json:"propertyChangeType"`
+
+	// ChangeCategory tells if the property was changed by a user or by the system.
+	ChangeCategory ChangeCategory `json:"changeCategory"`
+
+	// PreviousValue is the JSON value before the change, empty for inserted properties.
+	PreviousValue json.RawMessage `json:"previousValue"`
+
+	// NewValue is the JSON value after the change, empty for removed properties.
+	NewValue json.RawMessage `json:"newValue"`
+}
+
+// Values unmarshals the values before and after the change into previous and current,
+// which are left unchanged when the corresponding value is empty.
+func (c PropertyChange) Values(previous, current any) error {
+	if len(c.PreviousValue) > 0 {
+		if err := json.Unmarshal(c.PreviousValue, previous); err != nil {
+			return fmt.Errorf("previous value of '%s': %w", c.Path, err)
+		}
+	}
+
+	if len(c.NewValue) > 0 {
+		if err := json.Unmarshal(c.NewValue, current); err != nil {
+			return fmt.Errorf("new value of '%s': %w", c.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// ChangesFilter selects the resource changes for [ExecChanges].
+type ChangesFilter struct {
+	// Target is the ID of the changed resource, or of its scope like a subscription or a resource group
+	// to select the changes of all resources in it. Empty means all resources in the query scope.
+	Target string
+
+	// From is the start of the time window, inclusive. Zero means no start.
+	From time.Time
+
+	// To is the end of the time window, exclusive. Zero means no end.
+	To time.Time
+
+	// ChangeTypes are the types of changes to select. Empty means all types.
+	ChangeTypes []ChangeType
+}
+
+// Query returns the query text for the filter, the newest changes come first.
+func (f ChangesFilter) Query() string {
+	var sb strings.Builder
+	sb.WriteString(ResourceChanges.Name)
+
+	if target := strings.TrimRight(strings.TrimSpace(f.Target), "/"); target != "" {
+		fmt.Fprintf(&sb, "\n| where tostring(properties.targetResourceId) =~ %s or tostring(properties.targetResourceId) startswith %s",
+			kql.Quote(target), kql.Quote(target+"/"))
+	}
+
+	if !f.From.IsZero() {
+		fmt.Fprintf(&sb, "\n| where todatetime(properties.changeAttributes.timestamp) >= %s", quoteDatetime(f.From))
+	}
+
+	if !f.To.IsZero() {
+		fmt.Fprintf(&sb, "\n| where todatetime(properties.changeAttributes.timestamp) < %s", quoteDatetime(f.To))
+	}
+
+	if len(f.ChangeTypes) > 0 {
+		types := make([]string, 0, len(f.ChangeTypes))
+		for _, t := range f.ChangeTypes {
+			types = append(types, kql.Quote(string(t)))
+		}
+		fmt.Fprintf(&sb, "\n| where tostring(properties.changeType) in~ (%s)", strings.Join(types, ", "))
+	}
+
+	sb.WriteString("\n| order by todatetime(properties.changeAttributes.timestamp) desc")
+	return sb.String()
+}
+
+// ExecChanges returns the resource changes selected by the filter, the newest changes come first.
+//
+// Example:
+//
+//	changes, err := tables.ExecChanges(ctx, tables.ChangesFilter{
+//		Target: vmID,
+//		From:   time.Now().Add(-24 * time.Hour),
+//	}, nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	for _, c := range changes {
+//		for _, p := range c.Properties.PropertyChanges() {
+//			fmt.Printf("%s %s: %s -> %s\n", c.Properties.ChangeAttributes.Timestamp, p.Path, p.PreviousValue, p.NewValue)
+//		}
+//	}
+func ExecChanges(ctx context.Context, filter ChangesFilter, options *rg.ExecOptions) ([]ResourceChange, error) {
+	return rg.Exec[ResourceChange](ctx, filter.Query(), options)
+}
+
+// quoteDatetime returns the KQL datetime literal for t.
+func quoteDatetime(t time.Time) string {
+	return "datetime(" + t.UTC().Format(time.RFC3339Nano) + ")"
+}
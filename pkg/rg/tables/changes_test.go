@@ -0,0 +1,159 @@
+package tables_test
+
+import (
+	"context"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/tables"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangesFilterQuery(t *testing.T) {
+	tests := []struct {
+		filter tables.ChangesFilter
+		want   string
+	}{
+		{tables.ChangesFilter{}, "resourcechanges\n| order by todatetime(properties.changeAttributes.timestamp) desc"},
+		{
+			tables.ChangesFilter{
+				Target:      "/subscriptions/sub1/resourceGroups/rg1/",
+				From:        time.Date(2024, 1, 2, 4, 4, 5, 0, time.FixedZone("CET", 3600)),
+				To:          time.Date(2024, 1, 3, 0, 0, 0, 500, time.UTC),
+				ChangeTypes: []tables.ChangeType{tables.ChangeTypeCreate, tables.ChangeTypeDelete},
+			},
+			"resourcechanges\n" +
+				"| where tostring(properties.targetResourceId) =~ '/subscriptions/sub1/resourceGroups/rg1' or tostring(properties.targetResourceId) startswith '/subscriptions/sub1/resourceGroups/rg1/'\n" +
+				"| where todatetime(properties.changeAttributes.timestamp) >= datetime(2024-01-02T03:04:05Z)\n" +
+				"| where todatetime(properties.changeAttributes.timestamp) < datetime(2024-01-03T00:00:00.0000005Z)\n" +
+				"| where tostring(properties.changeType) in~ ('Create', 'Delete')\n" +
+				"| order by todatetime(properties.changeAttributes.timestamp) desc",
+		},
+		{tables.ChangesFilter{Target: "/subscriptions/o'brien"}, "resourcechanges\n| where tostring(properties.targetResourceId) =~ '/subscriptions/o\\'brien' or tostring(properties.targetResourceId) startswith '/subscriptions/o\\'brien/'\n| order by todatetime(properties.changeAttributes.timestamp) desc"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.Query(); got != tt.want {
+			t.Errorf("Query(%+v) =\n%s\nwant\n%s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+const changeRow = `{
+	"id": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1/providers/Microsoft.Resources/changes/change1",
+	"name": "change1",
+	"type": "microsoft.resources/changes",
+	"subscriptionId": "sub1",
+	"resourceGroup": "rg1",
+	"properties": {
+		"targetResourceId": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1",
+		"targetResourceType": "microsoft.compute/virtualmachines",
+		"changeType": "Update",
+		"changeAttributes": {
+			"timestamp": "2024-01-02T03:04:05.123Z",
+			"changedBy": "user@example.com",
+			"changedByType": "User",
+			"clientType": "Azure Portal",
+			"operation": "microsoft.compute/virtualmachines/write",
+			"changesCount": 3
+		},
+		"changes": {
+			"tags.env": {"propertyChangeType": "Update", "changeCategory": "User", "previousValue": "dev", "newValue": "prod"},
+			"properties.hardwareProfile.vmSize": {"propertyChangeType": "Update", "changeCategory": "User", "previousValue": "Standard_B1s", "newValue": "Standard_B2s"},
+			"tags.owner": {"propertyChangeType": "Insert", "changeCategory": "User", "newValue": "a"},
+			"zones": {"propertyChangeType": "Remove", "changeCategory": "System", "previousValue": ["1"]}
+		}
+	}
+}`
+
+func TestExecChanges(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`[` + changeRow + `, {"id": "not an ID", "properties": {"targetResourceId": "", "changes": null}}]`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	changes, err := tables.ExecChanges(context.Background(), tables.ChangesFilter{ChangeTypes: []tables.ChangeType{tables.ChangeTypeUpdate}}, &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+
+	change := changes[0].Properties
+	if change.ChangeType != tables.ChangeTypeUpdate || change.ChangeAttributes.ChangesCount != 3 || !change.ChangeAttributes.Timestamp.Equal(time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC)) {
+		t.Errorf("unexpected change %+v", change)
+	}
+
+	target, err := change.ParsedTargetResourceID()
+	if err != nil || target.Name() != "vm1" {
+		t.Errorf("got target %+v, %v", target, err)
+	}
+
+	// The change with the invalid IDs doesn't fail the page.
+	if _, err := changes[1].Properties.ParsedTargetResourceID(); err == nil || len(changes[1].Properties.PropertyChanges()) != 0 {
+		t.Errorf("got %+v, want no changes and an invalid target", changes[1])
+	}
+
+	if query := server.Requests()[0].Query; !strings.Contains(query, "in~ ('Update')") {
+		t.Errorf("got query %q", query)
+	}
+}
+
+func TestPropertyChanges(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`[` + changeRow + `]`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	changes, err := tables.ExecChanges(context.Background(), tables.ChangesFilter{}, &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The changes are ordered by path.
+	var got []string
+	for _, p := range changes[0].Properties.PropertyChanges() {
+		got = append(got, string(p.PropertyChangeType)+" "+p.Path)
+	}
+
+	want := "Update properties.hardwareProfile.vmSize, Update tags.env, Insert tags.owner, Remove zones"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, ", "), want)
+	}
+}
+
+func TestPropertyChangeValues(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`[` + changeRow + `]`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	changes, err := tables.ExecChanges(context.Background(), tables.ChangesFilter{}, &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byPath := map[string]tables.PropertyChange{}
+	for _, p := range changes[0].Properties.PropertyChanges() {
+		byPath[p.Path] = p
+	}
+
+	var previous, current string
+	if err := byPath["tags.env"].Values(&previous, &current); err != nil || previous != "dev" || current != "prod" {
+		t.Errorf("got %q -> %q, %v", previous, current, err)
+	}
+
+	// The missing values leave the targets unchanged.
+	previous, current = "unset", "unset"
+	if err := byPath["tags.owner"].Values(&previous, &current); err != nil || previous != "unset" || current != "a" {
+		t.Errorf("got %q -> %q, %v", previous, current, err)
+	}
+
+	var zones []string
+	current = "unset"
+	if err := byPath["zones"].Values(&zones, &current); err != nil || len(zones) != 1 || current != "unset" {
+		t.Errorf("got %v -> %q, %v", zones, current, err)
+	}
+
+	var size int
+	if err := byPath["tags.env"].Values(&size, &current); err == nil || !strings.HasPrefix(err.Error(), "previous value of 'tags.env': ") {
+		t.Errorf("got error %v, want the previous value error", err)
+	}
+}
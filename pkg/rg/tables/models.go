@@ -0,0 +1,141 @@
+package tables
+
+import (
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+)
+
+// Resource has the standard columns which all well-known tables share.
+type Resource struct {
+	// ID is the fully qualified resource ID, see [Resource.ParsedID].
+	ID string `json:"id"`
+
+	// Name is the resource name.
+	Name string `json:"name"`
+
+	// Type is the resource type in lower case, e.g. "microsoft.compute/virtualmachines".
+	Type string `json:"type"`
+
+	// TenantID is the ID of the Microsoft Entra tenant of the resource.
+	TenantID string `json:"tenantId"`
+
+	// Kind is the kind of the resource, its meaning depends on the resource type.
+	Kind string `json:"kind"`
+
+	// Location is the Azure region of the resource in lower case, e.g. "westeurope".
+	Location string `json:"location"`
+
+	// ResourceGroup is the name of the resource group of the resource.
+	ResourceGroup string `json:"resourceGroup"`
+
+	// SubscriptionID is the ID of the subscription of the resource.
+	SubscriptionID string `json:"subscriptionId"`
+
+	// ManagedBy is the ID of the resource which manages this resource, empty if there is none.
+	ManagedBy string `json:"managedBy"`
+
+	// SKU is the SKU of the resource, if it has one.
+	SKU *SKU `json:"sku"`
+
+	// Plan is the marketplace plan of the resource, if it has one.
+	Plan *Plan `json:"plan"`
+
+	// Properties are the resource-specific properties as raw JSON, to be unmarshalled into
+	// the type specific to the resource type.
+	Properties json.RawMessage `json:"properties"`
+
+	// Tags are the resource tags.
+	Tags map[string]string `json:"tags"`
+
+	// Identity is the managed identity of the resource, if it has one.
+	Identity *Identity `json:"identity"`
+
+	// Zones are the availability zones of the resource.
+	Zones []string `json:"zones"`
+
+	// ExtendedLocation is the extended location of the resource, e.g. an edge zone.
+	ExtendedLocation *ExtendedLocation `json:"extendedLocation"`
+}
+
+// ParsedID parses the resource ID. The IDs are kept as strings, so that a row with an ID which
+// doesn't parse doesn't fail the whole page.
+func (r Resource) ParsedID() (rg.ResourceID, error) {
+	return rg.ParseResourceID(r.ID)
+}
+
+// ParsedManagedBy parses the ID of the resource which manages this resource, it is the zero
+// ResourceID when there is none.
+func (r Resource) ParsedManagedBy() (rg.ResourceID, error) {
+	var result rg.ResourceID
+	err := result.UnmarshalText([]byte(r.ManagedBy))
+	return result, err
+}
+
+// SKU is the SKU of the resource.
+type SKU struct {
+	Name     string `json:"name"`
+	Tier     string `json:"tier"`
+	Size     string `json:"size"`
+	Family   string `json:"family"`
+	Model    string `json:"model"`
+	Capacity *int64 `json:"capacity"`
+}
+
+// Plan is the marketplace plan of the resource.
+type Plan struct {
+	Name          string `json:"name"`
+	Publisher     string `json:"publisher"`
+	Product       string `json:"product"`
+	PromotionCode string `json:"promotionCode"`
+	Version       string `json:"version"`
+}
+
+// Identity is the managed identity of the resource.
+type Identity struct {
+	// Type is "SystemAssigned", "UserAssigned", "SystemAssigned, UserAssigned" or "None".
+	Type string `json:"type"`
+
+	// PrincipalID is the principal ID of the system-assigned identity.
+	PrincipalID string `json:"principalId"`
+
+	// TenantID is the tenant ID of the system-assigned identity.
+	TenantID string `json:"tenantId"`
+
+	// UserAssignedIdentities are the user-assigned identities keyed by their resource IDs.
+	UserAssignedIdentities map[string]UserAssignedIdentity `json:"userAssignedIdentities"`
+}
+
+// UserAssignedIdentity is a user-assigned managed identity.
+type UserAssignedIdentity struct {
+	PrincipalID string `json:"principalId"`
+	ClientID    string `json:"clientId"`
+}
+
+// ExtendedLocation is the extended location of the resource.
+type ExtendedLocation struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// The rows of the tables below have the standard columns, their own columns are in the properties.
+
+// ResourceContainer is a row of the "resourcecontainers" table.
+type ResourceContainer = Resource
+
+// AdvisorResource is a row of the "advisorresources" table.
+type AdvisorResource = Resource
+
+// SecurityResource is a row of the "securityresources" table.
+type SecurityResource = Resource
+
+// HealthResource is a row of the "healthresources" table.
+type HealthResource = Resource
+
+// PolicyResource is a row of the "policyresources" table.
+type PolicyResource = Resource
+
+// ServiceHealthResource is a row of the "servicehealthresources" table.
+type ServiceHealthResource = Resource
+
+// MaintenanceResource is a row of the "maintenanceresources" table.
+type MaintenanceResource = Resource
@@ -0,0 +1,78 @@
+// Package tables provides Go types for the standard columns of the well-known
+// Azure Resource Graph tables, and ready-made helpers to query them.
+//
+// Example:
+//
+//	vms, err := tables.Resources.Exec(ctx, "| where type =~ 'microsoft.compute/virtualmachines'", nil)
+//	if err != nil {
+//		panic(err)
+//	}
+//
+//	for _, vm := range vms {
+//		fmt.Printf("%s, %s, %s\n", vm.Name, vm.Location, vm.Tags["env"])
+//	}
+package tables
+
+import (
+	"context"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"strings"
+)
+
+// Table is a well-known Resource Graph table with rows unmarshalled as T.
+type Table[T any] struct {
+	// Name is the table name as used in queries.
+	Name string
+}
+
+var (
+	// Resources is the "resources" table with most resources managed by Azure Resource Manager.
+	Resources = Table[Resource]{Name: "resources"}
+
+	// ResourceContainers is the "resourcecontainers" table with management groups,
+	// subscriptions and resource groups.
+	ResourceContainers = Table[ResourceContainer]{Name: "resourcecontainers"}
+
+	// AdvisorResources is the "advisorresources" table with Azure Advisor recommendations.
+	AdvisorResources = Table[AdvisorResource]{Name: "advisorresources"}
+
+	// SecurityResources is the "securityresources" table with Microsoft Defender for Cloud
+	// assessments, alerts and secure scores.
+	SecurityResources = Table[SecurityResource]{Name: "securityresources"}
+
+	// HealthResources is the "healthresources" table with Resource Health availability statuses.
+	HealthResources = Table[HealthResource]{Name: "healthresources"}
+
+	// PolicyResources is the "policyresources" table with Azure Policy states and assignments.
+	PolicyResources = Table[PolicyResource]{Name: "policyresources"}
+
+	// ServiceHealthResources is the "servicehealthresources" table with Service Health events.
+	ServiceHealthResources = Table[ServiceHealthResource]{Name: "servicehealthresources"}
+
+	// MaintenanceResources is the "maintenanceresources" table with maintenance configurations
+	// and updates.
+	MaintenanceResources = Table[MaintenanceResource]{Name: "maintenanceresources"}
+)
+
+// Query returns the query text for the table with the filter appended to the table name.
+// The filter is the rest of the query after the table name, e.g. "| where location =~ 'westeurope'",
+// it can be empty to return all rows.
+func (t Table[T]) Query(filter string) string {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return t.Name
+	}
+
+	return t.Name + "\n" + filter
+}
+
+// Exec queries the table and returns the rows. See [Table.Query] for the filter.
+// The filter should only filter and sort the rows, so that they keep the standard columns.
+func (t Table[T]) Exec(ctx context.Context, filter string, options *rg.ExecOptions) ([]T, error) {
+	return rg.Exec[T](ctx, t.Query(filter), options)
+}
+
+// Stream queries the table and calls fn for each row as pages arrive. See [Table.Query] for the filter.
+func (t Table[T]) Stream(ctx context.Context, filter string, options *rg.ExecOptions, fn func(row T) error) error {
+	return rg.Stream(ctx, t.Query(filter), options, fn)
+}
@@ -0,0 +1,132 @@
+package tables_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/tables"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{"", "resources"},
+		{"  ", "resources"},
+		{"| where location =~ 'westeurope' ", "resources\n| where location =~ 'westeurope'"},
+	}
+
+	for _, tt := range tests {
+		if got := tables.Resources.Query(tt.filter); got != tt.want {
+			t.Errorf("Query(%q) = %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+}
+
+const vmRow = `{
+	"id": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1",
+	"name": "vm1",
+	"type": "microsoft.compute/virtualmachines",
+	"tenantId": "tenant1",
+	"kind": "",
+	"location": "westeurope",
+	"resourceGroup": "rg1",
+	"subscriptionId": "sub1",
+	"managedBy": "",
+	"sku": null,
+	"plan": {"name": "plan1", "publisher": "publisher1", "product": "product1"},
+	"properties": {"vmId": "42"},
+	"tags": {"env": "prod"},
+	"identity": {"type": "UserAssigned", "userAssignedIdentities": {"/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id1": {"principalId": "p1", "clientId": "c1"}}},
+	"zones": ["1"],
+	"extendedLocation": null
+}`
+
+func TestExec(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{`[` + vmRow + `]`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	vms, err := tables.Resources.Exec(context.Background(), "| where type =~ 'microsoft.compute/virtualmachines'", &rg.ExecOptions{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vms) != 1 {
+		t.Fatalf("got %d rows, want 1", len(vms))
+	}
+
+	vm := vms[0]
+	if vm.Name != "vm1" || vm.Location != "westeurope" || vm.SubscriptionID != "sub1" || vm.Tags["env"] != "prod" || !reflect.DeepEqual(vm.Zones, []string{"1"}) {
+		t.Errorf("unexpected standard columns %+v", vm)
+	}
+
+	if vm.SKU != nil || vm.Plan == nil || vm.Plan.Publisher != "publisher1" || vm.ExtendedLocation != nil || string(vm.Properties) != `{"vmId": "42"}` {
+		t.Errorf("unexpected SKU %v, plan %v, extended location %v or properties %s", vm.SKU, vm.Plan, vm.ExtendedLocation, vm.Properties)
+	}
+
+	if vm.Identity == nil || len(vm.Identity.UserAssignedIdentities) != 1 {
+		t.Errorf("unexpected identity %+v", vm.Identity)
+	}
+
+	if query := server.Requests()[0].Query; query != "resources\n| where type =~ 'microsoft.compute/virtualmachines'" {
+		t.Errorf("got query %q", query)
+	}
+}
+
+func TestStream(t *testing.T) {
+	server := &rgtest.Server{Pages: []string{
+		`[{"name": "sub1", "type": "microsoft.resources/subscriptions"}]`,
+		`[{"name": "rg1", "type": "microsoft.resources/subscriptions/resourcegroups"}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	var names []string
+	err := tables.ResourceContainers.Stream(context.Background(), "", &rg.ExecOptions{Client: client}, func(row tables.ResourceContainer) error {
+		names = append(names, row.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"sub1", "rg1"}) || server.Requests()[0].Query != "resourcecontainers" {
+		t.Errorf("got %v from query %q", names, server.Requests()[0].Query)
+	}
+}
+
+func TestParsedID(t *testing.T) {
+	// The row with the invalid ID still unmarshals.
+	var rows []tables.Resource
+	if err := json.Unmarshal([]byte(`[`+vmRow+`, {"id": "not an ID", "managedBy": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1"}]`), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := rows[0].ParsedID()
+	if err != nil || id.Name() != "vm1" || id.ResourceGroup != "rg1" {
+		t.Errorf("got %+v, %v", id, err)
+	}
+
+	if managedBy, err := rows[0].ParsedManagedBy(); err != nil || !managedBy.IsZero() {
+		t.Errorf("got %+v, %v, want the zero ID", managedBy, err)
+	}
+
+	if _, err := rows[1].ParsedID(); err == nil {
+		t.Error("ParsedID succeeded, want an error")
+	}
+
+	if managedBy, err := rows[1].ParsedManagedBy(); err != nil || managedBy.Name() != "vm1" {
+		t.Errorf("got %+v, %v", managedBy, err)
+	}
+}
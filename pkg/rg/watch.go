@@ -0,0 +1,320 @@
+//go:build go1.18
+// +build go1.18
+
+package rg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/armresourcegraph2"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/jsondiff"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EventType is the type of change which [Watch] detects.
+type EventType string
+
+const (
+	EventAdded    EventType = "added"
+	EventRemoved  EventType = "removed"
+	EventModified EventType = "modified"
+)
+
+// Change is a change of a single property of a row.
+type Change = jsondiff.Change
+
+// Event is a change of a row between two consecutive runs of the watched query.
+type Event struct {
+	// Type is the type of change.
+	Type EventType
+
+	// Key is the key of the row, which is the value of the key column in lower case.
+	Key string
+
+	// Row is the row JSON from the latest run, or the last known row when it is removed.
+	Row json.RawMessage
+
+	// Old is the row JSON from the previous run for modified rows.
+	Old json.RawMessage
+
+	// Changes are the changed properties of modified rows, ordered by path.
+	Changes []Change
+
+	// Time is the time of the run which detected the change.
+	Time time.Time
+}
+
+// WatchOptions are the optional parameters for [Watch].
+type WatchOptions struct {
+	// ExecOptions are the options to run the query with. First and Skip are best left unset,
+	// as the rows which move in and out of the window show up as added and removed.
+	ExecOptions
+
+	// Key is the column which identifies the rows, the default is "id".
+	Key string
+
+	// Interval is the time between the runs, the default is 5 minutes.
+	Interval time.Duration
+
+	// Jitter is the maximum random time added to each interval, so that several watchers
+	// don't run their queries at the same moment.
+	Jitter time.Duration
+
+	// Initial makes the first run send all rows as added, otherwise the first run only
+	// sets the baseline for the next runs.
+	Initial bool
+
+	// Events receives the events when not nil. Watch blocks until the event is received or
+	// the context is done, and closes the channel when it returns.
+	Events chan<- Event
+
+	// OnEvent is called for each event when not nil. If it returns an error, Watch stops with that error.
+	OnEvent func(event Event) error
+
+	// OnError is called when a run fails, e.g. due to a network error. If it returns nil, the
+	// watch continues with the next run and the rows from the last successful run. When nil,
+	// Watch stops with the error of the run.
+	OnError func(err error) error
+}
+
+// defaultWatchInterval is the time between the runs when WatchOptions.Interval is not given.
+const defaultWatchInterval = 5 * time.Minute
+
+// Watch runs the query repeatedly and sends the rows which were added, removed or modified
+// since the previous run as events to [WatchOptions.Events] and [WatchOptions.OnEvent].
+// The rows are compared by the key column. It blocks until the context is done, when it
+// returns nil, or until an error stops it.
+//
+// Watch respects the throttling quota: when the quota is used up, or a run is throttled,
+// the next run waits until the quota resets even if that is longer than the interval.
+//
+// Example:
+//
+//	events := make(chan rg.Event)
+//	go func() {
+//		for e := range events {
+//			fmt.Printf("%s %s\n", e.Type, e.Key)
+//		}
+//	}()
+//
+//	err := rg.Watch(ctx, "resources | where type =~ 'microsoft.network/publicipaddresses'", &rg.WatchOptions{
+//		Interval: 10 * time.Minute,
+//		Jitter:   time.Minute,
+//		Events:   events,
+//	})
+func Watch(ctx context.Context, query string, options *WatchOptions) error {
+	if options == nil {
+		options = &WatchOptions{}
+	}
+
+	if options.Events != nil {
+		defer close(options.Events)
+	}
+
+	key := options.Key
+	if key == "" {
+		key = "id"
+	}
+
+	interval := options.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	// The global source of math/rand is not seeded before Go 1.20, so all processes would wait the same jitter.
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Without the baseline, the first run doesn't send events.
+	var previous map[string]json.RawMessage
+	if options.Initial {
+		previous = map[string]json.RawMessage{}
+	}
+
+	for {
+		now := time.Now().UTC()
+		rows, quota, err := watchRun(ctx, query, key, &options.ExecOptions)
+		if err != nil && ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			if options.OnError == nil {
+				return err
+			}
+			if err := options.OnError(err); err != nil {
+				return err
+			}
+		} else {
+			if previous != nil {
+				if err := sendWatchEvents(ctx, options, previous, rows, now); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+			}
+			previous = rows
+		}
+
+		wait := interval
+		if options.Jitter > 0 {
+			wait += time.Duration(random.Int63n(int64(options.Jitter)))
+		}
+		if quota != nil && quota.Remaining <= 0 && quota.ResetsAfter > wait {
+			log.Printf("Watch: the query quota is used up, waiting %s for it to reset", quota.ResetsAfter)
+			wait = quota.ResetsAfter
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// watchRun runs the query and returns the rows by key and the quota after the last page,
+// or after the failed page when the run fails.
+func watchRun(ctx context.Context, query string, key string, options *ExecOptions) (map[string]json.RawMessage, *Quota, error) {
+	pager, err := NewPager(ctx, query, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var quota *Quota
+	rows := map[string]json.RawMessage{}
+	err = pager.eachRow(options.First, func(page *Page) (int, func(i int) error, error) {
+		if page.Quota != nil {
+			quota = page.Quota
+		}
+
+		var pageRows []json.RawMessage
+		if err := pager.Decode(page, &pageRows); err != nil {
+			return 0, nil, err
+		}
+
+		return len(pageRows), func(i int) error {
+			k, err := jsondiff.Key(pageRows[i], key)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := rows[k]; ok {
+				log.Printf("Watch: duplicate key '%s', keeping the last row", k)
+			}
+			rows[k] = pageRows[i]
+			return nil
+		}, nil
+	})
+	if err != nil {
+		if q := errorQuota(err); q != nil {
+			quota = q
+		}
+		return nil, quota, err
+	}
+
+	return rows, quota, nil
+}
+
+// errorQuota returns the quota from the response of the failed request, which for throttled
+// requests is 429 Too Many Requests with the Retry-After header. It returns nil when the error
+// has no response or the response has no quota.
+func errorQuota(err error) *Quota {
+	var responseError *azcore.ResponseError
+	if !errors.As(err, &responseError) || responseError.RawResponse == nil {
+		return nil
+	}
+
+	header := responseError.RawResponse.Header
+	var result *Quota
+	remaining, resetsAfter := armresourcegraph2.ReadQuota(header)
+	if remaining != nil {
+		result = &Quota{Remaining: *remaining}
+		if resetsAfter != nil {
+			result.ResetsAfter = *resetsAfter
+		}
+	}
+
+	if retryAfter := parseRetryAfter(header.Get("Retry-After")); retryAfter > 0 {
+		if result == nil {
+			result = &Quota{}
+		}
+		result.Remaining = 0
+		if retryAfter > result.ResetsAfter {
+			result.ResetsAfter = retryAfter
+		}
+	}
+
+	if result == nil && responseError.StatusCode == http.StatusTooManyRequests {
+		// Throttled without telling for how long, the quota resets within the 5 seconds window.
+		result = &Quota{ResetsAfter: throttledWait}
+	}
+
+	return result
+}
+
+// throttledWait is the wait after a throttled run when the response doesn't tell how long to wait.
+const throttledWait = 5 * time.Second
+
+// parseRetryAfter returns the duration of the Retry-After header, which is in seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+func sendWatchEvents(ctx context.Context, options *WatchOptions, previous, current map[string]json.RawMessage, now time.Time) error {
+	diff, err := jsondiff.CompareRows(previous, current)
+	if err != nil {
+		return err
+	}
+
+	var events []Event
+	for _, k := range diff.Added {
+		events = append(events, Event{Type: EventAdded, Key: k, Row: current[k], Time: now})
+	}
+
+	for _, k := range diff.Removed {
+		events = append(events, Event{Type: EventRemoved, Key: k, Row: previous[k], Time: now})
+	}
+
+	for _, k := range diff.Modified {
+		events = append(events, Event{Type: EventModified, Key: k, Row: current[k], Old: previous[k], Changes: diff.Changes[k], Time: now})
+	}
+
+	for _, event := range events {
+		if options.OnEvent != nil {
+			if err := options.OnEvent(event); err != nil {
+				return err
+			}
+		}
+
+		if options.Events != nil {
+			select {
+			case options.Events <- event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+//go:build go1.18
+// +build go1.18
+
+package rg_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg"
+	"github.com/ppanyukov/azure-resource-graph-go/pkg/rg/internal/rgtest"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runsServer serves a single page per run, the rows of the last run are repeated.
+// The runs with the status respond with it instead, e.g. to throttle them.
+type runsServer struct {
+	runs   []string
+	status map[int]int
+	header http.Header
+
+	// onRun is called with the index of each run when not nil.
+	onRun func(run int)
+
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (s *runsServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	run := len(s.times)
+	s.times = append(s.times, time.Now())
+	s.mu.Unlock()
+
+	if s.onRun != nil {
+		s.onRun(run)
+	}
+
+	if status := s.status[run]; status != 0 {
+		for name, values := range s.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, `{"error": {"code": "RateLimiting", "message": "throttled"}}`)
+		return
+	}
+
+	if run >= len(s.runs) {
+		run = len(s.runs) - 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"count": 1, "data": %s, "totalRecords": 1, "resultTruncated": "false"}`, s.runs[run])
+}
+
+func TestWatch(t *testing.T) {
+	server := &runsServer{runs: []string{
+		`[{"id": "/a", "size": 1}, {"id": "/b", "size": 1}]`,
+		`[{"id": "/a", "size": 2}, {"id": "/c", "size": 1}]`,
+	}}
+	client := rgtest.NewClient(t, server, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan rg.Event)
+	done := make(chan error, 1)
+	go func() {
+		done <- rg.Watch(ctx, "resources", &rg.WatchOptions{
+			ExecOptions: rg.ExecOptions{Client: client},
+			Interval:    10 * time.Millisecond,
+			Events:      events,
+		})
+	}()
+
+	var got []string
+	for e := range events {
+		got = append(got, string(e.Type)+" "+e.Key)
+		if e.Type == rg.EventModified && (len(e.Changes) != 1 || e.Changes[0].Path != "$.size" || string(e.Old) != `{"id": "/a", "size": 1}`) {
+			t.Errorf("unexpected modified event %+v", e)
+		}
+
+		if len(got) == 3 {
+			cancel()
+		}
+	}
+
+	// The channel is closed when Watch returns.
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"added /c", "removed /b", "modified /a"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWatchInitial(t *testing.T) {
+	server := &runsServer{runs: []string{`[{"name": "a"}]`}}
+	client := rgtest.NewClient(t, server, nil)
+
+	var events []rg.Event
+	stop := errors.New("stop")
+	err := rg.Watch(context.Background(), "resources", &rg.WatchOptions{
+		ExecOptions: rg.ExecOptions{Client: client},
+		Key:         "name",
+		Initial:     true,
+		OnEvent: func(e rg.Event) error {
+			events = append(events, e)
+			return stop
+		},
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("got error %v, want %v", err, stop)
+	}
+
+	if len(events) != 1 || events[0].Type != rg.EventAdded || events[0].Key != "a" || !json.Valid(events[0].Row) {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestWatchThrottled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := &runsServer{
+		runs:   []string{`[{"id": "/a"}]`},
+		status: map[int]int{1: http.StatusTooManyRequests},
+		header: http.Header{"Retry-After": {"1"}},
+		onRun: func(run int) {
+			if run == 2 {
+				cancel()
+			}
+		},
+	}
+	client := rgtest.NewClient(t, server, nil)
+
+	var runErrors []error
+	err := rg.Watch(ctx, "resources", &rg.WatchOptions{
+		ExecOptions: rg.ExecOptions{Client: client},
+		Interval:    10 * time.Millisecond,
+		OnError: func(err error) error {
+			runErrors = append(runErrors, err)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.times) != 3 || len(runErrors) != 1 {
+		t.Fatalf("got %d runs and errors %v, want 3 runs and the error of the throttled one", len(server.times), runErrors)
+	}
+
+	// The run after the throttled one waits for Retry-After instead of the interval.
+	if wait := server.times[2].Sub(server.times[1]); wait < 900*time.Millisecond {
+		t.Errorf("the run after the throttled one came after %s, want 1s", wait)
+	}
+}
+
+func TestWatchFirst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := &runsServer{
+		runs: []string{`[{"id": "/a"}, {"id": "/b"}, {"id": "/c"}]`},
+		onRun: func(run int) {
+			if run == 1 {
+				cancel()
+			}
+		},
+	}
+	client := rgtest.NewClient(t, server, nil)
+
+	var keys []string
+	err := rg.Watch(ctx, "resources", &rg.WatchOptions{
+		ExecOptions: rg.ExecOptions{Client: client, First: 2},
+		Interval:    10 * time.Millisecond,
+		Initial:     true,
+		OnEvent: func(e rg.Event) error {
+			keys = append(keys, e.Key)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(keys) != "[/a /b]" {
+		t.Errorf("got the rows %v, want the first 2", keys)
+	}
+}